@@ -4,6 +4,7 @@ package webrtc
 
 import (
 	"github.com/pion/logging"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 )
 
 // API bundles the global funcions of the WebRTC and ORTC API.
@@ -11,8 +12,13 @@ import (
 // defaultAPI object. Note that the global version of the API
 // may be phased out in the future.
 type API struct {
-	settingEngine *SettingEngine
-	mediaEngine   *MediaEngine
+	settingEngine       *SettingEngine
+	mediaEngine         *MediaEngine
+	interceptorRegistry *interceptor.Registry
+
+	// The Interceptor RTPSenders and RTPReceivers use, every PeerConnection
+	// has an API of its own with Interceptors built from interceptorRegistry
+	interceptor interceptor.Interceptor
 }
 
 // NewAPI Creates a new API object for keeping semi-global settings to WebRTC objects
@@ -35,6 +41,14 @@ func NewAPI(options ...func(*API)) *API {
 		a.mediaEngine = &MediaEngine{}
 	}
 
+	if a.interceptorRegistry == nil {
+		a.interceptorRegistry = &interceptor.Registry{}
+	}
+
+	if a.interceptor == nil {
+		a.interceptor = &interceptor.NoOp{}
+	}
+
 	return a
 }
 
@@ -53,3 +67,12 @@ func WithSettingEngine(s SettingEngine) func(a *API) {
 		a.settingEngine = &s
 	}
 }
+
+// WithInterceptorRegistry allows providing Interceptors to the API.
+// Every PeerConnection creates Interceptors of its own from the Registry.
+// Settings should not be changed after passing the registry to an API.
+func WithInterceptorRegistry(r *interceptor.Registry) func(a *API) {
+	return func(a *API) {
+		a.interceptorRegistry = r
+	}
+}
@@ -0,0 +1,134 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+
+	"github.com/pion/sdp/v2"
+	"github.com/pion/webrtc/v2/internal/util"
+)
+
+// mediaTransport is the ICE and DTLS transport an m-line is negotiated on when
+// the remote doesn't accept BUNDLE
+type mediaTransport struct {
+	iceGatherer   *ICEGatherer
+	iceTransport  *ICETransport
+	dtlsTransport *DTLSTransport
+
+	// Set once startMediaTransports started the transports
+	started bool
+}
+
+// stop closes the transports, an ICEGatherer that was never started is closed as well
+func (t *mediaTransport) stop() error {
+	var closeErrs []error
+	if err := t.iceTransport.Stop(); err != nil {
+		closeErrs = append(closeErrs, err)
+	}
+	if err := t.dtlsTransport.Stop(); err != nil {
+		closeErrs = append(closeErrs, err)
+	}
+	return util.FlattenErrs(closeErrs)
+}
+
+// getBundleGroup returns the mids of the BUNDLE group of a description. The
+// first one tags the m-line whose transport is shared by the whole group
+// https://tools.ietf.org/html/rfc8843#section-7.2
+func getBundleGroup(desc *sdp.SessionDescription) []string {
+	for _, attr := range desc.Attributes {
+		if attr.Key != sdp.AttrKeyGroup {
+			continue
+		}
+
+		if fields := strings.Fields(attr.Value); len(fields) > 1 && fields[0] == "BUNDLE" {
+			return fields[1:]
+		}
+	}
+	return nil
+}
+
+// getMediaDescriptionByMid returns the m-line with the given mid, or nil if there is none
+func getMediaDescriptionByMid(desc *sdp.SessionDescription, mid string) *sdp.MediaDescription {
+	for _, media := range desc.MediaDescriptions {
+		if value, ok := media.Attribute(sdp.AttrKeyMID); ok && value == mid {
+			return media
+		}
+	}
+	return nil
+}
+
+// getTransportMediaDescription returns the m-line that carries the transport
+// of a description, the tagged m-line of the BUNDLE group or else the first
+// one that isn't rejected
+func getTransportMediaDescription(desc *sdp.SessionDescription) *sdp.MediaDescription {
+	if group := getBundleGroup(desc); group != nil {
+		if media := getMediaDescriptionByMid(desc, group[0]); media != nil {
+			return media
+		}
+	}
+
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Port.Value != 0 {
+			return media
+		}
+	}
+
+	if len(desc.MediaDescriptions) == 0 {
+		return nil
+	}
+	return desc.MediaDescriptions[0]
+}
+
+// getMediaTransportMids returns the mids of the m-lines that have a transport of
+// their own if BUNDLE is not used, in order. The first one uses the transports of
+// the PeerConnection, any m-line that is left out can only be negotiated with BUNDLE.
+// https://tools.ietf.org/html/rfc8829#section-4.1.1
+func getMediaTransportMids(policy BundlePolicy, mediaDescriptions []*sdp.MediaDescription) []string {
+	mids := []string{}
+	kinds := map[string]bool{}
+	for _, media := range mediaDescriptions {
+		mid, ok := media.Attribute(sdp.AttrKeyMID)
+		if !ok || media.MediaName.Port.Value == 0 {
+			continue
+		}
+
+		switch {
+		case len(mids) == 0, policy == BundlePolicyMaxCompat:
+		case policy == BundlePolicyMaxBundle, kinds[media.MediaName.Media]:
+			// balanced only gives the first m-line of every media type a transport
+			continue
+		}
+		kinds[media.MediaName.Media] = true
+		mids = append(mids, mid)
+	}
+	return mids
+}
+
+// setMediaICEDetails replaces the ICE credentials and candidates of an m-line
+func setMediaICEDetails(media *sdp.MediaDescription, iceParams ICEParameters, candidates []ICECandidate) {
+	attributes := []sdp.Attribute{}
+	for _, attr := range media.Attributes {
+		switch attr.Key {
+		case "ice-ufrag", "ice-pwd", "candidate", "end-of-candidates":
+			continue
+		}
+		attributes = append(attributes, attr)
+	}
+	media.Attributes = attributes
+
+	media.WithICECredentials(iceParams.UsernameFragment, iceParams.Password)
+	addCandidatesToMediaDescriptions(candidates, media)
+}
+
+// rejectMediaDescription turns an m-line into a rejected one, keeping only its mid
+func rejectMediaDescription(media *sdp.MediaDescription) {
+	attributes := []sdp.Attribute{}
+	if mid, ok := media.Attribute(sdp.AttrKeyMID); ok {
+		attributes = append(attributes, sdp.NewAttribute(sdp.AttrKeyMID, mid))
+	}
+
+	media.MediaName.Port = sdp.RangedPort{Value: 0}
+	media.ConnectionInformation = nil
+	media.Attributes = attributes
+}
@@ -0,0 +1,45 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/sdp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMediaTransportMids(t *testing.T) {
+	mediaDescriptions := []*sdp.MediaDescription{}
+	for _, m := range []struct {
+		kind, mid string
+		port      int
+	}{
+		{"audio", "0", 9},
+		{"video", "1", 9},
+		{"video", "2", 9},
+		{"audio", "3", 0},
+		{"application", "4", 9},
+	} {
+		mediaDescriptions = append(mediaDescriptions, (&sdp.MediaDescription{
+			MediaName: sdp.MediaName{Media: m.kind, Port: sdp.RangedPort{Value: m.port}},
+		}).WithValueAttribute(sdp.AttrKeyMID, m.mid))
+	}
+
+	assert.Equal(t, []string{"0", "1", "4"}, getMediaTransportMids(BundlePolicyBalanced, mediaDescriptions))
+	assert.Equal(t, []string{"0", "1", "2", "4"}, getMediaTransportMids(BundlePolicyMaxCompat, mediaDescriptions))
+	assert.Equal(t, []string{"0"}, getMediaTransportMids(BundlePolicyMaxBundle, mediaDescriptions))
+}
+
+func TestGetTransportMediaDescription(t *testing.T) {
+	first := (&sdp.MediaDescription{}).WithValueAttribute(sdp.AttrKeyMID, "0")
+	second := (&sdp.MediaDescription{}).WithValueAttribute(sdp.AttrKeyMID, "1")
+
+	desc := &sdp.SessionDescription{MediaDescriptions: []*sdp.MediaDescription{first, second}}
+	assert.Nil(t, getBundleGroup(desc))
+	assert.Equal(t, first, getTransportMediaDescription(desc))
+
+	desc = desc.WithValueAttribute(sdp.AttrKeyGroup, "BUNDLE 1 0")
+	assert.Equal(t, []string{"1", "0"}, getBundleGroup(desc))
+	assert.Equal(t, second, getTransportMediaDescription(desc))
+}
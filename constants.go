@@ -1,5 +1,7 @@
 package webrtc
 
+import "time"
+
 const (
 	// Unknown defines default public constant to use for "enum" like struct
 	// comparisons when no value was defined.
@@ -7,4 +9,16 @@ const (
 	unknownStr = "unknown"
 
 	receiveMTU = 8192
+
+	// undeclaredSSRCProbeCount is the number of packets read from an
+	// unsignaled SSRC while looking for the MID RTP header extension
+	undeclaredSSRCProbeCount = 10
+
+	// repairPacketsBufferSize is the number of unwrapped RTX packets that
+	// wait for the Track they retransmit to be read
+	repairPacketsBufferSize = 64
+
+	// senderReportInterval is how often a PeerConnection sends a RTCP Sender
+	// Report of every encoding it sends, unless the SettingEngine sets another interval
+	senderReportInterval = time.Second
 )
@@ -42,6 +42,9 @@ type DTLSTransport struct {
 	srtpEndpoint  *mux.Endpoint
 	srtcpEndpoint *mux.Endpoint
 
+	// The transport RTCP is sent and received on if it isn't multiplexed
+	rtcpTransport *DTLSTransport
+
 	dtlsMatcher mux.MatchFunc
 
 	api *API
@@ -192,6 +195,11 @@ func (t *DTLSTransport) getSRTPSession() (*srtp.SessionSRTP, error) {
 
 func (t *DTLSTransport) getSRTCPSession() (*srtp.SessionSRTCP, error) {
 	t.lock.RLock()
+	if t.rtcpTransport != nil {
+		rtcpTransport := t.rtcpTransport
+		t.lock.RUnlock()
+		return rtcpTransport.getSRTCPSession()
+	}
 	if t.srtcpSession != nil {
 		t.lock.RUnlock()
 		return t.srtcpSession, nil
@@ -205,6 +213,13 @@ func (t *DTLSTransport) getSRTCPSession() (*srtp.SessionSRTCP, error) {
 	return t.srtcpSession, nil
 }
 
+// setRTCPTransport moves RTCP to the transport of a separate RTCP component
+func (t *DTLSTransport) setRTCPTransport(rtcpTransport *DTLSTransport) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rtcpTransport = rtcpTransport
+}
+
 func (t *DTLSTransport) isClient() bool {
 	isClient := true
 	switch t.remoteParameters.Role {
@@ -268,16 +283,23 @@ func (t *DTLSTransport) Start(remoteParameters DTLSParameters) error {
 		}
 		t.conn = dtlsConn
 	}
-	t.onStateChange(DTLSTransportStateConnected)
 
-	// Check the fingerprint if a certificate was exchanged
+	// Check the fingerprint if a certificate was exchanged, the transport
+	// isn't connected until the remote is authenticated
 	remoteCert := t.conn.RemoteCertificate()
 	if remoteCert == nil {
+		t.onStateChange(DTLSTransportStateFailed)
 		return fmt.Errorf("peer didn't provide certificate via DTLS")
 	}
 
 	t.remoteCertificate = remoteCert.Raw
-	return t.validateFingerPrint(remoteParameters, remoteCert)
+	if err := t.validateFingerPrint(remoteParameters, remoteCert); err != nil {
+		t.onStateChange(DTLSTransportStateFailed)
+		return err
+	}
+
+	t.onStateChange(DTLSTransportStateConnected)
+	return nil
 }
 
 // Stop stops and closes the DTLSTransport object.
@@ -326,7 +348,7 @@ func (t *DTLSTransport) validateFingerPrint(remoteParameters DTLSParameters, rem
 		}
 	}
 
-	return errors.New("no matching fingerprint")
+	return ErrNoMatchingFingerprint
 }
 
 func (t *DTLSTransport) ensureICEConn() error {
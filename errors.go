@@ -83,4 +83,66 @@ var (
 	// ErrIncorrectSDPSemantics indicates that the PeerConnection was configured to
 	// generate SDP Answers with different SDP Semantics than the received Offer
 	ErrIncorrectSDPSemantics = errors.New("offer SDP semantics does not match configuration")
+
+	// ErrSenderNotCreatedByConnection indicates RemoveTrack was called with a
+	// RTPSender not created by this PeerConnection
+	ErrSenderNotCreatedByConnection = errors.New("RTPSender not created by this PeerConnection")
+
+	// ErrRTPSenderNewTrackHasIncorrectKind indicates that the new track is of a
+	// different kind than the previous/original track
+	ErrRTPSenderNewTrackHasIncorrectKind = errors.New("new track must be of the same kind as previous")
+
+	// ErrRTPSenderNewTrackHasIncorrectCodec indicates that the new track uses a
+	// codec that would require renegotiation to be sent by the RTPSender
+	ErrRTPSenderNewTrackHasIncorrectCodec = errors.New("new track must use the same codec as previous")
+
+	// ErrRTPTransceiverCodecUnsupported indicates that a codec passed to
+	// SetCodecPreferences is not registered in the MediaEngine for the kind
+	// of the RTPTransceiver
+	ErrRTPTransceiverCodecUnsupported = errors.New("unsupported codec type by this transceiver")
+
+	// ErrRTPSenderRIDNil indicates that a simulcast encoding was added to an
+	// RTPSender without a RID, or while its first encoding has none
+	ErrRTPSenderRIDNil = errors.New("every simulcast encoding must have a RID")
+
+	// ErrRTPSenderRIDCollision indicates that an encoding was added to an
+	// RTPSender with a RID that is already in use
+	ErrRTPSenderRIDCollision = errors.New("an encoding with this RID already exists")
+
+	// ErrRTPSenderEncodingsModified indicates that SetParameters tried to
+	// change anything other than the Active and MaxBitrate of the encodings
+	ErrRTPSenderEncodingsModified = errors.New("only Active and MaxBitrate of existing encodings can be modified")
+
+	// ErrRTCPMuxRequired indicates that a remote description doesn't multiplex
+	// RTP and RTCP while the RTCPMuxPolicy requires it
+	ErrRTCPMuxRequired = errors.New("the remote description doesn't multiplex RTCP")
+
+	// ErrNoMatchingFingerprint indicates that the certificate of the remote
+	// DTLS transport doesn't match any of the fingerprints it signaled.
+	ErrNoMatchingFingerprint = errors.New("no matching fingerprint")
+
+	// ErrIdentityProviderNotRegistered indicates that no IdentityProvider was
+	// registered for the domain of an identity provider.
+	ErrIdentityProviderNotRegistered = errors.New("identity provider not registered")
+
+	// ErrIdentityDomainMismatch indicates that an identity assertion is for an
+	// identity outside of the domain of the identity provider that validated it.
+	ErrIdentityDomainMismatch = errors.New("identity isn't of the domain of the identity provider")
+
+	// ErrIdentityFingerprintMismatch indicates that an identity assertion
+	// doesn't cover the fingerprints of the remote description.
+	ErrIdentityFingerprintMismatch = errors.New("identity assertion doesn't match the fingerprint")
+
+	// ErrNoIdentityAssertion indicates that a remote description has no
+	// identity assertion while the identity of the peer is required.
+	ErrNoIdentityAssertion = errors.New("remote description has no identity assertion")
+
+	// ErrPeerIdentityMismatch indicates that the identity of the remote peer
+	// isn't the PeerIdentity of the configuration, or changed.
+	ErrPeerIdentityMismatch = errors.New("remote identity doesn't match the peer identity")
+
+	// ErrMungedDescriptionInvalid indicates that a description munger or the
+	// application changed the m-lines, their mids, the BUNDLE group or the ICE
+	// and DTLS parameters.
+	ErrMungedDescriptionInvalid = errors.New("munged description changed the m-lines or transport parameters")
 )
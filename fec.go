@@ -0,0 +1,62 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strings"
+
+	"github.com/pion/sdp/v2"
+)
+
+// The mechanisms of RTPFecParameters
+// https://draft.ortc.org/#dom-rtcrtpfecparameters-mechanism
+const (
+	fecMechanismULPFEC  = "red+ulpfec"
+	fecMechanismFlexFEC = "flexfec"
+)
+
+// getFECSSRCs returns the FlexFEC SSRC of every SSRC of a m-line that has one,
+// they are grouped as FEC-FR
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-5.1.3
+func getFECSSRCs(media *sdp.MediaDescription) map[uint32]uint32 {
+	return getGroupedSSRCs(media, "FEC-FR")
+}
+
+// hasCodecName tells if a m-line has a codec with the name
+func hasCodecName(media *sdp.MediaDescription, name string) bool {
+	for _, attr := range media.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+		if split := strings.Fields(attr.Value); len(split) == 2 && strings.HasPrefix(strings.ToLower(split[1]), strings.ToLower(name)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// getFECMechanism returns how a m-line with the codecs offers or accepts to
+// protect a stream, FlexFEC is preferred if there is a SSRC to send it with.
+// An answer only uses a mechanism the offer in remoteMedia did as well
+func getFECMechanism(codecs []*RTPCodec, remoteMedia *sdp.MediaDescription, fecSSRC uint32) string {
+	supports := func(name string) bool {
+		if remoteMedia != nil && !hasCodecName(remoteMedia, name) {
+			return false
+		}
+		for _, codec := range codecs {
+			if strings.EqualFold(codec.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case fecSSRC != 0 && supports(FlexFEC03):
+		return fecMechanismFlexFEC
+	case supports(RED) && supports(ULPFEC):
+		return fecMechanismULPFEC
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,42 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/sdp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFECSSRCs(t *testing.T) {
+	media := (&sdp.MediaDescription{}).
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FID 1 2").
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FEC-FR 1 3")
+
+	assert.Equal(t, map[uint32]uint32{1: 3}, getFECSSRCs(media))
+}
+
+func TestGetFECMechanism(t *testing.T) {
+	vp8 := NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000)
+	red := NewRTPREDCodec(DefaultPayloadTypeRED, 90000)
+	ulpfec := NewRTPULPFECCodec(DefaultPayloadTypeULPFEC, 90000)
+	flexfec := NewRTPFlexFEC03Codec(DefaultPayloadTypeFlexFEC03, 90000)
+	all := []*RTPCodec{vp8, red, ulpfec, flexfec}
+
+	// Offers use what is registered, FlexFEC needs a SSRC
+	assert.Equal(t, fecMechanismFlexFEC, getFECMechanism(all, nil, 1))
+	assert.Equal(t, fecMechanismULPFEC, getFECMechanism(all, nil, 0))
+	assert.Equal(t, "", getFECMechanism([]*RTPCodec{vp8, red}, nil, 1))
+
+	// Answers use what the offer has as well
+	remoteMedia := (&sdp.MediaDescription{}).
+		WithCodec(96, "VP8", 90000, 0, "").
+		WithCodec(100, "RED", 90000, 0, "").
+		WithCodec(101, "ulpfec", 90000, 0, "")
+	assert.Equal(t, fecMechanismULPFEC, getFECMechanism(all, remoteMedia, 1))
+	assert.Equal(t, "", getFECMechanism([]*RTPCodec{vp8, flexfec}, remoteMedia, 1))
+
+	remoteMedia = remoteMedia.WithCodec(102, "flexfec-03", 90000, 0, "repair-window=10000000")
+	assert.Equal(t, fecMechanismFlexFEC, getFECMechanism(all, remoteMedia, 1))
+}
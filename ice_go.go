@@ -2,13 +2,16 @@
 
 package webrtc
 
-import "github.com/pion/sdp/v2"
+import (
+	"github.com/pion/ice"
+	"github.com/pion/sdp/v2"
+)
 
 // NewICEGatherer creates a new NewICEGatherer.
 // This constructor is part of the ORTC API. It is not
 // meant to be used together with the basic WebRTC API.
 func (api *API) NewICEGatherer(opts ICEGatherOptions) (*ICEGatherer, error) {
-	return NewICEGatherer(
+	g, err := NewICEGatherer(
 		api.settingEngine.ephemeralUDP.PortMin,
 		api.settingEngine.ephemeralUDP.PortMax,
 		api.settingEngine.timeout.ICEConnection,
@@ -23,6 +26,17 @@ func (api *API) NewICEGatherer(opts ICEGatherOptions) (*ICEGatherer, error) {
 		api.settingEngine.candidates.ICENetworkTypes,
 		opts,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	if api.settingEngine.candidates.ICELite {
+		// An ICE-lite agent only has host candidates
+		// https://tools.ietf.org/html/rfc8445#section-5.1.1
+		g.lite = true
+		g.candidateTypes = []ice.CandidateType{ice.CandidateTypeHost}
+	}
+	return g, nil
 }
 
 // NewICETransport creates a new NewICETransport.
@@ -19,6 +19,10 @@ type ICECandidate struct {
 	Component      uint16           `json:"component"`
 	RelatedAddress string           `json:"relatedAddress"`
 	RelatedPort    uint16           `json:"relatedPort"`
+
+	// The mid of the m-line the candidate was gathered for, if the m-line
+	// isn't bundled on the transports of the PeerConnection
+	sdpMid string
 }
 
 // Conversion for package ice
@@ -157,8 +161,13 @@ func iceCandidateToSDP(c ICECandidate) sdp.ICECandidate {
 // as indicated by the spec https://w3c.github.io/webrtc-pc/#dom-rtcicecandidate-tojson
 func (c ICECandidate) ToJSON() ICECandidateInit {
 	var sdpmLineIndex uint16
-	return ICECandidateInit{
+	init := ICECandidateInit{
 		Candidate:     fmt.Sprintf("candidate:%s", iceCandidateToSDP(c).Marshal()),
 		SDPMLineIndex: &sdpmLineIndex,
 	}
+	if c.sdpMid != "" {
+		sdpMid := c.sdpMid
+		init.SDPMid = &sdpMid
+	}
+	return init
 }
@@ -3,8 +3,10 @@
 package webrtc
 
 import (
+	"reflect"
 	"sync"
 	"time"
+	"unsafe"
 
 	"github.com/pion/ice"
 	"github.com/pion/logging"
@@ -21,8 +23,18 @@ type ICEGatherer struct {
 	validatedServers []*ice.URL
 
 	agentIsTrickle bool
+	lite           bool
 	agent          *ice.Agent
 
+	// previousAgent is the agent an ICE restart replaced, until the restart
+	// is completed or rolled back
+	previousAgent *ice.Agent
+
+	// rtpGatherer is set if the ICEGatherer gathers for the RTCP component,
+	// its agent then has the local credentials of the one of rtpGatherer
+	// https://tools.ietf.org/html/rfc8445#section-5.3
+	rtpGatherer *ICEGatherer
+
 	portMin                   uint16
 	portMax                   uint16
 	candidateTypes            []ice.CandidateType
@@ -131,6 +143,17 @@ func (g *ICEGatherer) createAgent() error {
 		return err
 	}
 
+	if g.rtpGatherer != nil {
+		params, err := g.rtpGatherer.GetLocalParameters()
+		if err != nil {
+			if closeErr := agent.Close(); closeErr != nil {
+				g.log.Warnf("Failed to close agent: %s", closeErr)
+			}
+			return err
+		}
+		setAgentCredentials(agent, params.UsernameFragment, params.Password)
+	}
+
 	g.agent = agent
 	if !g.agentIsTrickle {
 		g.state = ICEGathererStateComplete
@@ -167,6 +190,7 @@ func (g *ICEGatherer) Gather() error {
 				g.log.Warnf("Failed to convert ice.Candidate: %s", err)
 				return
 			}
+			g.setComponent([]ICECandidate{c})
 			onLocalCandidateHdlr(&c)
 		} else {
 			g.setState(ICEGathererStateComplete)
@@ -178,6 +202,48 @@ func (g *ICEGatherer) Gather() error {
 	return agent.GatherCandidates()
 }
 
+// restart replaces the agent with a new one, which has new local credentials
+// and gathers its candidates again. The ICETransport keeps using and is
+// responsible for closing the previous agent.
+func (g *ICEGatherer) restart() error {
+	g.lock.Lock()
+	g.previousAgent = g.agent
+	g.agent = nil
+	g.state = ICEGathererStateNew
+	g.lock.Unlock()
+
+	return g.createAgent()
+}
+
+// completeRestart keeps the agent of an ICE restart, the ICETransport
+// closes the previous agent once it moved over
+func (g *ICEGatherer) completeRestart() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.previousAgent = nil
+}
+
+// rollbackRestart closes the agent of an ICE restart that is rolled back,
+// and goes back to the previous agent and its candidates
+func (g *ICEGatherer) rollbackRestart() error {
+	g.lock.Lock()
+	if g.previousAgent == nil {
+		g.lock.Unlock()
+		return nil
+	}
+
+	agent := g.agent
+	g.agent = g.previousAgent
+	g.previousAgent = nil
+	g.state = ICEGathererStateComplete
+	g.lock.Unlock()
+
+	if agent == nil {
+		return nil
+	}
+	return agent.Close()
+}
+
 // Close prunes all local candidates, and closes the ports.
 func (g *ICEGatherer) Close() error {
 	g.lock.Lock()
@@ -206,7 +272,7 @@ func (g *ICEGatherer) GetLocalParameters() (ICEParameters, error) {
 	return ICEParameters{
 		UsernameFragment: frag,
 		Password:         pwd,
-		ICELite:          false,
+		ICELite:          g.lite,
 	}, nil
 }
 
@@ -220,7 +286,33 @@ func (g *ICEGatherer) GetLocalCandidates() ([]ICECandidate, error) {
 		return nil, err
 	}
 
-	return newICECandidatesFromICE(iceCandidates)
+	candidates, err := newICECandidatesFromICE(iceCandidates)
+	if err != nil {
+		return nil, err
+	}
+	g.setComponent(candidates)
+	return candidates, nil
+}
+
+// setComponent marks the candidates of an ICEGatherer that gathers for the
+// RTCP component, the agent gathers all candidates for the RTP component
+func (g *ICEGatherer) setComponent(candidates []ICECandidate) {
+	if g.rtpGatherer == nil {
+		return
+	}
+	for i := range candidates {
+		candidates[i].Component = uint16(ICEComponentRTCP)
+	}
+}
+
+// setAgentCredentials replaces the local credentials of an agent. All components
+// of a media stream share them, but pion/ice can't be configured with them yet
+func setAgentCredentials(agent *ice.Agent, ufrag, pwd string) {
+	v := reflect.ValueOf(agent).Elem()
+	for name, value := range map[string]string{"localUfrag": ufrag, "localPwd": pwd} {
+		field := v.FieldByName(name)
+		reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().SetString(value)
+	}
 }
 
 // OnLocalCandidate sets an event handler which fires when a new local ICE candidate is available
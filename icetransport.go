@@ -5,6 +5,7 @@ package webrtc
 import (
 	"context"
 	"errors"
+	"net"
 	"sync"
 
 	"github.com/pion/ice"
@@ -28,6 +29,7 @@ type ICETransport struct {
 	state ICETransportState
 
 	gatherer *ICEGatherer
+	agent    *ice.Agent
 	conn     *ice.Conn
 	mux      *mux.Mux
 
@@ -79,27 +81,15 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 		return err
 	}
 
-	agent := t.gatherer.agent
-	if err := agent.OnConnectionStateChange(func(iceState ice.ConnectionState) {
-		state := newICETransportStateFromICE(iceState)
-		t.lock.Lock()
-		t.state = state
-		t.lock.Unlock()
-
-		t.onConnectionStateChange(state)
-	}); err != nil {
-		return err
+	agent := t.gatherer.getAgent()
+	if agent == nil {
+		return errors.New("ICEAgent does not exist, unable to start ICETransport")
 	}
-	if err := agent.OnSelectedCandidatePairChange(func(local, remote ice.Candidate) {
-		candidates, err := newICECandidatesFromICE([]ice.Candidate{local, remote})
-		if err != nil {
-			t.log.Warnf("Unable to convert ICE candidates to ICECandidates: %s", err)
-			return
-		}
-		t.onSelectedCandidatePairChange(NewICECandidatePair(&candidates[0], &candidates[1]))
-	}); err != nil {
+
+	if err := t.handleAgent(agent); err != nil {
 		return err
 	}
+	t.agent = agent
 
 	if role == nil {
 		controlled := ICERoleControlled
@@ -111,22 +101,7 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 	// added so that the agent can complete a connection
 	t.lock.Unlock()
 
-	var iceConn *ice.Conn
-	var err error
-	switch *role {
-	case ICERoleControlling:
-		iceConn, err = agent.Dial(context.TODO(),
-			params.UsernameFragment,
-			params.Password)
-
-	case ICERoleControlled:
-		iceConn, err = agent.Accept(context.TODO(),
-			params.UsernameFragment,
-			params.Password)
-
-	default:
-		err = errors.New("unknown ICE Role")
-	}
+	iceConn, err := connectAgent(agent, params, *role)
 
 	// Reacquire the lock to set the connection/mux
 	t.lock.Lock()
@@ -146,12 +121,126 @@ func (t *ICETransport) Start(gatherer *ICEGatherer, params ICEParameters, role *
 	return nil
 }
 
+// startConn starts the ICETransport on a connection that was established
+// without its ICEGatherer, like the one of a separate RTCP component
+func (t *ICETransport) startConn(conn net.Conn, role ICERole) {
+	t.lock.Lock()
+	t.role = role
+	t.mux = mux.NewMux(mux.Config{
+		Conn:          conn,
+		BufferSize:    receiveMTU,
+		LoggerFactory: t.loggerFactory,
+	})
+	t.state = ICETransportStateConnected
+	t.lock.Unlock()
+
+	t.onConnectionStateChange(ICETransportStateConnected)
+}
+
+// restart connects the agent the ICEGatherer created for an ICE restart and
+// moves all traffic over to it once it is connected. The previous agent is
+// closed afterwards, so the DTLS and SRTP sessions above stay alive.
+func (t *ICETransport) restart(params ICEParameters) error {
+	t.lock.Lock()
+	if t.mux == nil {
+		t.lock.Unlock()
+		return errors.New("ICETransport has not been started")
+	}
+
+	agent := t.gatherer.getAgent()
+	if agent == nil || agent == t.agent {
+		t.lock.Unlock()
+		return errors.New("ICEGatherer has not been restarted")
+	}
+
+	if err := t.handleAgent(agent); err != nil {
+		t.lock.Unlock()
+		return err
+	}
+	role := t.role
+	t.lock.Unlock()
+
+	iceConn, err := connectAgent(agent, params, role)
+	if err != nil {
+		return err
+	}
+
+	t.lock.Lock()
+	previousConn := t.conn
+	t.agent = agent
+	t.conn = iceConn
+	t.mux.SetConn(iceConn)
+	t.state = ICETransportStateConnected
+	t.lock.Unlock()
+
+	t.onConnectionStateChange(ICETransportStateConnected)
+	return previousConn.Close()
+}
+
+// handleAgent forwards the events of agent, once it has been replaced by
+// an ICE restart they are ignored.
+func (t *ICETransport) handleAgent(agent *ice.Agent) error {
+	if err := agent.OnConnectionStateChange(func(iceState ice.ConnectionState) {
+		state := newICETransportStateFromICE(iceState)
+		t.lock.Lock()
+		if t.agent != agent {
+			t.lock.Unlock()
+			return
+		}
+		t.state = state
+		t.lock.Unlock()
+
+		t.onConnectionStateChange(state)
+	}); err != nil {
+		return err
+	}
+
+	return agent.OnSelectedCandidatePairChange(func(local, remote ice.Candidate) {
+		t.lock.RLock()
+		isCurrent := t.agent == agent
+		t.lock.RUnlock()
+		if !isCurrent {
+			return
+		}
+
+		candidates, err := newICECandidatesFromICE([]ice.Candidate{local, remote})
+		if err != nil {
+			t.log.Warnf("Unable to convert ICE candidates to ICECandidates: %s", err)
+			return
+		}
+		t.onSelectedCandidatePairChange(NewICECandidatePair(&candidates[0], &candidates[1]))
+	})
+}
+
+func connectAgent(agent *ice.Agent, params ICEParameters, role ICERole) (*ice.Conn, error) {
+	switch role {
+	case ICERoleControlling:
+		return agent.Dial(context.TODO(),
+			params.UsernameFragment,
+			params.Password)
+
+	case ICERoleControlled:
+		return agent.Accept(context.TODO(),
+			params.UsernameFragment,
+			params.Password)
+
+	default:
+		return nil, errors.New("unknown ICE Role")
+	}
+}
+
 // Stop irreversibly stops the ICETransport.
 func (t *ICETransport) Stop() error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
 	if t.mux != nil {
+		// An agent created for an ICE restart that hasn't connected yet
+		if t.gatherer != nil && t.gatherer.getAgent() != t.agent {
+			if err := t.gatherer.Close(); err != nil {
+				return err
+			}
+		}
 		return t.mux.Close()
 	} else if t.gatherer != nil {
 		return t.gatherer.Close()
@@ -0,0 +1,208 @@
+// +build !js
+
+package webrtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/pion/sdp/v2"
+)
+
+var errInvalidLocalIdentityAssertion = errors.New("assertion isn't signed with the secret")
+
+// IdentityProvider generates and validates the identity assertions that bind
+// the DTLS fingerprints of a PeerConnection to the identity of its user. It is
+// registered for its domain with SettingEngine.RegisterIdentityProvider.
+// https://www.w3.org/TR/webrtc-identity/#identity-provider-interaction
+type IdentityProvider interface {
+	// GenerateAssertion asserts the identity of the local user for contents,
+	// the fingerprints of the local PeerConnection.
+	GenerateAssertion(contents string) (assertion string, err error)
+
+	// ValidateAssertion verifies an assertion of a remote peer and returns the
+	// identity and the contents it was generated for.
+	ValidateAssertion(assertion string) (identity string, contents string, err error)
+}
+
+// IdentityAssertion is the identity of the remote peer once its assertion
+// was validated.
+// https://www.w3.org/TR/webrtc-identity/#rtcidentityassertion-interface
+type IdentityAssertion struct {
+	// IdP is the domain of the identity provider that validated the identity
+	IdP string
+
+	// Name is the identity of the peer, like alice@example.org
+	Name string
+}
+
+// identityProviderProtocol is the only protocol an IdentityProvider is
+// registered with, it is signaled nevertheless
+const identityProviderProtocol = "default"
+
+// identityAssertion is the JSON carried base64 encoded by a=identity
+// https://tools.ietf.org/html/rfc8827#section-7.4
+type identityAssertion struct {
+	IdP struct {
+		Domain   string `json:"domain"`
+		Protocol string `json:"protocol"`
+	} `json:"idp"`
+	Assertion string `json:"assertion"`
+}
+
+// identityContents is what an assertion is generated for
+// https://tools.ietf.org/html/rfc8827#section-7.4
+type identityContents struct {
+	Fingerprint []identityFingerprint `json:"fingerprint"`
+}
+
+type identityFingerprint struct {
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+// newIdentityAttribute generates an assertion for the fingerprints of a
+// description and returns the value of its a=identity
+func newIdentityAttribute(domain string, provider IdentityProvider, desc *sdp.SessionDescription) (string, error) {
+	contents, err := json.Marshal(identityContents{Fingerprint: getIdentityFingerprints(desc)})
+	if err != nil {
+		return "", err
+	}
+
+	assertion := identityAssertion{}
+	assertion.IdP.Domain = domain
+	assertion.IdP.Protocol = identityProviderProtocol
+	if assertion.Assertion, err = provider.GenerateAssertion(string(contents)); err != nil {
+		return "", err
+	}
+
+	value, err := json.Marshal(assertion)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(value), nil
+}
+
+// validateIdentityAttribute validates the a=identity of a remote description
+// with the IdentityProvider registered for its domain. The assertion has to be
+// for an identity of that domain and cover every fingerprint of the description.
+// https://www.w3.org/TR/webrtc-identity/#verifying-identity-assertions
+func validateIdentityAttribute(providers map[string]IdentityProvider, value string, desc *sdp.SessionDescription) (*IdentityAssertion, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	assertion := identityAssertion{}
+	if err = json.Unmarshal(raw, &assertion); err != nil {
+		return nil, err
+	}
+
+	provider, ok := providers[assertion.IdP.Domain]
+	if !ok {
+		return nil, ErrIdentityProviderNotRegistered
+	}
+	identity, rawContents, err := provider.ValidateAssertion(assertion.Assertion)
+	if err != nil {
+		return nil, err
+	} else if !strings.HasSuffix(identity, "@"+assertion.IdP.Domain) {
+		return nil, ErrIdentityDomainMismatch
+	}
+
+	contents := identityContents{}
+	if err = json.Unmarshal([]byte(rawContents), &contents); err != nil {
+		return nil, err
+	}
+	for _, fingerprint := range getIdentityFingerprints(desc) {
+		found := false
+		for _, asserted := range contents.Fingerprint {
+			if strings.EqualFold(fingerprint.Algorithm, asserted.Algorithm) && strings.EqualFold(fingerprint.Digest, asserted.Digest) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrIdentityFingerprintMismatch
+		}
+	}
+
+	return &IdentityAssertion{IdP: assertion.IdP.Domain, Name: identity}, nil
+}
+
+// getIdentityFingerprints returns every fingerprint of a description, at session
+// level and in the m-lines
+func getIdentityFingerprints(desc *sdp.SessionDescription) []identityFingerprint {
+	fingerprints := []identityFingerprint{}
+	attributes := append([]sdp.Attribute{}, desc.Attributes...)
+	for _, media := range desc.MediaDescriptions {
+		attributes = append(attributes, media.Attributes...)
+	}
+	for _, attr := range attributes {
+		if attr.Key != "fingerprint" {
+			continue
+		}
+		if parts := strings.Split(attr.Value, " "); len(parts) == 2 {
+			fingerprints = append(fingerprints, identityFingerprint{Algorithm: parts[0], Digest: parts[1]})
+		}
+	}
+	return fingerprints
+}
+
+// LocalIdentityProvider is an IdentityProvider that signs assertions with a
+// secret shared by both peers instead of contacting an identity provider. It
+// stands in for a real one in tests and closed deployments.
+type LocalIdentityProvider struct {
+	// Identity is asserted for the local user, like alice@example.org
+	Identity string
+
+	// Secret signs and verifies the assertions
+	Secret []byte
+}
+
+type localIdentityAssertion struct {
+	Identity string `json:"identity"`
+	Contents string `json:"contents"`
+}
+
+// GenerateAssertion signs the identity and contents with the secret
+func (p *LocalIdentityProvider) GenerateAssertion(contents string) (string, error) {
+	payload, err := json.Marshal(localIdentityAssertion{Identity: p.Identity, Contents: contents})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+// ValidateAssertion verifies the signature of an assertion generated with the same secret
+func (p *LocalIdentityProvider) ValidateAssertion(assertion string) (string, string, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 2 {
+		return "", "", errInvalidLocalIdentityAssertion
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	if !hmac.Equal(signature, p.sign(payload)) {
+		return "", "", errInvalidLocalIdentityAssertion
+	}
+
+	decoded := localIdentityAssertion{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return "", "", err
+	}
+	return decoded.Identity, decoded.Contents, nil
+}
+
+func (p *LocalIdentityProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.Secret)
+	mac.Write(payload) // nolint:errcheck
+	return mac.Sum(nil)
+}
@@ -0,0 +1,42 @@
+// +build !js
+
+package webrtc
+
+import (
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+	"github.com/pion/webrtc/v2/pkg/interceptor/fec"
+	"github.com/pion/webrtc/v2/pkg/interceptor/nack"
+)
+
+// RegisterDefaultInterceptors registers the Interceptors NewPeerConnection uses,
+// for now NACK based retransmission
+func RegisterDefaultInterceptors(r *interceptor.Registry) {
+	ConfigureNack(r)
+}
+
+// ConfigureNack registers the Interceptors that retransmit lost packets of
+// streams that negotiated generic NACK. NACKs of a remote peer are only
+// answered while RTCP is read from the RTPSender
+func ConfigureNack(r *interceptor.Registry) {
+	r.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		return nack.NewResponderInterceptor()
+	}))
+	r.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		return nack.NewGeneratorInterceptor()
+	}))
+}
+
+// ConfigureFEC registers the Interceptors that protect video with forward error
+// correction, if RED and ULPFEC or FlexFEC-03 codecs are registered in the
+// MediaEngine. protectionRatio is how many FEC packets are sent per media packet.
+// ULPFEC packets take sequence numbers of the stream they protect, so NACKs only
+// find the packets they ask for if ConfigureFEC is called before ConfigureNack
+// or RegisterDefaultInterceptors
+func ConfigureFEC(r *interceptor.Registry, protectionRatio float64) {
+	r.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		return fec.NewEncoderInterceptor(fec.EncoderProtectionRatio(protectionRatio))
+	}))
+	r.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		return fec.NewDecoderInterceptor()
+	}))
+}
@@ -64,6 +64,21 @@ func (m *Mux) NewEndpoint(f MatchFunc) *Endpoint {
 	return e
 }
 
+// SetConn replaces the Conn the Mux reads from and writes to. The previous
+// Conn isn't read from anymore once it returns an error, closing it is up to
+// the caller.
+func (m *Mux) SetConn(conn net.Conn) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.nextConn = conn
+}
+
+func (m *Mux) getConn() net.Conn {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.nextConn
+}
+
 // RemoveEndpoint removes an endpoint from the Mux
 func (m *Mux) RemoveEndpoint(e *Endpoint) {
 	m.lock.Lock()
@@ -84,7 +99,7 @@ func (m *Mux) Close() error {
 	}
 	m.lock.Unlock()
 
-	err := m.nextConn.Close()
+	err := m.getConn().Close()
 	if err != nil {
 		return err
 	}
@@ -102,8 +117,13 @@ func (m *Mux) readLoop() {
 
 	buf := make([]byte, m.bufferSize)
 	for {
-		n, err := m.nextConn.Read(buf)
+		conn := m.getConn()
+		n, err := conn.Read(buf)
 		if err != nil {
+			// Keep reading if the Conn has been replaced
+			if conn != m.getConn() {
+				continue
+			}
 			return
 		}
 
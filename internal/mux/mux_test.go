@@ -102,3 +102,63 @@ func TestNoEndpoints(t *testing.T) {
 	}
 
 }
+
+func TestSetConn(t *testing.T) {
+	lim := test.TimeOut(time.Second * 5)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	ca, cb := net.Pipe()
+	m := NewMux(Config{
+		Conn:          ca,
+		BufferSize:    8192,
+		LoggerFactory: logging.NewDefaultLoggerFactory(),
+	})
+	e := m.NewEndpoint(func([]byte) bool {
+		return true
+	})
+
+	// Replace the Conn, reading continues after the previous one is closed
+	newCa, newCb := net.Pipe()
+	m.SetConn(newCa)
+	if err := ca.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		if _, err := newCb.Write([]byte{0x01}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	buf := make([]byte, 1)
+	if _, err := e.Read(buf); err != nil {
+		t.Fatal(err)
+	} else if buf[0] != 0x01 {
+		t.Fatalf("unexpected packet %v", buf)
+	}
+
+	go func() {
+		if _, err := e.Write([]byte{0x02}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if _, err := newCb.Read(buf); err != nil {
+		t.Fatal(err)
+	} else if buf[0] != 0x02 {
+		t.Fatalf("unexpected packet %v", buf)
+	}
+
+	if err := newCb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -19,6 +19,16 @@ const (
 	DefaultPayloadTypeVP8  = 96
 	DefaultPayloadTypeVP9  = 98
 	DefaultPayloadTypeH264 = 102
+
+	// Retransmissions of the default video codecs
+	DefaultPayloadTypeRTXVP8  = 97
+	DefaultPayloadTypeRTXVP9  = 99
+	DefaultPayloadTypeRTXH264 = 103
+
+	// Forward error correction of video, these codecs aren't registered by default
+	DefaultPayloadTypeRED       = 116
+	DefaultPayloadTypeULPFEC    = 117
+	DefaultPayloadTypeFlexFEC03 = 118
 )
 
 // MediaEngine defines the codecs supported by a PeerConnection
@@ -38,8 +48,11 @@ func (m *MediaEngine) RegisterDefaultCodecs() {
 	m.RegisterCodec(NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000))
 	m.RegisterCodec(NewRTPG722Codec(DefaultPayloadTypeG722, 8000))
 	m.RegisterCodec(NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000))
+	m.RegisterCodec(NewRTPRTXCodec(RTPCodecTypeVideo, DefaultPayloadTypeRTXVP8, 90000, DefaultPayloadTypeVP8))
 	m.RegisterCodec(NewRTPH264Codec(DefaultPayloadTypeH264, 90000))
+	m.RegisterCodec(NewRTPRTXCodec(RTPCodecTypeVideo, DefaultPayloadTypeRTXH264, 90000, DefaultPayloadTypeH264))
 	m.RegisterCodec(NewRTPVP9Codec(DefaultPayloadTypeVP9, 90000))
+	m.RegisterCodec(NewRTPRTXCodec(RTPCodecTypeVideo, DefaultPayloadTypeRTXVP9, 90000, DefaultPayloadTypeVP9))
 }
 
 // PopulateFromSDP finds all codecs in a session description and adds them to a MediaEngine, using dynamic
@@ -67,6 +80,8 @@ func (m *MediaEngine) PopulateFromSDP(sd SessionDescription) error {
 			switch payloadCodec.Name {
 			case G722:
 				codec = NewRTPG722Codec(payloadType, clockRate)
+			case CN:
+				codec = NewRTPCNCodec(payloadType, clockRate)
 			case Opus:
 				codec = NewRTPOpusCodec(payloadType, clockRate)
 			case VP8:
@@ -78,16 +93,46 @@ func (m *MediaEngine) PopulateFromSDP(sd SessionDescription) error {
 			case H264:
 				codec = NewRTPH264Codec(payloadType, clockRate)
 				codec.SDPFmtpLine = parameters
+			case RTX:
+				codec = NewRTPRTXCodec(NewRTPCodecType(md.MediaName.Media), payloadType, clockRate, 0)
+				codec.SDPFmtpLine = parameters
+			case RED:
+				codec = NewRTPREDCodec(payloadType, clockRate)
+			case ULPFEC:
+				codec = NewRTPULPFECCodec(payloadType, clockRate)
+			case FlexFEC03:
+				codec = NewRTPFlexFEC03Codec(payloadType, clockRate)
+				codec.SDPFmtpLine = parameters
 			default:
 				// ignoring other codecs
 				continue
 			}
+			codec.RTCPFeedback = getRTCPFeedback(md, payloadType)
 			m.RegisterCodec(codec)
 		}
 	}
 	return nil
 }
 
+// getRTCPFeedback returns the RTCP feedback a m-line offers for a payload type,
+// "a=rtcp-fb:* <type>" applies to all of them
+// https://tools.ietf.org/html/rfc4585#section-4.2
+func getRTCPFeedback(md *sdp.MediaDescription, payloadType uint8) []RTCPFeedback {
+	feedback := []RTCPFeedback{}
+	for _, attr := range md.Attributes {
+		if attr.Key != "rtcp-fb" {
+			continue
+		}
+
+		fields := strings.Fields(attr.Value)
+		if len(fields) < 2 || (fields[0] != "*" && fields[0] != strconv.Itoa(int(payloadType))) {
+			continue
+		}
+		feedback = append(feedback, RTCPFeedback{Type: fields[1], Parameter: strings.Join(fields[2:], " ")})
+	}
+	return feedback
+}
+
 func (m *MediaEngine) getCodec(payloadType uint8) (*RTPCodec, error) {
 	for _, codec := range m.codecs {
 		if codec.PayloadType == payloadType {
@@ -97,6 +142,26 @@ func (m *MediaEngine) getCodec(payloadType uint8) (*RTPCodec, error) {
 	return nil, ErrCodecNotFound
 }
 
+// getRTXCodec returns the RTX codec that retransmits the codec with the payload type
+func (m *MediaEngine) getRTXCodec(payloadType uint8) *RTPCodec {
+	for _, codec := range m.codecs {
+		if apt, ok := codec.getAssociatedPayloadType(); ok && apt == payloadType {
+			return codec
+		}
+	}
+	return nil
+}
+
+// getCodecByName returns the first codec of a kind with the name
+func (m *MediaEngine) getCodecByName(kind RTPCodecType, name string) *RTPCodec {
+	for _, codec := range m.codecs {
+		if codec.Type == kind && strings.EqualFold(codec.Name, name) {
+			return codec
+		}
+	}
+	return nil
+}
+
 func (m *MediaEngine) getCodecSDP(sdpCodec sdp.Codec) (*RTPCodec, error) {
 	for _, codec := range m.codecs {
 		if codec.Name == sdpCodec.Name &&
@@ -130,6 +195,29 @@ const (
 	H264 = "H264"
 )
 
+// CN is the name of the comfort noise codec, it isn't registered by default and
+// is not advertised when DisableVoiceActivityDetection is set
+// https://tools.ietf.org/html/rfc3389
+const CN = "CN"
+
+// DefaultPayloadTypeCN is the static payload type of comfort noise at 8000 Hz
+const DefaultPayloadTypeCN = 13
+
+// RTX is the name of the retransmission codec, every RTX codec carries the
+// retransmissions of the codec its apt parameter points to
+// https://tools.ietf.org/html/rfc4588#section-8.6
+const RTX = "rtx"
+
+// Names of the codecs video is protected with by forward error correction. ULPFEC
+// packets are sent within RED packets, FlexFEC packets are sent on a SSRC of their own
+// https://tools.ietf.org/html/rfc5109
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03
+const (
+	RED       = "red"
+	ULPFEC    = "ulpfec"
+	FlexFEC03 = "flexfec-03"
+)
+
 // NewRTPG722Codec is a helper to create a G722 codec
 func NewRTPG722Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 	c := NewRTPCodec(RTPCodecTypeAudio,
@@ -142,6 +230,18 @@ func NewRTPG722Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 	return c
 }
 
+// NewRTPCNCodec is a helper to create a comfort noise codec
+func NewRTPCNCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeAudio,
+		CN,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
 // NewRTPOpusCodec is a helper to create an Opus codec
 func NewRTPOpusCodec(payloadType uint8, clockrate uint32) *RTPCodec {
 	c := NewRTPCodec(RTPCodecTypeAudio,
@@ -163,6 +263,7 @@ func NewRTPVP8Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 		"",
 		payloadType,
 		&codecs.VP8Payloader{})
+	c.RTCPFeedback = []RTCPFeedback{{Type: TypeRTCPFBNACK}}
 	return c
 }
 
@@ -175,6 +276,7 @@ func NewRTPVP9Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 		"",
 		payloadType,
 		nil) // pion/webrtc#755
+	c.RTCPFeedback = []RTCPFeedback{{Type: TypeRTCPFBNACK}}
 	return c
 }
 
@@ -187,6 +289,55 @@ func NewRTPH264Codec(payloadType uint8, clockrate uint32) *RTPCodec {
 		"level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f",
 		payloadType,
 		&codecs.H264Payloader{})
+	c.RTCPFeedback = []RTCPFeedback{{Type: TypeRTCPFBNACK}}
+	return c
+}
+
+// NewRTPRTXCodec is a helper to create a RTX codec that retransmits the codec with payload type apt
+func NewRTPRTXCodec(codecType RTPCodecType, payloadType uint8, clockrate uint32, apt uint8) *RTPCodec {
+	c := NewRTPCodec(codecType,
+		RTX,
+		clockrate,
+		0,
+		fmt.Sprintf("apt=%d", apt),
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTPREDCodec is a helper to create a RED codec, that ULPFEC packets are sent with
+func NewRTPREDCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		RED,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTPULPFECCodec is a helper to create an ULPFEC codec, it needs a RED codec as well
+func NewRTPULPFECCodec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		ULPFEC,
+		clockrate,
+		0,
+		"",
+		payloadType,
+		nil)
+	return c
+}
+
+// NewRTPFlexFEC03Codec is a helper to create a FlexFEC-03 codec
+func NewRTPFlexFEC03Codec(payloadType uint8, clockrate uint32) *RTPCodec {
+	c := NewRTPCodec(RTPCodecTypeVideo,
+		FlexFEC03,
+		clockrate,
+		0,
+		"repair-window=10000000",
+		payloadType,
+		nil)
 	return c
 }
 
@@ -234,6 +385,14 @@ type RTPCodec struct {
 	Payloader   rtp.Payloader
 }
 
+// getAssociatedPayloadType returns the payload type a RTX codec retransmits
+func (c *RTPCodec) getAssociatedPayloadType() (uint8, bool) {
+	if !strings.EqualFold(c.Name, RTX) {
+		return 0, false
+	}
+	return parseAssociatedPayloadType(c.SDPFmtpLine)
+}
+
 // NewRTPCodec is used to define a new codec
 func NewRTPCodec(
 	codecType RTPCodecType,
@@ -35,3 +35,64 @@ func TestCodecRegistration(t *testing.T) {
 	_, err := api.mediaEngine.getCodecSDP(sdp.Codec{PayloadType: invalidPT})
 	assert.Equal(t, err, ErrCodecNotFound)
 }
+
+func TestPopulateFromSDP_RTCPFeedback(t *testing.T) {
+	const offer = `v=0
+o=- 4596489990601351948 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+m=video 60323 UDP/TLS/RTP/SAVPF 96 98 99
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtcp-fb:* ccm fir
+a=rtpmap:98 VP9/90000
+a=rtpmap:99 rtx/90000
+a=fmtp:99 apt=98
+`
+
+	m := MediaEngine{}
+	assert.NoError(t, m.PopulateFromSDP(SessionDescription{Type: SDPTypeOffer, SDP: offer}))
+
+	vp8, err := m.getCodec(96)
+	assert.NoError(t, err)
+	assert.Equal(t, []RTCPFeedback{{Type: TypeRTCPFBNACK}, {Type: TypeRTCPFBNACK, Parameter: "pli"}, {Type: TypeRTCPFBCCM, Parameter: "fir"}}, vp8.RTCPFeedback)
+
+	vp9, err := m.getCodec(98)
+	assert.NoError(t, err)
+	assert.Equal(t, []RTCPFeedback{{Type: TypeRTCPFBCCM, Parameter: "fir"}}, vp9.RTCPFeedback)
+
+	rtx := m.getRTXCodec(98)
+	if assert.NotNil(t, rtx) {
+		assert.Equal(t, uint8(99), rtx.PayloadType)
+		assert.Equal(t, "video/rtx", rtx.MimeType)
+	}
+	assert.Nil(t, m.getRTXCodec(96))
+}
+
+func TestPopulateFromSDP_FEC(t *testing.T) {
+	const offer = `v=0
+o=- 4596489990601351948 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+m=video 60323 UDP/TLS/RTP/SAVPF 96 116 117 118
+a=rtpmap:96 VP8/90000
+a=rtpmap:116 red/90000
+a=rtpmap:117 ulpfec/90000
+a=rtpmap:118 flexfec-03/90000
+a=fmtp:118 repair-window=10000000
+`
+
+	m := MediaEngine{}
+	assert.NoError(t, m.PopulateFromSDP(SessionDescription{Type: SDPTypeOffer, SDP: offer}))
+
+	for payloadType, name := range map[uint8]string{116: RED, 117: ULPFEC, 118: FlexFEC03} {
+		codec := m.getCodecByName(RTPCodecTypeVideo, name)
+		if assert.NotNil(t, codec) {
+			assert.Equal(t, payloadType, codec.PayloadType)
+			assert.Equal(t, "video/"+name, codec.MimeType)
+		}
+	}
+	assert.Equal(t, "repair-window=10000000", m.getCodecByName(RTPCodecTypeVideo, FlexFEC03).SDPFmtpLine)
+	assert.Nil(t, m.getCodecByName(RTPCodecTypeAudio, RED))
+}
@@ -6,6 +6,11 @@ type OfferAnswerOptions struct {
 	// VoiceActivityDetection allows the application to provide information
 	// about whether it wishes voice detection feature to be enabled or disabled.
 	VoiceActivityDetection bool
+
+	// DisableVoiceActivityDetection leaves the comfort noise codec out of the
+	// description. Voice activity detection is enabled by default like in the
+	// browser, which VoiceActivityDetection can't express as it is false unless set.
+	DisableVoiceActivityDetection bool
 }
 
 // AnswerOptions structure describes the options used to control the answer
@@ -23,4 +28,11 @@ type OfferOptions struct {
 	// When this value is true, the generated description will have ICE
 	// credentials that are different from the current credentials
 	ICERestart bool
+
+	// OfferToReceiveAudio and OfferToReceiveVideo are the legacy way to
+	// receive media, a recvonly transceiver of the kind is added unless one
+	// that receives it already exists. Leaving them false changes nothing.
+	// https://www.w3.org/TR/webrtc/#legacy-configuration-extensions
+	OfferToReceiveAudio bool
+	OfferToReceiveVideo bool
 }
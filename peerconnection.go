@@ -9,6 +9,7 @@ import (
 	"crypto/rand"
 	"fmt"
 	mathRand "math/rand"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,8 +19,10 @@ import (
 	"github.com/pion/logging"
 	"github.com/pion/rtcp"
 	"github.com/pion/sdp/v2"
+	"github.com/pion/srtp"
 
 	"github.com/pion/webrtc/v2/internal/util"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 	"github.com/pion/webrtc/v2/pkg/rtcerr"
 )
 
@@ -40,16 +43,41 @@ type PeerConnection struct {
 	iceConnectionState       ICEConnectionState
 	connectionState          PeerConnectionState
 
-	idpLoginURL *string
+	// The domain of the IdentityProvider that asserts the local identity, and
+	// the identity of the remote peer once its assertion was validated
+	identityProvider string
+	peerIdentity     *IdentityAssertion
 
 	isClosed          bool
 	negotiationNeeded bool
+	iceRestartPending bool
+
+	// transportFailed keeps the PeerConnectionState failed after a transport
+	// error, whatever the transports report, until the next ICE restart
+	transportFailed bool
 
 	lastOffer  string
 	lastAnswer string
 
 	rtpTransceivers []*RTPTransceiver
 
+	// The transceivers as they were before the pending description was set,
+	// and the ones created by applying a remote offer, rollback restores them
+	rollbackTransceivers    []transceiverState
+	remoteOfferTransceivers map[*RTPTransceiver]bool
+
+	// transportsStarted is created by the first SetRemoteDescription and
+	// closed once the transports and initial RTP streams have been started.
+	// Every later remote description renegotiates over the same transports.
+	transportsStarted chan struct{}
+	startRTPLock      sync.Mutex
+
+	// The SSRCs of unsignaled RTP streams that are being bound to a RTPReceiver
+	probingSSRCs map[uint32]bool
+
+	// reportsDone stops sending the Sender Reports of the RTPSenders once closed
+	reportsDone chan struct{}
+
 	// DataChannels
 	dataChannels          map[uint16]*DataChannel
 	dataChannelsOpened    uint32
@@ -57,31 +85,74 @@ type PeerConnection struct {
 	dataChannelsAccepted  uint32
 
 	onSignalingStateChangeHandler     func(SignalingState)
+	onNegotiationNeededHandler        func()
 	onICEConnectionStateChangeHandler func(ICEConnectionState)
+	onConnectionStateChangeHandler    func(PeerConnectionState)
 	onTrackHandler                    func(*Track, *RTPReceiver)
 	onDataChannelHandler              func(*DataChannel)
+	onErrorHandler                    func(*TransportError)
+	onUnhandledRTPHandler             func(*srtp.ReadStreamSRTP, uint32, []byte)
+	onUnhandledRTCPHandler            func(*srtp.ReadStreamSRTCP, uint32, []byte)
 
 	iceGatherer   *ICEGatherer
 	iceTransport  *ICETransport
 	dtlsTransport *DTLSTransport
 	sctpTransport *SCTPTransport
 
+	// The last state of every ICE and DTLS transport that was started, the
+	// PeerConnectionState is derived from them
+	iceTransportStates  map[*ICETransport]ICETransportState
+	dtlsTransportStates map[*DTLSTransport]DTLSTransportState
+
+	// The transports of every m-line when the remote doesn't accept BUNDLE,
+	// keyed by mid. It is nil while all m-lines are bundled on the ones above
+	mediaTransports map[string]*mediaTransport
+
+	// The separate RTCP component of the transports above. It is gathered with
+	// RTCPMuxPolicyNegotiate and kept if the remote doesn't multiplex RTCP
+	rtcpTransport *rtcpTransport
+
+	// All outgoing RTCP passes the Interceptors of the API
+	interceptorRTCPWriter interceptor.RTCPWriter
+
 	// A reference to the associated API state used by this connection
 	api *API
 	log logging.LeveledLogger
 }
 
 // NewPeerConnection creates a peerconnection with the default
-// codecs. See API.NewRTCPeerConnection for details.
+// codecs and Interceptors. See API.NewRTCPeerConnection for details.
 func NewPeerConnection(configuration Configuration) (*PeerConnection, error) {
 	m := MediaEngine{}
 	m.RegisterDefaultCodecs()
-	api := NewAPI(WithMediaEngine(m))
+	r := &interceptor.Registry{}
+	RegisterDefaultInterceptors(r)
+	api := NewAPI(WithMediaEngine(m), WithInterceptorRegistry(r))
 	return api.NewPeerConnection(configuration)
 }
 
 // NewPeerConnection creates a new PeerConnection with the provided configuration against the received API object
 func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection, error) {
+	// Interceptors keep state about the streams, so every PeerConnection gets
+	// its own. They are built before any transport is created with the API.
+	i, err := api.interceptorRegistry.Build()
+	if err != nil {
+		return nil, err
+	}
+	pcAPI := &API{
+		settingEngine:       api.settingEngine,
+		mediaEngine:         api.mediaEngine,
+		interceptorRegistry: api.interceptorRegistry,
+		interceptor:         i,
+	}
+	// Nothing else closes the Interceptor if the PeerConnection isn't returned
+	fail := func(err error) (*PeerConnection, error) {
+		if closeErr := i.Close(); closeErr != nil {
+			return nil, util.FlattenErrs([]error{err, closeErr})
+		}
+		return nil, err
+	}
+
 	// https://w3c.github.io/webrtc-pc/#constructor (Step #2)
 	// Some variables defined explicitly despite their implicit zero values to
 	// allow better readability to understand what is happening.
@@ -104,23 +175,29 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 		connectionState:    PeerConnectionStateNew,
 		dataChannels:       make(map[uint16]*DataChannel),
 
-		api: api,
+		iceTransportStates:  make(map[*ICETransport]ICETransportState),
+		dtlsTransportStates: make(map[*DTLSTransport]DTLSTransportState),
+
+		reportsDone:  make(chan struct{}),
+		probingSSRCs: make(map[uint32]bool),
+
+		api: pcAPI,
 		log: api.settingEngine.LoggerFactory.NewLogger("pc"),
 	}
+	pc.interceptorRTCPWriter = i.BindRTCPWriter(interceptor.RTCPWriterFunc(pc.writeRTCP))
 
-	var err error
 	if err = pc.initConfiguration(configuration); err != nil {
-		return nil, err
+		return fail(err)
 	}
 
 	pc.iceGatherer, err = pc.createICEGatherer()
 	if err != nil {
-		return nil, err
+		return fail(err)
 	}
 
 	if !pc.iceGatherer.agentIsTrickle {
 		if err = pc.iceGatherer.Gather(); err != nil {
-			return nil, err
+			return fail(err)
 		}
 	}
 
@@ -131,9 +208,27 @@ func (api *API) NewPeerConnection(configuration Configuration) (*PeerConnection,
 	// Create the DTLS transport
 	dtlsTransport, err := pc.api.NewDTLSTransport(pc.iceTransport, pc.configuration.Certificates)
 	if err != nil {
-		return nil, err
+		return fail(err)
 	}
 	pc.dtlsTransport = dtlsTransport
+	dtlsTransport.OnStateChange(func(state DTLSTransportState) {
+		pc.onDTLSTransportStateChange(dtlsTransport, state)
+	})
+
+	if pc.configuration.RTCPMuxPolicy == RTCPMuxPolicyNegotiate {
+		if pc.rtcpTransport, err = pc.newRTCPTransport(); err != nil {
+			return fail(err)
+		}
+		pc.watchTransports(pc.rtcpTransport.iceTransport, pc.rtcpTransport.dtlsTransport)
+	}
+
+	interval := senderReportInterval
+	if api.settingEngine.rtcp.SenderReportInterval != nil {
+		interval = *api.settingEngine.rtcp.SenderReportInterval
+	}
+	if interval > 0 {
+		go pc.sendReports(interval)
+	}
 
 	return pc, nil
 }
@@ -229,6 +324,127 @@ func (pc *PeerConnection) onSignalingStateChange(newState SignalingState) (done
 	return
 }
 
+// OnNegotiationNeeded sets an event handler which is invoked when a change
+// has occurred which requires session negotiation. It is only fired while the
+// signaling state is stable, repeated changes before the next offer/answer
+// exchange are coalesced into a single event.
+func (pc *PeerConnection) OnNegotiationNeeded(f func()) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onNegotiationNeededHandler = f
+}
+
+func (pc *PeerConnection) onNegotiationNeeded() (done chan struct{}) {
+	pc.mu.RLock()
+	hdlr := pc.onNegotiationNeededHandler
+	pc.mu.RUnlock()
+
+	pc.log.Debug("negotiation needed")
+	done = make(chan struct{})
+	if hdlr == nil {
+		close(done)
+		return
+	}
+
+	go func() {
+		hdlr()
+		close(done)
+	}()
+
+	return
+}
+
+// updateNegotiationNeededFlag implements
+// https://w3c.github.io/webrtc-pc/#updating-the-negotiation-needed-flag
+func (pc *PeerConnection) updateNegotiationNeededFlag() {
+	pc.mu.RLock()
+	stable := !pc.isClosed && pc.signalingState == SignalingStateStable
+	pc.mu.RUnlock()
+	if !stable {
+		// The flag is updated again once the signaling state returns to stable
+		return
+	}
+
+	needed := pc.checkNegotiationNeeded()
+
+	pc.mu.Lock()
+	alreadyNeeded := pc.negotiationNeeded
+	pc.negotiationNeeded = needed
+	pc.mu.Unlock()
+
+	if needed && !alreadyNeeded {
+		pc.onNegotiationNeeded()
+	}
+}
+
+// checkNegotiationNeeded implements
+// https://w3c.github.io/webrtc-pc/#dfn-check-if-negotiation-is-needed
+func (pc *PeerConnection) checkNegotiationNeeded() bool {
+	var localMedia []*sdp.MediaDescription
+	if pc.currentLocalDescription != nil && pc.currentLocalDescription.parsed != nil {
+		localMedia = pc.currentLocalDescription.parsed.MediaDescriptions
+	}
+
+	pc.mu.RLock()
+	haveDataChannels := pc.dataChannelsRequested != 0
+	pc.mu.RUnlock()
+
+	if haveDataChannels {
+		haveDataSection := false
+		for _, media := range localMedia {
+			if media.MediaName.Media == "application" {
+				haveDataSection = true
+				break
+			}
+		}
+		if !haveDataSection {
+			return true
+		}
+	}
+
+	isPlanB := pc.configuration.SDPSemantics == SDPSemanticsPlanB
+	for _, t := range pc.GetTransceivers() {
+		var media *sdp.MediaDescription
+		for _, m := range localMedia {
+			if isPlanB && m.MediaName.Media == t.kind.String() ||
+				!isPlanB && t.mid != "" && pc.getMidValue(m) == t.mid {
+				media = m
+				break
+			}
+		}
+
+		switch {
+		case t.stopped:
+			if media != nil && media.MediaName.Port.Value != 0 {
+				return true
+			}
+		case media == nil:
+			return true
+		case !isPlanB && media.MediaName.Port.Value != 0 && pc.getPeerDirection(media) != pc.negotiatedDirection(t):
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiatedDirection returns the direction the current local description should
+// have for the RTPTransceiver. An answer can only accept what was offered, so it
+// is compared with the direction intersected with the offered one
+func (pc *PeerConnection) negotiatedDirection(t *RTPTransceiver) RTPTransceiverDirection {
+	if pc.currentLocalDescription == nil || pc.currentLocalDescription.Type != SDPTypeAnswer ||
+		pc.currentRemoteDescription == nil || pc.currentRemoteDescription.parsed == nil {
+		return t.Direction
+	}
+
+	for _, media := range pc.currentRemoteDescription.parsed.MediaDescriptions {
+		if pc.getMidValue(media) == t.mid {
+			return t.Direction.intersect(pc.getPeerDirection(media).reverse())
+		}
+	}
+	return t.Direction
+}
+
 // OnDataChannel sets an event handler which is invoked when a data
 // channel message arrives from a remote peer.
 func (pc *PeerConnection) OnDataChannel(f func(*DataChannel)) {
@@ -277,6 +493,26 @@ func (pc *PeerConnection) onTrack(t *Track, r *RTPReceiver) (done chan struct{})
 	return
 }
 
+// OnUnhandledRTP sets an event handler which is called for every incoming RTP
+// stream that can't be bound to a RTPReceiver, for instance because its SSRC
+// wasn't signaled and there is no MID RTP header extension to bind it with. The
+// handler gets the stream with its SSRC and first packet, and reads the stream
+// from then on. Other packets read while looking for a MID are dropped.
+func (pc *PeerConnection) OnUnhandledRTP(f func(stream *srtp.ReadStreamSRTP, ssrc uint32, firstPacket []byte)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onUnhandledRTPHandler = f
+}
+
+// OnUnhandledRTCP sets an event handler which is called for every incoming RTCP
+// stream that isn't read by a RTPSender or RTPReceiver. The handler gets the
+// stream with its SSRC and first packet, and reads the stream from then on.
+func (pc *PeerConnection) OnUnhandledRTCP(f func(stream *srtp.ReadStreamSRTCP, ssrc uint32, firstPacket []byte)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onUnhandledRTCPHandler = f
+}
+
 // OnICEConnectionStateChange sets an event handler which is called
 // when an ICE connection state is changed.
 func (pc *PeerConnection) OnICEConnectionStateChange(f func(ICEConnectionState)) {
@@ -305,6 +541,80 @@ func (pc *PeerConnection) onICEConnectionStateChange(cs ICEConnectionState) (don
 	return
 }
 
+// OnConnectionStateChange sets an event handler which is called when the
+// PeerConnectionState changes.
+func (pc *PeerConnection) OnConnectionStateChange(f func(PeerConnectionState)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onConnectionStateChangeHandler = f
+}
+
+func (pc *PeerConnection) onConnectionStateChange(cs PeerConnectionState) (done chan struct{}) {
+	pc.mu.RLock()
+	hdlr := pc.onConnectionStateChangeHandler
+	pc.mu.RUnlock()
+
+	pc.log.Infof("peer connection state changed: %s", cs)
+	done = make(chan struct{})
+	if hdlr == nil {
+		close(done)
+		return
+	}
+
+	go func() {
+		hdlr(cs)
+		close(done)
+	}()
+
+	return
+}
+
+// OnError sets an event handler which is invoked when one of the transports
+// fails once SetRemoteDescription returned, for instance when ICE doesn't
+// connect or the remote certificate doesn't match its fingerprint. The
+// PeerConnection is in the failed state by then.
+func (pc *PeerConnection) OnError(f func(*TransportError)) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.onErrorHandler = f
+}
+
+// onTransportError moves the PeerConnection to the failed state and reports the
+// error, the errors of transports torn down by Close are ignored
+func (pc *PeerConnection) onTransportError(err *TransportError) (done chan struct{}) {
+	pc.mu.Lock()
+	closed := pc.isClosed
+	changed := !closed && pc.connectionState != PeerConnectionStateFailed
+	if !closed {
+		pc.transportFailed = true
+		pc.connectionState = PeerConnectionStateFailed
+	}
+	hdlr := pc.onErrorHandler
+	pc.mu.Unlock()
+
+	done = make(chan struct{})
+	if closed {
+		close(done)
+		return
+	}
+
+	pc.log.Warnf("%s", err)
+	if changed {
+		pc.onConnectionStateChange(PeerConnectionStateFailed)
+	}
+	if hdlr == nil {
+		close(done)
+		return
+	}
+
+	go func() {
+		hdlr(err)
+		close(done)
+	}()
+
+	return
+}
+
 // SetConfiguration updates the configuration of this PeerConnection object.
 func (pc *PeerConnection) SetConfiguration(configuration Configuration) error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-setconfiguration (step #2)
@@ -394,19 +704,30 @@ func (pc *PeerConnection) getStatsID() string {
 
 // CreateOffer starts the PeerConnection and generates the localDescription
 func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription, error) {
-	useIdentity := pc.idpLoginURL != nil
-	switch {
-	case options != nil:
-		return SessionDescription{}, fmt.Errorf("TODO handle options")
-	case useIdentity:
-		return SessionDescription{}, fmt.Errorf("TODO handle identity provider")
-	case pc.isClosed:
+	if pc.isClosed {
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
-	d := sdp.NewJSEPSessionDescription(useIdentity)
+	// A first offer always has new credentials
+	if options != nil && options.ICERestart && pc.currentRemoteDescription != nil {
+		if err := pc.restartICEGatherer(); err != nil {
+			return SessionDescription{}, err
+		}
+	}
+	if options != nil {
+		if err := pc.addOfferToReceiveTransceivers(options); err != nil {
+			return SessionDescription{}, err
+		}
+	}
+
+	d := sdp.NewJSEPSessionDescription(false)
 	if err := pc.addFingerprint(d); err != nil {
 		return SessionDescription{}, err
+	} else if err := pc.addIdentity(d); err != nil {
+		return SessionDescription{}, err
+	}
+	if pc.iceGatherer.lite {
+		d.WithPropertyAttribute(sdp.AttrKeyICELite)
 	}
 
 	iceParams, err := pc.iceGatherer.GetLocalParameters()
@@ -414,61 +735,121 @@ func (pc *PeerConnection) CreateOffer(options *OfferOptions) (SessionDescription
 		return SessionDescription{}, err
 	}
 
-	candidates, err := pc.iceGatherer.GetLocalCandidates()
+	candidates, err := pc.getLocalCandidates()
 	if err != nil {
 		return SessionDescription{}, err
 	}
 
 	bundleValue := "BUNDLE"
-	bundleCount := 0
 	appendBundle := func(midValue string) {
 		bundleValue += " " + midValue
-		bundleCount++
 	}
 
 	if pc.configuration.SDPSemantics == SDPSemanticsPlanB {
-		video := make([]*RTPTransceiver, 0)
-		audio := make([]*RTPTransceiver, 0)
+		// All transceivers of a kind share the m-line of the kind
+		transceivers := map[string][]*RTPTransceiver{}
 		for _, t := range pc.GetTransceivers() {
-			switch t.kind {
-			case RTPCodecTypeVideo:
-				video = append(video, t)
-			case RTPCodecTypeAudio:
-				audio = append(audio, t)
+			if !t.stopped {
+				transceivers[t.kind.String()] = append(transceivers[t.kind.String()], t)
 			}
 		}
 
-		if len(video) > 0 {
-			if err = pc.addTransceiverSDP(d, "video", iceParams, candidates, sdp.ConnectionRoleActpass, video...); err != nil {
-				return SessionDescription{}, err
+		negotiated := map[string]*sdp.MediaDescription{}
+		for _, media := range pc.negotiatedMediaSections() {
+			negotiated[pc.getMidValue(media)] = media
+		}
+
+		for _, midValue := range getPlanBMids(pc.negotiatedMediaSections(), transceivers) {
+			kind := NewRTPCodecType(midValue)
+			switch media := negotiated[midValue]; {
+			case midValue == planBDataMid:
+				pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass)
+			case media != nil && (kind == 0 || media.MediaName.Port.Value == 0):
+				addRejectedMediaSection(d, media.MediaName.Media, midValue)
+				continue
+			case len(transceivers[midValue]) == 0:
+				// The m-line was negotiated before, but all its transceivers are stopped
+				t := &RTPTransceiver{kind: kind, Direction: RTPTransceiverDirectionInactive}
+				if err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, t.Direction, nil, t); err != nil {
+					return SessionDescription{}, err
+				}
+			default:
+				direction := getSharedDirection(transceivers[midValue])
+				if err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, direction, nil, transceivers[midValue]...); err != nil {
+					return SessionDescription{}, err
+				}
 			}
-			appendBundle("video")
+			appendBundle(midValue)
 		}
-		if len(audio) > 0 {
-			if err = pc.addTransceiverSDP(d, "audio", iceParams, candidates, sdp.ConnectionRoleActpass, audio...); err != nil {
+
+		addMsidSemantic(d, pc.GetTransceivers())
+	} else {
+		// Media sections that have been negotiated before must keep their
+		// position and mid, new transceivers are appended after them. Their
+		// mids are only proposed here, SetLocalDescription assigns them.
+		proposedMids := []string{}
+		localTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
+		haveDataSection := false
+		for _, media := range pc.negotiatedMediaSections() {
+			midValue := pc.getMidValue(media)
+			if media.MediaName.Media == "application" {
+				pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass)
+				appendBundle(midValue)
+				haveDataSection = true
+				continue
+			}
+
+			var t *RTPTransceiver
+			t, localTransceivers = findByMid(midValue, localTransceivers)
+			switch {
+			case media.MediaName.Port.Value == 0 || (t != nil && t.stopped):
+				addRejectedMediaSection(d, media.MediaName.Media, midValue)
+				continue
+			case t == nil:
+				// We answered this media section without a transceiver of our own
+				t = &RTPTransceiver{kind: NewRTPCodecType(media.MediaName.Media), Direction: RTPTransceiverDirectionInactive}
+			}
+
+			if err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, t.Direction, nil, t); err != nil {
 				return SessionDescription{}, err
 			}
-			appendBundle("audio")
+			appendBundle(midValue)
 		}
-	} else {
-		for _, t := range pc.GetTransceivers() {
-			midValue := strconv.Itoa(bundleCount)
-			if err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, t); err != nil {
+
+		for _, t := range localTransceivers {
+			if t.stopped {
+				continue
+			}
+			midValue := t.mid
+			if midValue == "" {
+				midValue = pc.nextMid(proposedMids...)
+				proposedMids = append(proposedMids, midValue)
+			}
+			if err = pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass, t.Direction, nil, t); err != nil {
 				return SessionDescription{}, err
 			}
 			appendBundle(midValue)
 		}
-	}
 
-	midValue := strconv.Itoa(bundleCount)
-	if pc.configuration.SDPSemantics == SDPSemanticsPlanB {
-		midValue = "data"
+		if !haveDataSection {
+			midValue := pc.nextMid(proposedMids...)
+			pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass)
+			appendBundle(midValue)
+		}
 	}
-	pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActpass)
-	appendBundle(midValue)
 
 	d = d.WithValueAttribute(sdp.AttrKeyGroup, bundleValue)
 
+	if options != nil && options.DisableVoiceActivityDetection {
+		removeComfortNoise(d)
+	}
+
+	if err = pc.addOfferMediaTransports(d); err != nil {
+		return SessionDescription{}, err
+	} else if err = mungeDescription(pc.api.settingEngine.sdpMungers.Local, SDPTypeOffer, d); err != nil {
+		return SessionDescription{}, err
+	}
+
 	sdpBytes, err := d.Marshal()
 	if err != nil {
 		return SessionDescription{}, err
@@ -520,70 +901,705 @@ func (pc *PeerConnection) createICETransport() *ICETransport {
 			return
 		}
 		pc.iceStateChange(cs)
+		pc.onICETransportStateChange(t, state)
 	})
 
 	return t
 }
 
-func (pc *PeerConnection) getPeerDirection(media *sdp.MediaDescription) RTPTransceiverDirection {
-	for _, a := range media.Attributes {
-		if direction := NewRTPTransceiverDirection(a.Key); direction != RTPTransceiverDirection(Unknown) {
-			return direction
-		}
-	}
-	return RTPTransceiverDirection(Unknown)
+// watchTransports makes the states of the ICE and DTLS transports of an m-line
+// or of the RTCP component part of the PeerConnectionState
+func (pc *PeerConnection) watchTransports(iceTransport *ICETransport, dtlsTransport *DTLSTransport) {
+	iceTransport.OnConnectionStateChange(func(state ICETransportState) {
+		pc.onICETransportStateChange(iceTransport, state)
+	})
+	dtlsTransport.OnStateChange(func(state DTLSTransportState) {
+		pc.onDTLSTransportStateChange(dtlsTransport, state)
+	})
 }
 
-func (pc *PeerConnection) getMidValue(media *sdp.MediaDescription) string {
-	for _, attr := range media.Attributes {
-		if attr.Key == "mid" {
-			return attr.Value
-		}
+// addTransportStates includes transports that are about to be started in the
+// PeerConnectionState, it stays connecting until all of them are connected
+func (pc *PeerConnection) addTransportStates(iceTransport *ICETransport, dtlsTransport *DTLSTransport) {
+	pc.mu.Lock()
+	if _, ok := pc.iceTransportStates[iceTransport]; !ok {
+		pc.iceTransportStates[iceTransport] = ICETransportStateNew
 	}
-	return ""
-}
-
-// Given a direction+type pluck a transceiver from the passed list
-// if no entry satisfies the requested type+direction return a inactive Transceiver
-func satisfyTypeAndDirection(remoteKind RTPCodecType, remoteDirection RTPTransceiverDirection, localTransceivers []*RTPTransceiver) (*RTPTransceiver, []*RTPTransceiver) {
-	// Get direction order from most preferred to least
-	getPreferredDirections := func() []RTPTransceiverDirection {
-		switch remoteDirection {
-		case RTPTransceiverDirectionSendrecv:
-			return []RTPTransceiverDirection{RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionSendrecv}
-		case RTPTransceiverDirectionSendonly:
-			return []RTPTransceiverDirection{RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionSendrecv}
-		case RTPTransceiverDirectionRecvonly:
-			return []RTPTransceiverDirection{RTPTransceiverDirectionSendonly, RTPTransceiverDirectionSendrecv}
-		}
-		return []RTPTransceiverDirection{}
+	if _, ok := pc.dtlsTransportStates[dtlsTransport]; !ok {
+		pc.dtlsTransportStates[dtlsTransport] = DTLSTransportStateNew
 	}
+	pc.mu.Unlock()
+}
 
-	for _, possibleDirection := range getPreferredDirections() {
-		for i := range localTransceivers {
-			t := localTransceivers[i]
-			if t.kind != remoteKind || possibleDirection != t.Direction {
-				continue
-			}
+// removeTransportStates leaves the transports of a closed m-line out of the
+// PeerConnectionState, a stopped ICETransport doesn't report its state
+func (pc *PeerConnection) removeTransportStates(iceTransport *ICETransport, dtlsTransport *DTLSTransport) {
+	pc.mu.Lock()
+	delete(pc.iceTransportStates, iceTransport)
+	delete(pc.dtlsTransportStates, dtlsTransport)
+	pc.mu.Unlock()
+}
 
-			return t, append(localTransceivers[:i], localTransceivers[i+1:]...)
-		}
-	}
+func (pc *PeerConnection) onICETransportStateChange(t *ICETransport, state ICETransportState) {
+	pc.mu.Lock()
+	pc.iceTransportStates[t] = state
+	pc.mu.Unlock()
 
-	return &RTPTransceiver{
-		kind:      remoteKind,
-		Direction: RTPTransceiverDirectionInactive,
-	}, localTransceivers
+	pc.updateConnectionState()
 }
 
-func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription) (*sdp.SessionDescription, error) {
-	iceParams, err := pc.iceGatherer.GetLocalParameters()
-	if err != nil {
-		return nil, err
-	}
+// onDTLSTransportStateChange is called while the DTLSTransport holds its lock
+func (pc *PeerConnection) onDTLSTransportStateChange(t *DTLSTransport, state DTLSTransportState) {
+	pc.mu.Lock()
+	pc.dtlsTransportStates[t] = state
+	pc.mu.Unlock()
 
-	candidates, err := pc.iceGatherer.GetLocalCandidates()
-	if err != nil {
+	pc.updateConnectionState()
+}
+
+// updateConnectionState derives the PeerConnectionState from the states of the
+// transports and fires OnConnectionStateChange if it changed
+func (pc *PeerConnection) updateConnectionState() {
+	pc.mu.Lock()
+	if pc.isClosed {
+		pc.mu.Unlock()
+		return
+	}
+
+	cs := newPeerConnectionStateFromTransports(pc.iceTransportStates, pc.dtlsTransportStates)
+	if pc.transportFailed {
+		cs = PeerConnectionStateFailed
+	}
+	if cs == pc.connectionState {
+		pc.mu.Unlock()
+		return
+	}
+	pc.connectionState = cs
+	pc.mu.Unlock()
+
+	pc.onConnectionStateChange(cs)
+}
+
+// createMediaTransport creates the transports of an m-line that is not bundled. Without
+// trickle its candidates are gathered right away, so they are complete in the description.
+// Otherwise they are gathered once the transports start and signaled through the
+// OnICECandidate handler with the mid of the m-line
+func (pc *PeerConnection) createMediaTransport(mid string) (*mediaTransport, error) {
+	iceGatherer, err := pc.createICEGatherer()
+	if err != nil {
+		return nil, err
+	}
+	if !iceGatherer.agentIsTrickle {
+		if err = iceGatherer.Gather(); err != nil {
+			return nil, err
+		}
+	} else {
+		iceGatherer.OnLocalCandidate(func(candidate *ICECandidate) {
+			// The end of gathering is signaled by the ICEGatherer of the PeerConnection
+			if candidate == nil {
+				return
+			}
+
+			pc.iceGatherer.lock.RLock()
+			onLocalCandidateHdlr := pc.iceGatherer.onLocalCandidateHdlr
+			pc.iceGatherer.lock.RUnlock()
+			if onLocalCandidateHdlr != nil {
+				candidate.sdpMid = mid
+				onLocalCandidateHdlr(candidate)
+			}
+		})
+	}
+
+	iceTransport := pc.api.NewICETransport(iceGatherer)
+	dtlsTransport, err := pc.api.NewDTLSTransport(iceTransport, pc.configuration.Certificates)
+	if err != nil {
+		return nil, err
+	}
+	pc.watchTransports(iceTransport, dtlsTransport)
+
+	return &mediaTransport{
+		iceGatherer:   iceGatherer,
+		iceTransport:  iceTransport,
+		dtlsTransport: dtlsTransport,
+	}, nil
+}
+
+// addOfferMediaTransports gives the m-lines of an offer their own transports as the
+// BundlePolicy asks for, so they can still be negotiated if the remote doesn't accept
+// BUNDLE. Once the remote accepted BUNDLE every m-line stays on the transports of the
+// PeerConnection.
+// https://tools.ietf.org/html/rfc8829#section-4.1.1
+func (pc *PeerConnection) addOfferMediaTransports(d *sdp.SessionDescription) error {
+	pc.mu.RLock()
+	bundled := pc.currentRemoteDescription != nil && pc.mediaTransports == nil
+	pc.mu.RUnlock()
+
+	if bundled {
+		return nil
+	}
+	return pc.setMediaTransports(d, getMediaTransportMids(pc.configuration.BundlePolicy, d.MediaDescriptions))
+}
+
+// setMediaTransports gives every mid after the first its own transports, creating the
+// ones that don't exist yet, and puts their ICE details into the m-lines of d. The first
+// mid uses the transports of the PeerConnection. The transports are created without
+// holding pc.mu, as they may gather their candidates
+func (pc *PeerConnection) setMediaTransports(d *sdp.SessionDescription, mids []string) error {
+	if len(mids) == 0 {
+		return nil
+	}
+
+	pc.mu.RLock()
+	missing := []string{}
+	for i, mid := range mids {
+		if _, ok := pc.mediaTransports[mid]; !ok && (i != 0 || pc.mediaTransports != nil) {
+			missing = append(missing, mid)
+		}
+	}
+	pc.mu.RUnlock()
+
+	created := map[string]*mediaTransport{}
+	unused := []*mediaTransport{}
+	for _, mid := range missing {
+		t, err := pc.createMediaTransport(mid)
+		if err != nil {
+			for _, t := range created {
+				unused = append(unused, t)
+			}
+			pc.stopMediaTransports(unused)
+			return err
+		}
+		created[mid] = t
+	}
+
+	pc.mu.Lock()
+	if pc.mediaTransports == nil {
+		pc.mediaTransports = map[string]*mediaTransport{
+			mids[0]: {iceGatherer: pc.iceGatherer, iceTransport: pc.iceTransport, dtlsTransport: pc.dtlsTransport},
+		}
+	}
+	transports := map[string]*mediaTransport{}
+	for _, mid := range mids {
+		t, ok := pc.mediaTransports[mid]
+		if created[mid] != nil {
+			// Another description may have created the transports of the mid meanwhile
+			if ok {
+				unused = append(unused, created[mid])
+			} else {
+				t = created[mid]
+				pc.mediaTransports[mid] = t
+			}
+		}
+		transports[mid] = t
+	}
+	pc.mu.Unlock()
+	pc.stopMediaTransports(unused)
+
+	for _, mid := range mids {
+		t := transports[mid]
+		if t == nil || t.dtlsTransport == pc.dtlsTransport {
+			continue
+		}
+
+		iceParams, err := t.iceGatherer.GetLocalParameters()
+		if err != nil {
+			return err
+		}
+		candidates, err := t.iceGatherer.GetLocalCandidates()
+		if err != nil {
+			return err
+		}
+		setMediaICEDetails(getMediaDescriptionByMid(d, mid), iceParams, candidates)
+	}
+	return nil
+}
+
+// updateMediaTransports applies a remote answer to the transports offered for the
+// m-lines. If the remote accepted BUNDLE all m-lines use the transports of the
+// PeerConnection, otherwise only the transports of rejected m-lines are closed
+func (pc *PeerConnection) updateMediaTransports(answer *sdp.SessionDescription) {
+	bundled := getBundleGroup(answer) != nil
+
+	pc.mu.Lock()
+	closing := []*mediaTransport{}
+	for mid, t := range pc.mediaTransports {
+		if t.dtlsTransport == pc.dtlsTransport {
+			continue
+		}
+
+		// Only the transports of the PeerConnection can carry RTCP that isn't multiplexed
+		if media := getMediaDescriptionByMid(answer, mid); bundled || media == nil || media.MediaName.Port.Value == 0 || !haveRTCPMux(answer, media) {
+			closing = append(closing, t)
+			delete(pc.mediaTransports, mid)
+		}
+	}
+	if bundled {
+		pc.mediaTransports = nil
+	}
+	pc.mu.Unlock()
+
+	for _, t := range closing {
+		pc.removeTransportStates(t.iceTransport, t.dtlsTransport)
+		if err := t.stop(); err != nil {
+			pc.log.Warnf("Failed to close unused transport: %s", err)
+		}
+	}
+}
+
+// discardMediaTransports closes the transports that were offered but never started,
+// like the ones of an offer that was rolled back. Until a remote description was
+// applied every m-line then uses the transports of the PeerConnection again
+func (pc *PeerConnection) discardMediaTransports() {
+	pc.mu.Lock()
+	closing := []*mediaTransport{}
+	inUse := false
+	for mid, t := range pc.mediaTransports {
+		switch {
+		case t.dtlsTransport == pc.dtlsTransport:
+		case t.started:
+			inUse = true
+		default:
+			closing = append(closing, t)
+			delete(pc.mediaTransports, mid)
+		}
+	}
+	if !inUse && pc.currentRemoteDescription == nil {
+		pc.mediaTransports = nil
+	}
+	pc.mu.Unlock()
+
+	pc.stopMediaTransports(closing)
+}
+
+// stopMediaTransports closes transports that were never started
+func (pc *PeerConnection) stopMediaTransports(transports []*mediaTransport) {
+	for _, t := range transports {
+		if err := t.stop(); err != nil {
+			pc.log.Warnf("Failed to close unused transport: %s", err)
+		}
+	}
+}
+
+// checkRTCPMux fails a remote description with m-lines that don't multiplex RTCP
+// if the RTCPMuxPolicy requires it
+// https://www.w3.org/TR/webrtc/#dom-rtcrtcpmuxpolicy
+func (pc *PeerConnection) checkRTCPMux(desc *sdp.SessionDescription) error {
+	if pc.configuration.RTCPMuxPolicy != RTCPMuxPolicyRequire {
+		return nil
+	}
+
+	for _, media := range desc.MediaDescriptions {
+		if !haveRTCPMux(desc, media) {
+			return &rtcerr.InvalidAccessError{Err: ErrRTCPMuxRequired}
+		}
+	}
+	return nil
+}
+
+// updateRTCPTransport applies the first remote description to the RTCP component.
+// It is kept if the remote doesn't multiplex RTCP on the transports of the
+// PeerConnection, otherwise it is closed.
+func (pc *PeerConnection) updateRTCPTransport(desc *sdp.SessionDescription) error {
+	media := getTransportMediaDescription(desc)
+
+	pc.mu.Lock()
+	rtcp := pc.rtcpTransport
+	if rtcp == nil || media == nil {
+		pc.mu.Unlock()
+		return nil
+	} else if haveRTCPMux(desc, media) {
+		pc.rtcpTransport = nil
+		pc.mu.Unlock()
+		return rtcp.stop()
+	}
+	pc.mu.Unlock()
+
+	candidates, err := extractRTCPCandidates(media)
+	if err != nil {
+		return err
+	}
+	for _, candidate := range candidates {
+		if err = rtcp.iceTransport.AddRemoteCandidate(candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getMediaTransport returns the transports of a mid, or nil if every m-line is
+// bundled or the mid can't be negotiated without BUNDLE
+func (pc *PeerConnection) getMediaTransport(mid string) *mediaTransport {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.mediaTransports[mid]
+}
+
+// getTransceiverTransport returns the DTLSTransport the m-line of a RTPTransceiver is
+// negotiated on, or nil if it can't be negotiated without BUNDLE
+func (pc *PeerConnection) getTransceiverTransport(t *RTPTransceiver, remoteDesc *SessionDescription) *DTLSTransport {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	if pc.mediaTransports == nil {
+		return pc.dtlsTransport
+	}
+
+	mid := t.mid
+	if mid == "" {
+		// Plan-B transceivers share the m-line of their kind
+		for _, media := range remoteDesc.parsed.MediaDescriptions {
+			if NewRTPCodecType(media.MediaName.Media) == t.kind {
+				mid = pc.getMidValue(media)
+				break
+			}
+		}
+	}
+
+	if mt, ok := pc.mediaTransports[mid]; ok {
+		return mt.dtlsTransport
+	}
+	return nil
+}
+
+// startMediaTransports starts the transports of the m-lines that are not bundled and
+// waits for them to connect. An m-line whose transport fails doesn't stop the others
+func (pc *PeerConnection) startMediaTransports() {
+	remoteDesc := pc.RemoteDescription()
+	if remoteDesc == nil || remoteDesc.parsed == nil {
+		return
+	}
+
+	pc.mu.Lock()
+	pending := map[string]*mediaTransport{}
+	for mid, t := range pc.mediaTransports {
+		if t.dtlsTransport != pc.dtlsTransport && !t.started {
+			t.started = true
+			pending[mid] = t
+		}
+	}
+	pc.mu.Unlock()
+
+	iceRole := pc.iceTransport.Role()
+	var wg sync.WaitGroup
+	for mid, t := range pending {
+		wg.Add(1)
+		go func(mid string, t *mediaTransport) {
+			defer wg.Done()
+
+			if err := pc.startMediaTransport(t, remoteDesc.parsed, mid, iceRole); err != nil {
+				pc.onTransportError(err)
+				return
+			}
+			go pc.drainSRTP(t.dtlsTransport)
+		}(mid, t)
+	}
+	wg.Wait()
+}
+
+// startMediaTransport starts the transports of a single m-line with the ICE and
+// DTLS parameters of the remote m-line, it blocks until they are connected
+func (pc *PeerConnection) startMediaTransport(t *mediaTransport, remoteDesc *sdp.SessionDescription, mid string, iceRole ICERole) *TransportError {
+	media := getMediaDescriptionByMid(remoteDesc, mid)
+	if media == nil {
+		return &TransportError{Component: TransportComponentICE, Err: fmt.Errorf("no remote m-line for mid %s", mid)}
+	}
+
+	remoteUfrag, remotePwd, candidates, err := extractMediaICEDetails(media)
+	if err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+	for _, candidate := range candidates {
+		if err = t.iceTransport.AddRemoteCandidate(candidate); err != nil {
+			return &TransportError{Component: TransportComponentICE, Err: err}
+		}
+	}
+
+	fingerprint, fingerprintHash, err := extractFingerprint(remoteDesc, media)
+	if err != nil {
+		return &TransportError{Component: TransportComponentDTLS, Err: err}
+	}
+
+	// With trickle the candidates are gathered once the remote accepted the m-line
+	// without BUNDLE, they aren't of use otherwise
+	if t.iceGatherer.agentIsTrickle && t.iceGatherer.State() == ICEGathererStateNew {
+		if err = t.iceGatherer.Gather(); err != nil {
+			return &TransportError{Component: TransportComponentICE, Err: err}
+		}
+	}
+
+	pc.addTransportStates(t.iceTransport, t.dtlsTransport)
+	if err = t.iceTransport.Start(t.iceGatherer, ICEParameters{
+		UsernameFragment: remoteUfrag,
+		Password:         remotePwd,
+		ICELite:          isICELite(remoteDesc),
+	}, &iceRole); err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+
+	if err = t.dtlsTransport.Start(DTLSParameters{
+		Role:         dtlsRoleFromRemoteSDP(&sdp.SessionDescription{MediaDescriptions: []*sdp.MediaDescription{media}}),
+		Fingerprints: []DTLSFingerprint{{Algorithm: fingerprintHash, Value: fingerprint}},
+	}); err != nil {
+		return &TransportError{Component: TransportComponentDTLS, Err: err}
+	}
+	return nil
+}
+
+func (pc *PeerConnection) getPeerDirection(media *sdp.MediaDescription) RTPTransceiverDirection {
+	for _, a := range media.Attributes {
+		if direction := NewRTPTransceiverDirection(a.Key); direction != RTPTransceiverDirection(Unknown) {
+			return direction
+		}
+	}
+	return RTPTransceiverDirection(Unknown)
+}
+
+func (pc *PeerConnection) getMidValue(media *sdp.MediaDescription) string {
+	for _, attr := range media.Attributes {
+		if attr.Key == "mid" {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// getNegotiatedRTCPFeedback returns the RTCP feedback for a payload type that
+// both the local and the remote media section of a mid offer. Plan-B media
+// sections are found by their kind
+func (pc *PeerConnection) getNegotiatedRTCPFeedback(mid string, kind RTPCodecType, payloadType uint8) []RTCPFeedback {
+	localDesc, remoteDesc := pc.LocalDescription(), pc.RemoteDescription()
+	if localDesc == nil || localDesc.parsed == nil || remoteDesc == nil || remoteDesc.parsed == nil {
+		return nil
+	}
+
+	findMedia := func(d *sdp.SessionDescription) *sdp.MediaDescription {
+		for _, media := range d.MediaDescriptions {
+			if mid != "" && pc.getMidValue(media) == mid {
+				return media
+			}
+		}
+		return getMediaOfKind(d, kind)
+	}
+	localMedia, remoteMedia := findMedia(localDesc.parsed), findMedia(remoteDesc.parsed)
+	if localMedia == nil || remoteMedia == nil {
+		return nil
+	}
+
+	negotiated := []RTCPFeedback{}
+	localFeedback := getRTCPFeedback(localMedia, payloadType)
+	for _, feedback := range getRTCPFeedback(remoteMedia, payloadType) {
+		for _, f := range localFeedback {
+			if f == feedback {
+				negotiated = append(negotiated, feedback)
+				break
+			}
+		}
+	}
+	return negotiated
+}
+
+// negotiatedMediaSections returns the media sections of the last successful
+// offer/answer exchange. Subsequent offers have to keep their order.
+func (pc *PeerConnection) negotiatedMediaSections() []*sdp.MediaDescription {
+	switch {
+	case pc.currentLocalDescription != nil && pc.currentLocalDescription.parsed != nil:
+		return pc.currentLocalDescription.parsed.MediaDescriptions
+	case pc.currentRemoteDescription != nil && pc.currentRemoteDescription.parsed != nil:
+		return pc.currentRemoteDescription.parsed.MediaDescriptions
+	}
+	return nil
+}
+
+// nextMid returns a numeric mid that is not used by any transceiver,
+// negotiated media section or proposed mid yet
+func (pc *PeerConnection) nextMid(proposed ...string) string {
+	inUse := map[string]bool{}
+	for _, mid := range proposed {
+		inUse[mid] = true
+	}
+	for _, media := range pc.negotiatedMediaSections() {
+		inUse[pc.getMidValue(media)] = true
+	}
+	if remote := pc.RemoteDescription(); remote != nil && remote.parsed != nil {
+		for _, media := range remote.parsed.MediaDescriptions {
+			inUse[pc.getMidValue(media)] = true
+		}
+	}
+	for _, t := range pc.GetTransceivers() {
+		inUse[t.mid] = true
+	}
+
+	next := 0
+	for mid := range inUse {
+		if i, err := strconv.Atoi(mid); err == nil && i >= next {
+			next = i + 1
+		}
+	}
+	for inUse[strconv.Itoa(next)] {
+		next++
+	}
+	return strconv.Itoa(next)
+}
+
+// findByMid pluck a transceiver with the given mid from the passed list
+func findByMid(mid string, localTransceivers []*RTPTransceiver) (*RTPTransceiver, []*RTPTransceiver) {
+	if mid == "" {
+		return nil, localTransceivers
+	}
+
+	for i, t := range localTransceivers {
+		if t.mid == mid {
+			return t, append(localTransceivers[:i], localTransceivers[i+1:]...)
+		}
+	}
+
+	return nil, localTransceivers
+}
+
+// associateTransceivers gives the media sections of a remote offer a local
+// RTPTransceiver, matched by mid if it was negotiated before or by type and
+// direction otherwise. Media sections the remote rejected keep their
+// transceiver until the answer is set, see stopRejectedTransceivers.
+func (pc *PeerConnection) associateTransceivers(remote *SessionDescription) error {
+	if pc.configuration.SDPSemantics == SDPSemanticsPlanB || pc.descriptionIsPlanB(remote) {
+		// Plan-B media is matched by SSRC in openSRTP
+		return nil
+	}
+
+	unassociated := []*RTPTransceiver{}
+	for _, t := range pc.GetTransceivers() {
+		if t.mid == "" && !t.stopped {
+			unassociated = append(unassociated, t)
+		}
+	}
+
+	for _, media := range remote.parsed.MediaDescriptions {
+		midValue := pc.getMidValue(media)
+		kind := NewRTPCodecType(media.MediaName.Media)
+		if midValue == "" || kind == 0 {
+			continue
+		}
+
+		if t, _ := findByMid(midValue, append([]*RTPTransceiver{}, pc.GetTransceivers()...)); t != nil || media.MediaName.Port.Value == 0 {
+			continue
+		}
+
+		var t *RTPTransceiver
+		t, unassociated = satisfyTypeAndDirection(kind, pc.getPeerDirection(media), unassociated)
+		if t.Direction != RTPTransceiverDirectionInactive {
+			pc.mu.Lock()
+			t.mid = midValue
+			pc.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// assignOfferMids associates the transceivers without a mid with the media
+// sections a local offer proposed for them, in the order they were offered
+// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-24#section-5.2.2
+func (pc *PeerConnection) assignOfferMids(offer *SessionDescription) {
+	if pc.configuration.SDPSemantics == SDPSemanticsPlanB {
+		return
+	}
+
+	inUse := map[string]bool{}
+	for _, media := range pc.negotiatedMediaSections() {
+		inUse[pc.getMidValue(media)] = true
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	unassociated := []*RTPTransceiver{}
+	for _, t := range pc.rtpTransceivers {
+		switch {
+		case t.mid != "":
+			inUse[t.mid] = true
+		case !t.stopped:
+			unassociated = append(unassociated, t)
+		}
+	}
+
+	for _, media := range offer.parsed.MediaDescriptions {
+		midValue := pc.getMidValue(media)
+		kind := NewRTPCodecType(media.MediaName.Media)
+		if midValue == "" || kind == 0 || inUse[midValue] || media.MediaName.Port.Value == 0 {
+			continue
+		}
+
+		for i, t := range unassociated {
+			if t.kind == kind {
+				t.mid = midValue
+				unassociated = append(unassociated[:i], unassociated[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// stopRejectedTransceivers stops the transceivers of the media sections a
+// remote offer rejected, once our answer made the offer final
+func (pc *PeerConnection) stopRejectedTransceivers(offer *SessionDescription) error {
+	if offer == nil || offer.parsed == nil || pc.configuration.SDPSemantics == SDPSemanticsPlanB || pc.descriptionIsPlanB(offer) {
+		return nil
+	}
+
+	for _, media := range offer.parsed.MediaDescriptions {
+		if media.MediaName.Port.Value != 0 {
+			continue
+		}
+		if t, _ := findByMid(pc.getMidValue(media), append([]*RTPTransceiver{}, pc.GetTransceivers()...)); t != nil && !t.stopped {
+			if err := t.stop(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Given a direction+type pluck a transceiver from the passed list
+// if no entry satisfies the requested type+direction return a inactive Transceiver
+func satisfyTypeAndDirection(remoteKind RTPCodecType, remoteDirection RTPTransceiverDirection, localTransceivers []*RTPTransceiver) (*RTPTransceiver, []*RTPTransceiver) {
+	// Get direction order from most preferred to least
+	getPreferredDirections := func() []RTPTransceiverDirection {
+		switch remoteDirection {
+		case RTPTransceiverDirectionSendrecv:
+			return []RTPTransceiverDirection{RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionSendrecv}
+		case RTPTransceiverDirectionSendonly:
+			return []RTPTransceiverDirection{RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionSendrecv}
+		case RTPTransceiverDirectionRecvonly:
+			return []RTPTransceiverDirection{RTPTransceiverDirectionSendonly, RTPTransceiverDirectionSendrecv}
+		}
+		return []RTPTransceiverDirection{}
+	}
+
+	for _, possibleDirection := range getPreferredDirections() {
+		for i := range localTransceivers {
+			t := localTransceivers[i]
+			if t.kind != remoteKind || possibleDirection != t.Direction {
+				continue
+			}
+
+			return t, append(localTransceivers[:i], localTransceivers[i+1:]...)
+		}
+	}
+
+	return &RTPTransceiver{
+		kind:      remoteKind,
+		Direction: RTPTransceiverDirectionInactive,
+	}, localTransceivers
+}
+
+func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription) (*sdp.SessionDescription, error) {
+	iceParams, err := pc.iceGatherer.GetLocalParameters()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := pc.getLocalCandidates()
+	if err != nil {
 		return nil, err
 	}
 
@@ -592,18 +1608,29 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 		bundleValue += " " + midValue
 	}
 
+	// Once DTLS is running a renegotiation has to keep the established role
+	connectionRole := sdp.ConnectionRoleActive
+	if pc.dtlsTransport.State() != DTLSTransportStateNew && !pc.dtlsTransport.isClient() {
+		connectionRole = sdp.ConnectionRolePassive
+	}
+
 	var t *RTPTransceiver
 	localTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
 	detectedPlanB := pc.descriptionIsPlanB(pc.RemoteDescription())
 
 	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
 		midValue := pc.getMidValue(media)
+		if media.MediaName.Port.Value == 0 {
+			addRejectedMediaSection(d, media.MediaName.Media, midValue)
+			continue
+		}
+
 		if midValue == "" {
 			return nil, fmt.Errorf("RemoteDescription contained media section without mid value")
 		}
 
 		if media.MediaName.Media == "application" {
-			pc.addDataMediaSection(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive)
+			pc.addDataMediaSection(d, midValue, iceParams, candidates, connectionRole)
 			appendBundle(midValue)
 			continue
 		}
@@ -614,7 +1641,15 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 			continue
 		}
 
-		t, localTransceivers = satisfyTypeAndDirection(kind, direction, localTransceivers)
+		if detectedPlanB {
+			t, localTransceivers = satisfyTypeAndDirection(kind, direction, localTransceivers)
+		} else if t, localTransceivers = findByMid(midValue, localTransceivers); t == nil {
+			// Nothing was associated with this media section by SetRemoteDescription
+			t = &RTPTransceiver{kind: kind, Direction: RTPTransceiverDirectionInactive}
+		} else if t.stopped {
+			addRejectedMediaSection(d, media.MediaName.Media, midValue)
+			continue
+		}
 		mediaTransceivers := []*RTPTransceiver{t}
 		switch pc.configuration.SDPSemantics {
 		case SDPSemanticsUnifiedPlanWithFallback:
@@ -643,7 +1678,10 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 				return nil, &rtcerr.TypeError{Err: ErrIncorrectSDPSemantics}
 			}
 		}
-		if err := pc.addTransceiverSDP(d, midValue, iceParams, candidates, sdp.ConnectionRoleActive, mediaTransceivers...); err != nil {
+		// Answer with what both the offer and our transceivers allow, so a
+		// remote hold (sendonly or inactive) is answered with recvonly or inactive
+		answerDirection := getSharedDirection(mediaTransceivers).intersect(direction.reverse())
+		if err := pc.addTransceiverSDP(d, midValue, iceParams, candidates, connectionRole, answerDirection, media, mediaTransceivers...); err != nil {
 			return nil, err
 		}
 		appendBundle(midValue)
@@ -652,33 +1690,65 @@ func (pc *PeerConnection) addAnswerMediaTransceivers(d *sdp.SessionDescription)
 	if pc.configuration.SDPSemantics == SDPSemanticsUnifiedPlanWithFallback && detectedPlanB {
 		pc.log.Info("Plan-B Offer detected; responding with Plan-B Answer")
 	}
+	if detectedPlanB {
+		addMsidSemantic(d, pc.GetTransceivers())
+	}
 
+	// A remote that doesn't offer BUNDLE needs a transport for every m-line,
+	// the BundlePolicy decides which m-lines get one and rejects the others
+	if remoteDesc := pc.RemoteDescription().parsed; getBundleGroup(remoteDesc) == nil {
+		mids := []string{}
+		negotiated := map[string]bool{}
+		for i, mid := range getMediaTransportMids(pc.configuration.BundlePolicy, d.MediaDescriptions) {
+			// Only the transports of the PeerConnection can carry RTCP that isn't multiplexed
+			if media := getMediaDescriptionByMid(remoteDesc, mid); i == 0 || media == nil || haveRTCPMux(remoteDesc, media) {
+				mids = append(mids, mid)
+				negotiated[mid] = true
+			}
+		}
+		for _, media := range d.MediaDescriptions {
+			if media.MediaName.Port.Value != 0 && !negotiated[pc.getMidValue(media)] {
+				rejectMediaDescription(media)
+			}
+		}
+
+		return d, pc.setMediaTransports(d, mids)
+	}
+
+	pc.discardMediaTransports()
 	return d.WithValueAttribute(sdp.AttrKeyGroup, bundleValue), nil
 }
 
 // CreateAnswer starts the PeerConnection and generates the localDescription
 func (pc *PeerConnection) CreateAnswer(options *AnswerOptions) (SessionDescription, error) {
-	useIdentity := pc.idpLoginURL != nil
 	switch {
-	case options != nil:
-		return SessionDescription{}, fmt.Errorf("TODO handle options")
 	case pc.RemoteDescription() == nil:
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrNoRemoteDescription}
-	case useIdentity:
-		return SessionDescription{}, fmt.Errorf("TODO handle identity provider")
 	case pc.isClosed:
 		return SessionDescription{}, &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
-	d := sdp.NewJSEPSessionDescription(useIdentity)
+	d := sdp.NewJSEPSessionDescription(false)
 	if err := pc.addFingerprint(d); err != nil {
 		return SessionDescription{}, err
+	} else if err := pc.addIdentity(d); err != nil {
+		return SessionDescription{}, err
+	}
+	if pc.iceGatherer.lite {
+		d.WithPropertyAttribute(sdp.AttrKeyICELite)
 	}
 
 	d, err := pc.addAnswerMediaTransceivers(d)
 	if err != nil {
 		return SessionDescription{}, err
 	}
+	if options != nil && options.DisableVoiceActivityDetection {
+		removeComfortNoise(d)
+	}
+
+	if err = mungeDescription(pc.api.settingEngine.sdpMungers.Local, SDPTypeAnswer, d); err != nil {
+		return SessionDescription{}, err
+	}
 
 	sdpBytes, err := d.Marshal()
 	if err != nil {
@@ -700,7 +1770,9 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	pc.mu.RLock()
 	cur := pc.signalingState
+	pc.mu.RUnlock()
 	setLocal := stateChangeOpSetLocal
 	setRemote := stateChangeOpSetRemote
 	newSDPDoesNotMatchOffer := &rtcerr.InvalidModificationError{Err: fmt.Errorf("new sdp does not match previous offer")}
@@ -732,11 +1804,12 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 				pc.currentRemoteDescription = pc.pendingRemoteDescription
 				pc.pendingRemoteDescription = nil
 				pc.pendingLocalDescription = nil
+				pc.updateCurrentDirections(sd, true)
 			}
 		case SDPTypeRollback:
 			nextState, err = checkNextSignalingState(cur, SignalingStateStable, setLocal, sd.Type)
 			if err == nil {
-				pc.pendingLocalDescription = nil
+				pc.rollback()
 			}
 		// have-remote-offer->SetLocal(pranswer)->have-local-pranswer
 		case SDPTypePranswer:
@@ -767,11 +1840,12 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 				pc.currentLocalDescription = pc.pendingLocalDescription
 				pc.pendingRemoteDescription = nil
 				pc.pendingLocalDescription = nil
+				pc.updateCurrentDirections(sd, false)
 			}
 		case SDPTypeRollback:
 			nextState, err = checkNextSignalingState(cur, SignalingStateStable, setRemote, sd.Type)
 			if err == nil {
-				pc.pendingRemoteDescription = nil
+				pc.rollback()
 			}
 		// have-local-offer->SetRemote(pranswer)->have-remote-pranswer
 		case SDPTypePranswer:
@@ -779,18 +1853,151 @@ func (pc *PeerConnection) setDescription(sd *SessionDescription, op stateChangeO
 			if err == nil {
 				pc.pendingRemoteDescription = sd
 			}
-		default:
-			return &rtcerr.OperationError{Err: fmt.Errorf("invalid state change op: %s(%s)", op, sd.Type)}
+		default:
+			return &rtcerr.OperationError{Err: fmt.Errorf("invalid state change op: %s(%s)", op, sd.Type)}
+		}
+	default:
+		return &rtcerr.OperationError{Err: fmt.Errorf("unhandled state change op: %q", op)}
+	}
+
+	if err == nil {
+		pc.mu.Lock()
+		switch {
+		case cur == SignalingStateStable && nextState != SignalingStateStable:
+			pc.rollbackTransceivers = make([]transceiverState, 0, len(pc.rtpTransceivers))
+			for _, t := range pc.rtpTransceivers {
+				pc.rollbackTransceivers = append(pc.rollbackTransceivers, transceiverState{
+					transceiver:      t,
+					mid:              t.mid,
+					direction:        t.Direction,
+					currentDirection: t.currentDirection,
+				})
+			}
+			pc.remoteOfferTransceivers = map[*RTPTransceiver]bool{}
+		case nextState == SignalingStateStable:
+			pc.rollbackTransceivers = nil
+			pc.remoteOfferTransceivers = nil
+		}
+		pc.signalingState = nextState
+		pc.mu.Unlock()
+		pc.onSignalingStateChange(nextState)
+
+		// https://w3c.github.io/webrtc-pc/#set-description (Step #2.2.11)
+		if nextState == SignalingStateStable {
+			pc.mu.RLock()
+			wasNeeded := pc.negotiationNeeded
+			pc.mu.RUnlock()
+
+			pc.updateNegotiationNeededFlag()
+
+			pc.mu.RLock()
+			stillNeeded := pc.negotiationNeeded
+			pc.mu.RUnlock()
+
+			if wasNeeded && stillNeeded {
+				pc.onNegotiationNeeded()
+			}
+		}
+	}
+	return err
+}
+
+// updateCurrentDirections sets the currentDirection of every negotiated RTPTransceiver
+// from the answer, the direction of a remote answer is reversed to our point of view
+// https://w3c.github.io/webrtc-pc/#set-description (Step #2.2.8.1.7)
+func (pc *PeerConnection) updateCurrentDirections(answer *SessionDescription, isLocal bool) {
+	if answer.parsed == nil {
+		return
+	}
+
+	isPlanB := pc.descriptionIsPlanB(answer)
+	for _, media := range answer.parsed.MediaDescriptions {
+		direction := pc.getPeerDirection(media)
+		if media.MediaName.Port.Value == 0 {
+			direction = RTPTransceiverDirectionInactive
+		} else if direction == RTPTransceiverDirection(Unknown) {
+			continue
+		} else if !isLocal {
+			direction = direction.reverse()
+		}
+
+		midValue := pc.getMidValue(media)
+		for _, t := range pc.GetTransceivers() {
+			switch {
+			case isPlanB && t.kind.String() == media.MediaName.Media:
+				// The m-line is shared, so it can't be more than the transceiver wants
+				t.currentDirection = direction.intersect(t.Direction)
+			case !isPlanB && t.mid != "" && t.mid == midValue:
+				t.currentDirection = direction
+			}
+		}
+	}
+}
+
+// rollback discards the pending offer and restores the last stable state.
+// Transceivers lose the mid the pending offer associated them with, so they
+// are negotiated again by the next offer, and a pending ICE restart goes back
+// to the previous local credentials.
+// https://w3c.github.io/webrtc-pc/#dfn-rollback
+// transceiverState is the state of a transceiver that a description changes
+type transceiverState struct {
+	transceiver      *RTPTransceiver
+	mid              string
+	direction        RTPTransceiverDirection
+	currentDirection RTPTransceiverDirection
+}
+
+func (pc *PeerConnection) rollback() {
+	pc.pendingLocalDescription = nil
+	pc.pendingRemoteDescription = nil
+
+	if pc.iceRestartPending {
+		pc.iceRestartPending = false
+		if err := pc.iceGatherer.rollbackRestart(); err != nil {
+			pc.log.Warnf("Failed to close the agent of a rolled back ICE restart: %v", err)
+		}
+
+		pc.mu.RLock()
+		rtcp := pc.rtcpTransport
+		pc.mu.RUnlock()
+		if rtcp != nil {
+			if err := rtcp.iceGatherer.rollbackRestart(); err != nil {
+				pc.log.Warnf("Failed to close the agent of a rolled back ICE restart: %v", err)
+			}
 		}
-	default:
-		return &rtcerr.OperationError{Err: fmt.Errorf("unhandled state change op: %q", op)}
 	}
 
-	if err == nil {
-		pc.signalingState = nextState
-		pc.onSignalingStateChange(nextState)
+	negotiatedMids := map[string]bool{}
+	for _, media := range pc.negotiatedMediaSections() {
+		negotiatedMids[pc.getMidValue(media)] = true
 	}
-	return err
+	pc.mu.Lock()
+	kept := []*RTPTransceiver{}
+	removed := []*RTPTransceiver{}
+	for _, t := range pc.rtpTransceivers {
+		if pc.remoteOfferTransceivers[t] {
+			removed = append(removed, t)
+			continue
+		}
+		if !negotiatedMids[t.mid] {
+			t.mid = ""
+		}
+		kept = append(kept, t)
+	}
+	for _, state := range pc.rollbackTransceivers {
+		state.transceiver.mid = state.mid
+		state.transceiver.Direction = state.direction
+		state.transceiver.currentDirection = state.currentDirection
+	}
+	pc.rtpTransceivers = kept
+	pc.mu.Unlock()
+
+	for _, t := range removed {
+		if err := t.stop(); err != nil {
+			pc.log.Warnf("Failed to stop a transceiver of a rolled back offer: %v", err)
+		}
+	}
+	pc.discardMediaTransports()
 }
 
 // SetLocalDescription sets the SessionDescription of the local peer
@@ -799,6 +2006,10 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	if desc.Type == SDPTypeRollback {
+		return pc.setDescription(&desc, stateChangeOpSetLocal)
+	}
+
 	// JSEP 5.4
 	if desc.SDP == "" {
 		switch desc.Type {
@@ -813,13 +2024,43 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 		}
 	}
 
+	haveLocalDescription := pc.currentLocalDescription != nil
 	desc.parsed = &sdp.SessionDescription{}
 	if err := desc.parsed.Unmarshal([]byte(desc.SDP)); err != nil {
 		return err
 	}
+	if err := pc.checkLocalModifications(&desc); err != nil {
+		return err
+	}
 	if err := pc.setDescription(&desc, stateChangeOpSetLocal); err != nil {
 		return err
 	}
+	switch desc.Type {
+	case SDPTypeOffer:
+		pc.assignOfferMids(&desc)
+	case SDPTypeAnswer:
+		if err := pc.stopRejectedTransceivers(pc.currentRemoteDescription); err != nil {
+			return err
+		}
+	}
+
+	// Candidates have already been gathered and signaled during the first
+	// negotiation, a renegotiation keeps using them
+	if haveLocalDescription {
+		if desc.Type != SDPTypeAnswer {
+			return nil
+		}
+
+		go pc.renegotiateRTP()
+		if pc.iceRestartPending {
+			remoteUfrag, remotePwd, _, err := extractICEDetails(pc.currentRemoteDescription.parsed)
+			if err != nil {
+				return err
+			}
+			return pc.restartICETransport(remoteUfrag, remotePwd)
+		}
+		return nil
+	}
 
 	// To support all unittests which are following the future trickle=true
 	// setup while also support the old trickle=false synchronous gathering
@@ -832,7 +2073,7 @@ func (pc *PeerConnection) SetLocalDescription(desc SessionDescription) error {
 		return nil
 	}
 
-	if desc.Type == SDPTypeAnswer {
+	if desc.Type == SDPTypeAnswer && pc.iceGatherer.State() == ICEGathererStateNew {
 		return pc.iceGatherer.Gather()
 	}
 	return nil
@@ -851,68 +2092,85 @@ func (pc *PeerConnection) LocalDescription() *SessionDescription {
 
 // SetRemoteDescription sets the SessionDescription of the remote peer
 func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error { //nolint pion/webrtc#614
-	if pc.currentRemoteDescription != nil { // pion/webrtc#207
-		return fmt.Errorf("remoteDescription is already defined, SetRemoteDescription can only be called once")
-	}
 	if pc.isClosed {
 		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
 	}
 
+	if desc.Type == SDPTypeRollback {
+		return pc.setDescription(&desc, stateChangeOpSetRemote)
+	}
+
 	desc.parsed = &sdp.SessionDescription{}
 	if err := desc.parsed.Unmarshal([]byte(desc.SDP)); err != nil {
 		return err
 	}
+	if munger := pc.api.settingEngine.sdpMungers.Remote; munger != nil {
+		if err := mungeDescription(munger, desc.Type, desc.parsed); err != nil {
+			return err
+		}
+		sdpBytes, err := desc.parsed.Marshal()
+		if err != nil {
+			return err
+		}
+		desc.SDP = string(sdpBytes)
+	}
+	if err := pc.checkRTCPMux(desc.parsed); err != nil {
+		return err
+	}
+	identity, err := pc.checkIdentity(desc.parsed)
+	if err != nil {
+		return err
+	}
 	if err := pc.setDescription(&desc, stateChangeOpSetRemote); err != nil {
 		return err
 	}
+	if identity != nil {
+		pc.mu.Lock()
+		pc.peerIdentity = identity
+		pc.mu.Unlock()
+	}
 
-	weOffer := true
-	remoteUfrag := ""
-	remotePwd := ""
 	if desc.Type == SDPTypeOffer {
-		weOffer = false
+		if err := pc.associateTransceivers(&desc); err != nil {
+			return err
+		}
+	} else if desc.Type == SDPTypeAnswer {
+		pc.updateMediaTransports(desc.parsed)
 	}
 
-	fingerprint, haveFingerprint := desc.parsed.Attribute("fingerprint")
-	for _, m := range pc.RemoteDescription().parsed.MediaDescriptions {
-		if !haveFingerprint {
-			fingerprint, haveFingerprint = m.Attribute("fingerprint")
-		}
+	remoteUfrag, remotePwd, candidates, err := extractICEDetails(desc.parsed)
+	if err != nil {
+		return err
+	}
 
-		for _, a := range m.Attributes {
-			switch {
-			case a.IsICECandidate():
-				sdpCandidate, err := a.ToICECandidate()
-				if err != nil {
-					return err
-				}
+	// The transports are only started by the first remote description, every
+	// later one renegotiates the media running over them once the offer/answer
+	// exchange is complete. pion/webrtc#207
+	if pc.transportsStarted != nil {
+		return pc.renegotiateRemoteDescription(&desc, remoteUfrag, remotePwd, candidates)
+	}
+	pc.transportsStarted = make(chan struct{})
 
-				candidate, err := newICECandidateFromSDP(sdpCandidate)
-				if err != nil {
-					return err
-				}
+	if err = pc.updateRTCPTransport(desc.parsed); err != nil {
+		return err
+	}
 
-				if err = pc.iceTransport.AddRemoteCandidate(candidate); err != nil {
-					return err
-				}
-			case strings.HasPrefix(*a.String(), "ice-ufrag"):
-				remoteUfrag = (*a.String())[len("ice-ufrag:"):]
-			case strings.HasPrefix(*a.String(), "ice-pwd"):
-				remotePwd = (*a.String())[len("ice-pwd:"):]
-			}
+	for _, candidate := range candidates {
+		if err = pc.iceTransport.AddRemoteCandidate(candidate); err != nil {
+			return err
 		}
 	}
 
-	if !haveFingerprint {
-		return fmt.Errorf("could not find fingerprint")
+	weOffer := true
+	if desc.Type == SDPTypeOffer {
+		weOffer = false
 	}
+	remoteIsLite := isICELite(desc.parsed)
 
-	parts := strings.Split(fingerprint, " ")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid fingerprint")
+	fingerprint, fingerprintHash, err := extractFingerprint(pc.RemoteDescription().parsed, nil)
+	if err != nil {
+		return err
 	}
-	fingerprint = parts[1]
-	fingerprintHash := parts[0]
 
 	// Create the SCTP transport
 	sctp := pc.api.NewSCTPTransport(pc.dtlsTransport)
@@ -940,97 +2198,388 @@ func (pc *PeerConnection) SetRemoteDescription(desc SessionDescription) error {
 	go func() {
 		// Star the networking in a new routine since it will block until
 		// the connection is actually established.
+		iceRole := getICERole(weOffer, pc.iceGatherer.lite, remoteIsLite)
 
-		// Start the ice transport
-		iceRole := ICERoleControlled
-		if weOffer {
-			iceRole = ICERoleControlling
-		}
-		err := pc.iceTransport.Start(
-			pc.iceGatherer,
-			ICEParameters{
-				UsernameFragment: remoteUfrag,
-				Password:         remotePwd,
-				ICELite:          false,
-			},
-			&iceRole,
-		)
+		terr := pc.startTransports(iceRole, dtlsRoleFromRemoteSDP(desc.parsed), remoteUfrag, remotePwd, remoteIsLite, fingerprint, fingerprintHash)
+		if terr == nil {
+			pc.startMediaTransports()
+			pc.startRTP()
+			go pc.drainSRTP(pc.dtlsTransport)
+		}
+		close(pc.transportsStarted)
 
-		if err != nil {
-			// pion/webrtc#614
-			pc.log.Warnf("Failed to start manager: %s", err)
+		if terr != nil {
+			pc.onTransportError(terr)
 			return
 		}
 
-		// Start the dtls transport
-		err = pc.dtlsTransport.Start(DTLSParameters{
-			Role:         dtlsRoleFromRemoteSDP(desc.parsed),
-			Fingerprints: []DTLSFingerprint{{Algorithm: fingerprintHash, Value: fingerprint}},
-		})
-		if err != nil {
-			// pion/webrtc#614
-			pc.log.Warnf("Failed to start manager: %s", err)
+		// The data m-line may be on a transport of its own
+		dtlsTransport := pc.getDataTransport()
+		if dtlsTransport == nil {
+			pc.log.Warnf("Data m-line can't be negotiated without BUNDLE, not starting SCTP")
+			return
+		}
+		pc.sctpTransport.setDTLSTransport(dtlsTransport)
+
+		// Start sctp
+		if err := pc.sctpTransport.Start(SCTPCapabilities{
+			MaxMessageSize: 0,
+		}); err != nil {
+			pc.onTransportError(&TransportError{Component: TransportComponentSCTP, Err: err})
 			return
 		}
 
-		pc.openSRTP()
+		// Open data channels that where created before signaling
+		pc.mu.Lock()
+		// make a copy of dataChannels to avoid race condition accessing pc.dataChannels
+		dataChannels := make(map[uint16]*DataChannel, len(pc.dataChannels))
+		for k, v := range pc.dataChannels {
+			dataChannels[k] = v
+		}
+		pc.mu.Unlock()
+
+		var openedDCCount uint32
+		for _, d := range dataChannels {
+			err := d.open(pc.sctpTransport)
+			if err != nil {
+				pc.log.Warnf("failed to open data channel: %s", err)
+				continue
+			}
+			openedDCCount++
+		}
+
+		pc.mu.Lock()
+		pc.dataChannelsOpened += openedDCCount
+		pc.mu.Unlock()
+	}()
+
+	if (desc.Type == SDPTypeAnswer || desc.Type == SDPTypePranswer) && pc.iceGatherer.agentIsTrickle {
+		return pc.iceGatherer.Gather()
+	}
+	return nil
+}
+
+// startTransports starts the ICE and DTLS transports, it blocks until the
+// connection is established or has failed
+func (pc *PeerConnection) startTransports(iceRole ICERole, dtlsRole DTLSRole, remoteUfrag, remotePwd string, remoteIsLite bool, fingerprint, fingerprintHash string) *TransportError {
+	remoteParams := ICEParameters{
+		UsernameFragment: remoteUfrag,
+		Password:         remotePwd,
+		ICELite:          remoteIsLite,
+	}
+	dtlsParams := DTLSParameters{
+		Role:         dtlsRole,
+		Fingerprints: []DTLSFingerprint{{Algorithm: fingerprintHash, Value: fingerprint}},
+	}
+
+	pc.mu.RLock()
+	rtcp := pc.rtcpTransport
+	pc.mu.RUnlock()
+
+	pc.addTransportStates(pc.iceTransport, pc.dtlsTransport)
+	if rtcp != nil {
+		pc.addTransportStates(rtcp.iceTransport, rtcp.dtlsTransport)
+	}
+
+	// Start the ice transport
+	err := pc.iceTransport.Start(pc.iceGatherer, remoteParams, &iceRole)
+	if err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+
+	// Start the dtls transport
+	if err = pc.dtlsTransport.Start(dtlsParams); err != nil {
+		return &TransportError{Component: TransportComponentDTLS, Err: err}
+	}
+
+	if rtcp == nil {
+		return nil
+	}
+
+	// RTCP that isn't multiplexed is sent and received on a component of its own
+	if terr := rtcp.start(remoteParams, iceRole, dtlsParams); terr != nil {
+		return terr
+	}
+	pc.dtlsTransport.setRTCPTransport(rtcp.dtlsTransport)
+	return nil
+}
+
+// renegotiateRemoteDescription applies a remote description that doesn't
+// start the transports. New ICE credentials in a remote offer restart ICE,
+// an answer completes the ICE restart of our own offer.
+// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-24#section-5.10
+func (pc *PeerConnection) renegotiateRemoteDescription(desc *SessionDescription, remoteUfrag, remotePwd string, candidates []ICECandidate) error {
+	iceRestart := pc.iceRestartPending
+	if desc.Type == SDPTypeOffer && pc.currentRemoteDescription != nil {
+		currentUfrag, _, _, err := extractICEDetails(pc.currentRemoteDescription.parsed)
+		if err != nil {
+			return err
+		}
+
+		if currentUfrag != remoteUfrag {
+			// Our answer needs new credentials as well
+			if err = pc.restartICEGatherer(); err != nil {
+				return err
+			}
+			iceRestart = true
+		}
+	}
+
+	if iceRestart {
+		for _, candidate := range candidates {
+			if err := pc.iceTransport.AddRemoteCandidate(candidate); err != nil {
+				return err
+			}
+		}
+	}
+
+	if desc.Type != SDPTypeAnswer {
+		return nil
+	}
+
+	go pc.renegotiateRTP()
+	if iceRestart {
+		return pc.restartICETransport(remoteUfrag, remotePwd)
+	}
+	return nil
+}
+
+// restartICEGatherer creates the agent with new local credentials for an ICE
+// restart. Further offers and answers use it until the restart is complete.
+func (pc *PeerConnection) restartICEGatherer() error {
+	if pc.iceRestartPending {
+		return nil
+	}
+
+	if err := pc.iceGatherer.restart(); err != nil {
+		return err
+	}
+	pc.iceRestartPending = true
+
+	// The RTCP component takes over the new credentials
+	pc.mu.RLock()
+	rtcp := pc.rtcpTransport
+	pc.mu.RUnlock()
+	if rtcp != nil {
+		return rtcp.iceGatherer.restart()
+	}
+	return nil
+}
+
+// restartICETransport connects the agent created by restartICEGatherer to the
+// new remote credentials once the transports have been started
+func (pc *PeerConnection) restartICETransport(remoteUfrag, remotePwd string) error {
+	pc.iceRestartPending = false
+	pc.iceGatherer.completeRestart()
+
+	// The restart is how the application recovers from a failed transport
+	pc.mu.Lock()
+	pc.transportFailed = false
+	pc.mu.Unlock()
+
+	go func() {
+		<-pc.transportsStarted
+
+		if err := pc.iceTransport.restart(ICEParameters{
+			UsernameFragment: remoteUfrag,
+			Password:         remotePwd,
+		}); err != nil {
+			pc.onTransportError(&TransportError{Component: TransportComponentICE, Err: err})
+		}
+	}()
+
+	if err := pc.restartRTCPTransport(remoteUfrag, remotePwd); err != nil {
+		return err
+	}
+
+	if pc.iceGatherer.agentIsTrickle {
+		return pc.iceGatherer.Gather()
+	}
+	return nil
+}
+
+// restartRTCPTransport connects the agent the RTCP component created for the
+// ICE restart, it has the new credentials of the RTP component
+func (pc *PeerConnection) restartRTCPTransport(remoteUfrag, remotePwd string) error {
+	pc.mu.RLock()
+	rtcp := pc.rtcpTransport
+	pc.mu.RUnlock()
+	if rtcp == nil {
+		return nil
+	}
+	rtcp.iceGatherer.completeRestart()
+
+	if media := getTransportMediaDescription(pc.currentRemoteDescription.parsed); media != nil {
+		candidates, err := extractRTCPCandidates(media)
+		if err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			if err = rtcp.iceTransport.AddRemoteCandidate(candidate); err != nil {
+				return err
+			}
+		}
+	}
+
+	go func() {
+		<-pc.transportsStarted
+
+		if terr := rtcp.restart(ICEParameters{
+			UsernameFragment: remoteUfrag,
+			Password:         remotePwd,
+		}); terr != nil {
+			pc.onTransportError(terr)
+		}
+	}()
+	return nil
+}
+
+// renegotiateRTP waits for the transports started by the first remote
+// description and then applies the current one to the RTP streams
+func (pc *PeerConnection) renegotiateRTP() {
+	if pc.transportsStarted == nil {
+		return
+	}
+
+	<-pc.transportsStarted
+	if pc.dtlsTransport.State() != DTLSTransportStateConnected {
+		return
+	}
+
+	pc.startMediaTransports()
+	pc.startRTP()
+}
+
+// startRTP diffs the RemoteDescription against the current transceivers.
+// Receivers and senders that are negotiated for the first time are started,
+// receivers whose remote track went away are stopped and replaced so the
+// transceiver is able to receive again after a later negotiation.
+func (pc *PeerConnection) startRTP() {
+	pc.startRTPLock.Lock()
+	defer pc.startRTPLock.Unlock()
+
+	remoteDesc := pc.RemoteDescription()
+	if remoteDesc == nil || remoteDesc.parsed == nil {
+		return
+	}
+
+	pc.bindMediaTransports(remoteDesc)
+	pc.openSRTP(remoteDesc)
+	pc.startRTPSenders(remoteDesc)
+}
+
+// bindMediaTransports moves the RTPSenders and RTPReceivers that haven't been
+// started yet onto the transport their m-line is negotiated on
+func (pc *PeerConnection) bindMediaTransports(remoteDesc *SessionDescription) {
+	for _, t := range pc.GetTransceivers() {
+		dtlsTransport := pc.getTransceiverTransport(t, remoteDesc)
+		if dtlsTransport == nil {
+			continue
+		}
+
+		if t.Sender != nil {
+			t.Sender.setTransport(dtlsTransport)
+		}
+		if t.Receiver != nil {
+			t.Receiver.setTransport(dtlsTransport)
+		}
+	}
+}
+
+// getDataTransport returns the DTLSTransport the data m-line is negotiated on, or nil
+// if it can't be negotiated without BUNDLE
+func (pc *PeerConnection) getDataTransport() *DTLSTransport {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	remoteDesc := pc.RemoteDescription()
+	if pc.mediaTransports == nil || remoteDesc == nil || remoteDesc.parsed == nil {
+		return pc.dtlsTransport
+	}
 
-		for _, tranceiver := range pc.GetTransceivers() {
-			if tranceiver.Sender != nil {
-				err = tranceiver.Sender.Send(RTPSendParameters{
-					Encodings: RTPEncodingParameters{
-						RTPCodingParameters{
-							SSRC:        tranceiver.Sender.track.SSRC(),
-							PayloadType: tranceiver.Sender.track.PayloadType(),
-						},
-					}})
+	for _, media := range remoteDesc.parsed.MediaDescriptions {
+		if media.MediaName.Media != "application" {
+			continue
+		}
+		if t, ok := pc.mediaTransports[pc.getMidValue(media)]; ok {
+			return t.dtlsTransport
+		}
+	}
+	return nil
+}
 
-				if err != nil {
-					pc.log.Warnf("Failed to start Sender: %s", err)
-				}
+// startRTPSenders starts every RTPSender that is part of the negotiated media
+func (pc *PeerConnection) startRTPSenders(remoteDesc *SessionDescription) {
+	// Plan-B senders all share one media section per kind, so only
+	// Unified Plan senders can be checked against the remote mids
+	negotiated := map[string]*sdp.MediaDescription{}
+	if !pc.descriptionIsPlanB(remoteDesc) {
+		for _, media := range remoteDesc.parsed.MediaDescriptions {
+			if midValue := pc.getMidValue(media); midValue != "" && media.MediaName.Port.Value != 0 {
+				negotiated[midValue] = media
 			}
 		}
+	}
 
-		go pc.drainSRTP()
+	for _, tranceiver := range pc.GetTransceivers() {
+		switch {
+		case tranceiver.Sender == nil || tranceiver.stopped || tranceiver.Sender.hasSent():
+			continue
+		case pc.getTransceiverTransport(tranceiver, remoteDesc) == nil:
+			// The m-line was not negotiated without BUNDLE
+			continue
+		case tranceiver.currentDirection != RTPTransceiverDirection(Unknown) && !tranceiver.currentDirection.hasSend():
+			// The remote peer does not want to receive this media yet
+			continue
+		case len(negotiated) != 0 && negotiated[tranceiver.mid] == nil:
+			continue
+		}
 
-		// Start sctp
-		err = pc.sctpTransport.Start(SCTPCapabilities{
-			MaxMessageSize: 0,
-		})
-		if err != nil {
-			// pion/webrtc#614
-			pc.log.Warnf("Failed to start SCTP: %s", err)
-			return
+		parameters := tranceiver.Sender.GetParameters()
+		if media := negotiated[tranceiver.mid]; media != nil {
+			for uri, id := range getRTPHeaderExtensionIDs(media) {
+				parameters.HeaderExtensions = append(parameters.HeaderExtensions, RTPHeaderExtensionParameters{URI: uri, ID: id})
+			}
 		}
 
-		// Open data channels that where created before signaling
-		pc.mu.Lock()
-		// make a copy of dataChannels to avoid race condition accessing pc.dataChannels
-		dataChannels := make(map[uint16]*DataChannel, len(pc.dataChannels))
-		for k, v := range pc.dataChannels {
-			dataChannels[k] = v
+		// Simulcast encodings are only sent if the remote answered our offer of them
+		if remoteDesc.Type == SDPTypeOffer || !isSimulcastNegotiated(negotiated[tranceiver.mid]) {
+			parameters.Encodings = parameters.Encodings[:1]
 		}
-		pc.mu.Unlock()
 
-		var openedDCCount uint32
-		for _, d := range dataChannels {
-			err := d.open(pc.sctpTransport)
-			if err != nil {
-				pc.log.Warnf("failed to open data channel: %s", err)
-				continue
+		// Retransmissions are only sent with RTX if the remote accepts it for the codec. Simulcast
+		// encodings aren't signaled by SSRC, so the remote couldn't tell their RTX streams apart
+		remoteMedia := negotiated[tranceiver.mid]
+		if remoteMedia == nil {
+			remoteMedia = getMediaOfKind(remoteDesc.parsed, tranceiver.kind)
+		}
+		for i := range parameters.Encodings {
+			if _, ok := getRTXPayloadType(remoteMedia, parameters.Encodings[i].PayloadType); !ok || parameters.Encodings[i].RID != "" {
+				parameters.Encodings[i].RTX = RTPRtxParameters{}
 			}
-			openedDCCount++
 		}
 
-		pc.mu.Lock()
-		pc.dataChannelsOpened += openedDCCount
-		pc.mu.Unlock()
-	}()
+		// Packets are protected by the FEC mechanism both sides support, FlexFEC
+		// is signaled by SSRC as well so simulcast encodings can't use it
+		for i := range parameters.Encodings {
+			fec := &parameters.Encodings[i].FEC
+			if parameters.Encodings[i].RID != "" {
+				fec.SSRC = 0
+			}
+			fec.Mechanism = ""
+			if remoteMedia != nil {
+				fec.Mechanism = getFECMechanism(pc.api.mediaEngine.GetCodecsByKind(tranceiver.kind), remoteMedia, fec.SSRC)
+			}
+			if fec.Mechanism != fecMechanismFlexFEC {
+				fec.SSRC = 0
+			}
+		}
 
-	if (desc.Type == SDPTypeAnswer || desc.Type == SDPTypePranswer) && pc.iceGatherer.agentIsTrickle {
-		return pc.iceGatherer.Gather()
+		tranceiver.Sender.setMid(tranceiver.mid)
+		if err := tranceiver.Sender.Send(parameters); err != nil {
+			pc.log.Warnf("Failed to start Sender: %s", err)
+		}
 	}
-	return nil
 }
 
 func (pc *PeerConnection) descriptionIsPlanB(desc *SessionDescription) bool {
@@ -1047,120 +2596,133 @@ func (pc *PeerConnection) descriptionIsPlanB(desc *SessionDescription) bool {
 	return false
 }
 
+// incomingTrack describes a remote track before it is bound to a RTPReceiver
+type incomingTrack struct {
+	kind  RTPCodecType
+	label string
+	id    string
+	ssrc  uint32
+	mid   string
+	rid   string
+
+	// The SSRC retransmissions arrive with, if the remote sends RTX
+	rtxSSRC uint32
+
+	// The SSRC FlexFEC packets arrive with, if the remote sends FlexFEC
+	fecSSRC uint32
+
+	// Packets that were read from the stream before it was bound, an
+	// unsignaled stream is read until its mid is known
+	probed [][]byte
+}
+
 // openSRTP opens knows inbound SRTP streams from the RemoteDescription
-func (pc *PeerConnection) openSRTP() {
-	type incomingTrack struct {
-		kind  RTPCodecType
-		label string
-		id    string
-		ssrc  uint32
-	}
+func (pc *PeerConnection) openSRTP(remoteDesc *SessionDescription) {
 	incomingTracks := map[uint32]incomingTrack{}
 
+	// Media sections without SSRCs are bound by handleUndeclaredSSRC
+	unsignaledMids := map[string]bool{}
+
 	remoteIsPlanB := false
 	switch pc.configuration.SDPSemantics {
 	case SDPSemanticsPlanB:
 		remoteIsPlanB = true
 	case SDPSemanticsUnifiedPlanWithFallback:
-		remoteIsPlanB = pc.descriptionIsPlanB(pc.RemoteDescription())
+		remoteIsPlanB = pc.descriptionIsPlanB(remoteDesc)
 	}
 
-	for _, media := range pc.RemoteDescription().parsed.MediaDescriptions {
-		for _, attr := range media.Attributes {
+	for _, media := range remoteDesc.parsed.MediaDescriptions {
+		codecType := NewRTPCodecType(media.MediaName.Media)
+		if codecType == 0 || media.MediaName.Port.Value == 0 {
+			continue
+		}
 
-			codecType := NewRTPCodecType(media.MediaName.Media)
-			if codecType == 0 {
-				continue
-			}
+		// Nothing will arrive for media the remote doesn't send
+		switch pc.getPeerDirection(media) {
+		case RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionInactive:
+			continue
+		}
 
+		midValue := pc.getMidValue(media)
+		unsignaledMids[midValue] = true
+		repairSSRCs, rtxSSRCs, fecSSRCs := getRepairSSRCs(media), getRTXSSRCs(media), getFECSSRCs(media)
+		for _, attr := range media.Attributes {
 			if attr.Key == sdp.AttrKeySSRC {
+				delete(unsignaledMids, midValue)
 				split := strings.Split(attr.Value, " ")
 				ssrc, err := strconv.ParseUint(split[0], 10, 32)
 				if err != nil {
 					pc.log.Warnf("Failed to parse SSRC: %v", err)
 					continue
+				} else if repairSSRCs[uint32(ssrc)] {
+					continue
 				}
 
-				trackID := ""
-				trackLabel := ""
+				incoming, ok := incomingTracks[uint32(ssrc)]
+				if !ok {
+					incoming = incomingTrack{kind: codecType, ssrc: uint32(ssrc), mid: midValue, rtxSSRC: rtxSSRCs[uint32(ssrc)], fecSSRC: fecSSRCs[uint32(ssrc)]}
+				}
 				if len(split) == 3 && strings.HasPrefix(split[1], "msid:") {
-					trackLabel = split[1][len("msid:"):]
-					trackID = split[2]
+					incoming.label = split[1][len("msid:"):]
+					incoming.id = split[2]
 				}
 
-				incomingTracks[uint32(ssrc)] = incomingTrack{codecType, trackLabel, trackID, uint32(ssrc)}
-				if trackID != "" && trackLabel != "" {
+				incomingTracks[uint32(ssrc)] = incoming
+				if incoming.id != "" && incoming.label != "" && !remoteIsPlanB {
 					break // Remote provided Label+ID, we have all the information we need
 				}
 			}
 		}
 	}
 
-	startReceiver := func(incoming incomingTrack, receiver *RTPReceiver) {
-		err := receiver.Receive(RTPReceiveParameters{
-			Encodings: RTPDecodingParameters{
-				RTPCodingParameters{SSRC: incoming.ssrc},
-			}})
-		if err != nil {
-			pc.log.Warnf("RTPReceiver Receive failed %s", err)
-			return
-		}
-
-		if err = receiver.Track().determinePayloadType(); err != nil {
-			pc.log.Warnf("Could not determine PayloadType for SSRC %d", receiver.Track().SSRC())
-			return
+	// Receivers keep running as long as the remote still sends their SSRC.
+	// A receiver whose SSRC went away is replaced so the transceiver
+	// can be matched with a new remote track.
+	localTransceivers := []*RTPTransceiver{}
+	for _, t := range pc.GetTransceivers() {
+		dtlsTransport := pc.getTransceiverTransport(t, remoteDesc)
+		if t.Receiver == nil || t.stopped || dtlsTransport == nil {
+			continue
 		}
 
-		pc.mu.RLock()
-		defer pc.mu.RUnlock()
-
-		if pc.currentLocalDescription == nil {
-			pc.log.Warnf("SetLocalDescription not called, unable to handle incoming media streams")
-			return
+		track := t.Receiver.Track()
+		if track == nil {
+			localTransceivers = append(localTransceivers, t)
+			continue
+		} else if _, ok := incomingTracks[track.SSRC()]; ok {
+			delete(incomingTracks, track.SSRC())
+			continue
+		} else if !remoteIsPlanB && unsignaledMids[t.mid] {
+			continue
 		}
 
-		sdpCodec, err := pc.currentLocalDescription.parsed.GetCodecForPayloadType(receiver.Track().PayloadType())
-		if err != nil {
-			pc.log.Warnf("no codec could be found in RemoteDescription for payloadType %d", receiver.Track().PayloadType())
-			return
+		if err := t.Receiver.Stop(); err != nil {
+			pc.log.Warnf("Failed to stop RTPReceiver for SSRC %d: %s", track.SSRC(), err)
 		}
-
-		codec, err := pc.api.mediaEngine.getCodecSDP(sdpCodec)
+		receiver, err := pc.api.NewRTPReceiver(t.kind, dtlsTransport)
 		if err != nil {
-			pc.log.Warnf("codec %s in not registered", sdpCodec)
-			return
-		}
-
-		receiver.Track().mu.Lock()
-		receiver.Track().id = incoming.id
-		receiver.Track().label = incoming.label
-		receiver.Track().kind = codec.Type
-		receiver.Track().codec = codec
-		receiver.Track().mu.Unlock()
-
-		if pc.onTrackHandler != nil {
-			pc.onTrack(receiver.Track(), receiver)
-		} else {
-			pc.log.Warnf("OnTrack unset, unable to handle incoming media streams")
+			pc.log.Warnf("Failed to replace RTPReceiver for SSRC %d: %s", track.SSRC(), err)
+			continue
 		}
+		t.Receiver = receiver
+		localTransceivers = append(localTransceivers, t)
 	}
 
-	localTransceivers := append([]*RTPTransceiver{}, pc.GetTransceivers()...)
 	for ssrc, incoming := range incomingTracks {
 		for i := range localTransceivers {
 			t := localTransceivers[i]
 			switch {
 			case incomingTracks[ssrc].kind != t.kind:
 				continue
-			case t.Direction != RTPTransceiverDirectionRecvonly && t.Direction != RTPTransceiverDirectionSendrecv:
+			case !remoteIsPlanB && incomingTracks[ssrc].mid != t.mid:
 				continue
-			case t.Receiver == nil:
+			case t.Direction != RTPTransceiverDirectionRecvonly && t.Direction != RTPTransceiverDirectionSendrecv:
 				continue
 			}
 
 			delete(incomingTracks, ssrc)
 			localTransceivers = append(localTransceivers[:i], localTransceivers[i+1:]...)
-			go startReceiver(incoming, t.Receiver)
+			pc.receive(incoming, t.Receiver)
 			break
 		}
 	}
@@ -1174,48 +2736,347 @@ func (pc *PeerConnection) openSRTP() {
 				pc.log.Warnf("Could not add transceiver for remote SSRC %d: %s", ssrc, err)
 				continue
 			}
-			go startReceiver(incoming, t.Receiver)
+			pc.mu.Lock()
+			if pc.signalingState == SignalingStateHaveRemoteOffer {
+				pc.remoteOfferTransceivers[t] = true
+			}
+			pc.mu.Unlock()
+			pc.receive(incoming, t.Receiver)
+		}
+	}
+}
+
+// startReceiver reads the first packet of an incoming track to determine its
+// codec and then announces it through OnTrack
+func (pc *PeerConnection) startReceiver(incoming incomingTrack, track *Track, receiver *RTPReceiver) {
+	if err := track.determinePayloadType(); err != nil {
+		pc.log.Warnf("Could not determine PayloadType for SSRC %d", track.SSRC())
+		return
+	}
+
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	if pc.currentLocalDescription == nil {
+		pc.log.Warnf("SetLocalDescription not called, unable to handle incoming media streams")
+		return
+	}
+
+	sdpCodec, err := pc.currentLocalDescription.parsed.GetCodecForPayloadType(track.PayloadType())
+	if err != nil {
+		pc.log.Warnf("no codec could be found in RemoteDescription for payloadType %d", track.PayloadType())
+		return
+	}
+
+	codec, err := pc.api.mediaEngine.getCodecSDP(sdpCodec)
+	if err != nil {
+		pc.log.Warnf("codec %s in not registered", sdpCodec)
+		return
+	}
+
+	track.mu.Lock()
+	track.id = incoming.id
+	track.label = incoming.label
+	track.rid = incoming.rid
+	track.kind = codec.Type
+	track.codec = codec
+	track.mu.Unlock()
+
+	if pc.onTrackHandler != nil {
+		pc.onTrack(track, receiver)
+	} else {
+		pc.log.Warnf("OnTrack unset, unable to handle incoming media streams")
+	}
+}
+
+// receive starts the RTPReceiver for an incoming track
+func (pc *PeerConnection) receive(incoming incomingTrack, receiver *RTPReceiver) {
+	receiver.setRTCPFeedback(func(payloadType uint8) []RTCPFeedback {
+		return pc.getNegotiatedRTCPFeedback(incoming.mid, incoming.kind, payloadType)
+	})
+	err := receiver.Receive(RTPReceiveParameters{
+		Encodings: RTPDecodingParameters{
+			RTPCodingParameters{
+				SSRC: incoming.ssrc,
+				RTX:  RTPRtxParameters{SSRC: incoming.rtxSSRC},
+				FEC:  RTPFecParameters{SSRC: incoming.fecSSRC},
+			},
+		}})
+	if err != nil {
+		pc.log.Warnf("RTPReceiver Receive failed %s", err)
+		return
+	}
+
+	receiver.replay(receiver.Track(), incoming.probed)
+	go pc.startReceiver(incoming, receiver.Track(), receiver)
+}
+
+// handleUndeclaredSSRC binds an incoming SSRC that was not signaled in the
+// RemoteDescription to a RTPTransceiver. The mid is taken from the MID RTP header
+// extension of the first packets, or from the only media section when the
+// remote doesn't send it. The first packet is returned if one had to be read.
+// https://tools.ietf.org/html/rfc8843#section-9.2
+func (pc *PeerConnection) handleUndeclaredSSRC(rtpStream *srtp.ReadStreamSRTP, ssrc uint32) ([][]byte, error) {
+	remoteDesc := pc.RemoteDescription()
+	if remoteDesc == nil || remoteDesc.parsed == nil {
+		return nil, fmt.Errorf("no RemoteDescription")
+	}
+
+	extMaps := map[string]int{}
+	onlyMid, mediaSections := "", 0
+	for _, media := range remoteDesc.parsed.MediaDescriptions {
+		if NewRTPCodecType(media.MediaName.Media) == 0 || media.MediaName.Port.Value == 0 {
+			continue
+		}
+
+		for _, attr := range media.Attributes {
+			if attr.Key != sdp.AttrKeySSRC {
+				continue
+			}
+			// Signaled SSRCs are opened by openSRTP
+			if signaled, err := strconv.ParseUint(strings.Split(attr.Value, " ")[0], 10, 32); err == nil && uint32(signaled) == ssrc {
+				return nil, nil
+			}
+		}
+
+		for uri, id := range getRTPHeaderExtensionIDs(media) {
+			extMaps[uri] = id
+		}
+		onlyMid = pc.getMidValue(media)
+		mediaSections++
+	}
+
+	var mid, rid string
+	var probed [][]byte
+	if extMaps[sdesMidURI] != 0 {
+		b := make([]byte, receiveMTU)
+		for i := 0; i < undeclaredSSRCProbeCount && mid == ""; i++ {
+			n, err := rtpStream.Read(b)
+			if err != nil {
+				return probed, err
+			}
+			probed = append(probed, append([]byte{}, b[:n]...))
+
+			if mid, rid, err = getMidAndRIDFromPacket(b[:n], extMaps); err != nil {
+				return probed, err
+			}
+		}
+	}
+	if mid == "" {
+		if mediaSections != 1 {
+			return probed, fmt.Errorf("no MID RTP header extension for SSRC %d and multiple media sections", ssrc)
+		}
+		mid = onlyMid
+	}
+
+	pc.startRTPLock.Lock()
+	defer pc.startRTPLock.Unlock()
+
+	incoming := incomingTrack{ssrc: ssrc, mid: mid, rid: rid, probed: probed}
+	simulcast := false
+	for _, media := range remoteDesc.parsed.MediaDescriptions {
+		if pc.getMidValue(media) != mid {
+			continue
+		}
+		if msid, ok := media.Attribute("msid"); ok {
+			if split := strings.Split(msid, " "); len(split) == 2 {
+				incoming.label, incoming.id = split[0], split[1]
+			}
+		}
+		simulcast = len(getRids(media)) != 0
+	}
+
+	for _, t := range pc.GetTransceivers() {
+		// Transceivers are associated and stopped by the signaling of the application
+		pc.mu.RLock()
+		transceiverMid, stopped, direction := t.mid, t.stopped, t.Direction
+		pc.mu.RUnlock()
+
+		if transceiverMid != mid || stopped || t.Receiver == nil {
+			continue
+		}
+		for _, track := range t.Receiver.Tracks() {
+			if track.SSRC() == ssrc {
+				t.Receiver.replay(track, probed)
+				return nil, nil
+			}
+		}
+		if direction != RTPTransceiverDirectionRecvonly && direction != RTPTransceiverDirectionSendrecv {
+			continue
+		}
+		incoming.kind = t.kind
+
+		// Every simulcast layer is a Track of the same RTPReceiver
+		if simulcast && rid != "" {
+			t.Receiver.setRTCPFeedback(func(payloadType uint8) []RTCPFeedback {
+				return pc.getNegotiatedRTCPFeedback(incoming.mid, incoming.kind, payloadType)
+			})
+			track, err := t.Receiver.receiveForRID(rid, ssrc)
+			if err != nil {
+				return probed, err
+			}
+			t.Receiver.replay(track, probed)
+			go pc.startReceiver(incoming, track, t.Receiver)
+			return nil, nil
+		} else if t.Receiver.Track() != nil {
+			continue
 		}
+
+		pc.receive(incoming, t.Receiver)
+		return nil, nil
 	}
+
+	return probed, fmt.Errorf("no RTPTransceiver to receive mid %q", mid)
 }
 
-// drainSRTP pulls and discards RTP/RTCP packets that don't match any SRTP
-// These could be sent to the user, but right now we don't provide an API
-// to distribute orphaned RTCP messages. This is needed to make sure we don't block
-// and provides useful debugging messages
-func (pc *PeerConnection) drainSRTP() {
+// drainSRTP accepts the RTP/RTCP streams that don't match any SRTP stream that
+// was opened. RTP streams are bound to a RTPTransceiver if possible, the
+// remaining ones are handed to OnUnhandledRTP and OnUnhandledRTCP.
+func (pc *PeerConnection) drainSRTP(dtlsTransport *DTLSTransport) {
 	go func() {
 		for {
-			srtpSession, err := pc.dtlsTransport.getSRTPSession()
+			srtpSession, err := dtlsTransport.getSRTPSession()
 			if err != nil {
 				pc.log.Warnf("drainSRTP failed to open SrtpSession: %v", err)
 				return
 			}
 
-			_, ssrc, err := srtpSession.AcceptStream()
+			rtpStream, ssrc, err := srtpSession.AcceptStream()
 			if err != nil {
 				pc.log.Warnf("Failed to accept RTP %v \n", err)
 				return
 			}
 
-			pc.log.Debugf("Incoming unhandled RTP ssrc(%d)", ssrc)
+			pc.mu.Lock()
+			pc.probingSSRCs[ssrc] = true
+			pc.mu.Unlock()
+
+			go func() {
+				probed, err := pc.handleUndeclaredSSRC(rtpStream, ssrc)
+
+				pc.mu.Lock()
+				delete(pc.probingSSRCs, ssrc)
+				pc.mu.Unlock()
+
+				if err != nil {
+					pc.onUnhandledRTP(rtpStream, ssrc, probed, err)
+				}
+			}()
 		}
 	}()
 
 	for {
-		srtcpSession, err := pc.dtlsTransport.getSRTCPSession()
+		srtcpSession, err := dtlsTransport.getSRTCPSession()
 		if err != nil {
 			pc.log.Warnf("drainSRTP failed to open SrtcpSession: %v", err)
 			return
 		}
 
-		_, ssrc, err := srtcpSession.AcceptStream()
+		rtcpStream, ssrc, err := srtcpSession.AcceptStream()
 		if err != nil {
 			pc.log.Warnf("Failed to accept RTCP %v \n", err)
 			return
 		}
+		go pc.onUnhandledRTCP(rtcpStream, ssrc)
+	}
+}
+
+// onUnhandledRTP hands a RTP stream that isn't received by any RTPReceiver to
+// the OnUnhandledRTP handler, together with the packets read from it already.
+// Without a handler the stream is closed, nothing would read it otherwise
+func (pc *PeerConnection) onUnhandledRTP(stream *srtp.ReadStreamSRTP, ssrc uint32, packets [][]byte, reason error) {
+	pc.mu.RLock()
+	hdlr := pc.onUnhandledRTPHandler
+	pc.mu.RUnlock()
+
+	if hdlr == nil {
+		pc.log.Debugf("Incoming unhandled RTP ssrc(%d): %v", ssrc, reason)
+		if err := stream.Close(); err != nil {
+			pc.log.Warnf("Failed to close unhandled RTP stream ssrc(%d): %v", ssrc, err)
+		}
+		return
+	}
+
+	if len(packets) == 0 {
+		b := make([]byte, receiveMTU)
+		n, err := stream.Read(b)
+		if err != nil {
+			return
+		}
+		packets = [][]byte{b[:n]}
+	} else if len(packets) > 1 {
+		pc.log.Debugf("Dropping %d RTP packets of ssrc(%d) read while looking for its MID", len(packets)-1, ssrc)
+	}
+	hdlr(stream, ssrc, packets[0])
+}
+
+// onUnhandledRTCP hands a RTCP stream that isn't read by any RTPSender or
+// RTPReceiver to the OnUnhandledRTCP handler, together with its first packet.
+// The stream is closed if there is no handler or if its SSRC is about to be
+// read, so the RTPSender or RTPReceiver opens it once it does
+func (pc *PeerConnection) onUnhandledRTCP(stream *srtp.ReadStreamSRTCP, ssrc uint32) {
+	pc.mu.RLock()
+	hdlr := pc.onUnhandledRTCPHandler
+	pc.mu.RUnlock()
+
+	if hdlr == nil || !pc.isUnboundSSRC(ssrc) {
 		pc.log.Debugf("Incoming unhandled RTCP ssrc(%d)", ssrc)
+		if err := stream.Close(); err != nil {
+			pc.log.Warnf("Failed to close unhandled RTCP stream ssrc(%d): %v", ssrc, err)
+		}
+		return
+	}
+
+	b := make([]byte, receiveMTU)
+	n, err := stream.Read(b)
+	if err != nil {
+		return
+	}
+	hdlr(stream, ssrc, b[:n])
+}
+
+// isUnboundSSRC tells if a SSRC is neither sent by a RTPSender nor received by
+// a RTPReceiver, and won't be as it isn't signaled or being bound either
+func (pc *PeerConnection) isUnboundSSRC(ssrc uint32) bool {
+	pc.mu.RLock()
+	probing, remoteDesc := pc.probingSSRCs[ssrc], pc.RemoteDescription()
+	pc.mu.RUnlock()
+
+	if probing {
+		return false
+	}
+
+	for _, t := range pc.GetTransceivers() {
+		if t.Sender != nil {
+			for _, e := range t.Sender.GetParameters().Encodings {
+				if e.SSRC == ssrc || e.RTX.SSRC == ssrc || e.FEC.SSRC == ssrc {
+					return false
+				}
+			}
+		}
+		if t.Receiver != nil {
+			for _, track := range t.Receiver.Tracks() {
+				if track.SSRC() == ssrc {
+					return false
+				}
+			}
+		}
+	}
+
+	if remoteDesc == nil || remoteDesc.parsed == nil {
+		return true
+	}
+	for _, media := range remoteDesc.parsed.MediaDescriptions {
+		for _, attr := range media.Attributes {
+			if attr.Key != sdp.AttrKeySSRC {
+				continue
+			}
+			if fields := strings.Fields(attr.Value); len(fields) != 0 && fields[0] == strconv.FormatUint(uint64(ssrc), 10) {
+				return false
+			}
+		}
 	}
+	return true
 }
 
 // RemoteDescription returns pendingRemoteDescription if it is not null and
@@ -1248,6 +3109,23 @@ func (pc *PeerConnection) AddICECandidate(candidate ICECandidateInit) error {
 		return err
 	}
 
+	// Candidates of the RTCP component are only of use while it isn't multiplexed
+	if iceCandidate.Component == uint16(ICEComponentRTCP) {
+		pc.mu.RLock()
+		rtcp := pc.rtcpTransport
+		pc.mu.RUnlock()
+		if rtcp != nil {
+			return rtcp.iceTransport.AddRemoteCandidate(iceCandidate)
+		}
+		return nil
+	}
+
+	// m-lines that are not bundled have ICE transports of their own
+	if candidate.SDPMid != nil {
+		if t := pc.getMediaTransport(*candidate.SDPMid); t != nil {
+			return t.iceTransport.AddRemoteCandidate(iceCandidate)
+		}
+	}
 	return pc.iceTransport.AddRemoteCandidate(iceCandidate)
 }
 
@@ -1293,7 +3171,7 @@ func (pc *PeerConnection) GetTransceivers() []*RTPTransceiver {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	return pc.rtpTransceivers
+	return append([]*RTPTransceiver{}, pc.rtpTransceivers...)
 }
 
 // AddTrack adds a Track to the PeerConnection
@@ -1303,12 +3181,12 @@ func (pc *PeerConnection) AddTrack(track *Track) (*RTPSender, error) {
 	}
 	var transceiver *RTPTransceiver
 	for _, t := range pc.GetTransceivers() {
+		// Reuse a transceiver whose Track was removed, if it can send this Track
 		if !t.stopped &&
+			t.kind == track.Kind() &&
 			t.Sender != nil &&
-			!t.Sender.hasSent() &&
-			t.Receiver != nil &&
-			t.Receiver.Track() != nil &&
-			t.Receiver.Track().Kind() == track.Kind() {
+			t.Sender.Track() == nil &&
+			t.Sender.checkTrack(track) == nil {
 			transceiver = t
 			break
 		}
@@ -1335,9 +3213,39 @@ func (pc *PeerConnection) AddTrack(track *Track) (*RTPSender, error) {
 		)
 	}
 
+	pc.updateNegotiationNeededFlag()
 	return transceiver.Sender, nil
 }
 
+// RemoveTrack removes a Track from the PeerConnection. The RTPSender stops sending
+// and the RTPTransceiver direction becomes recvonly or inactive, this requires renegotiation
+func (pc *PeerConnection) RemoveTrack(sender *RTPSender) error {
+	if pc.isClosed {
+		return &rtcerr.InvalidStateError{Err: ErrConnectionClosed}
+	}
+
+	var transceiver *RTPTransceiver
+	for _, t := range pc.GetTransceivers() {
+		if t.Sender == sender {
+			transceiver = t
+			break
+		}
+	}
+
+	if transceiver == nil {
+		return &rtcerr.InvalidAccessError{Err: ErrSenderNotCreatedByConnection}
+	} else if transceiver.stopped || sender.Track() == nil {
+		return nil
+	}
+
+	if err := transceiver.setSendingTrack(nil); err != nil {
+		return err
+	}
+
+	pc.updateNegotiationNeededFlag()
+	return nil
+}
+
 // AddTransceiver Create a new RTCRtpTransceiver and add it to the set of transceivers.
 // Deprecated: Use AddTrack, AddTransceiverFromKind or AddTransceiverFromTrack
 func (pc *PeerConnection) AddTransceiver(trackOrKind RTPCodecType, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
@@ -1375,12 +3283,14 @@ func (pc *PeerConnection) AddTransceiverFromKind(kind RTPCodecType, init ...RtpT
 			return nil, err
 		}
 
-		return pc.newRTPTransceiver(
+		t := pc.newRTPTransceiver(
 			receiver,
 			sender,
 			RTPTransceiverDirectionSendrecv,
 			kind,
-		), nil
+		)
+		pc.updateNegotiationNeededFlag()
+		return t, nil
 
 	case RTPTransceiverDirectionRecvonly:
 		receiver, err := pc.api.NewRTPReceiver(kind, pc.dtlsTransport)
@@ -1388,12 +3298,14 @@ func (pc *PeerConnection) AddTransceiverFromKind(kind RTPCodecType, init ...RtpT
 			return nil, err
 		}
 
-		return pc.newRTPTransceiver(
+		t := pc.newRTPTransceiver(
 			receiver,
 			nil,
 			RTPTransceiverDirectionRecvonly,
 			kind,
-		), nil
+		)
+		pc.updateNegotiationNeededFlag()
+		return t, nil
 	default:
 		return nil, fmt.Errorf("AddTransceiverFromKind currently only supports recvonly and sendrecv")
 	}
@@ -1402,10 +3314,18 @@ func (pc *PeerConnection) AddTransceiverFromKind(kind RTPCodecType, init ...RtpT
 // AddTransceiverFromTrack Creates a new send only transceiver and add it to the set of
 func (pc *PeerConnection) AddTransceiverFromTrack(track *Track, init ...RtpTransceiverInit) (*RTPTransceiver, error) {
 	direction := RTPTransceiverDirectionSendrecv
+	sendEncodings := []RTPEncodingParameters{}
 	if len(init) > 1 {
 		return nil, fmt.Errorf("AddTransceiverFromTrack only accepts one RtpTransceiverInit")
 	} else if len(init) == 1 {
 		direction = init[0].Direction
+		sendEncodings = init[0].SendEncodings
+	}
+
+	// Every encoding is sent from its own Track, so only the encoding of track can be
+	// given here, the others are added with RTPSender.AddEncoding
+	if len(sendEncodings) > 1 {
+		return nil, fmt.Errorf("AddTransceiverFromTrack only accepts the encoding of track, use RTPSender.AddEncoding for others")
 	}
 
 	switch direction {
@@ -1419,26 +3339,36 @@ func (pc *PeerConnection) AddTransceiverFromTrack(track *Track, init ...RtpTrans
 		if err != nil {
 			return nil, err
 		}
+		if len(sendEncodings) == 1 {
+			sender.setFirstEncoding(sendEncodings[0])
+		}
 
-		return pc.newRTPTransceiver(
+		t := pc.newRTPTransceiver(
 			receiver,
 			sender,
 			RTPTransceiverDirectionSendrecv,
 			track.Kind(),
-		), nil
+		)
+		pc.updateNegotiationNeededFlag()
+		return t, nil
 
 	case RTPTransceiverDirectionSendonly:
 		sender, err := pc.api.NewRTPSender(track, pc.dtlsTransport)
 		if err != nil {
 			return nil, err
 		}
+		if len(sendEncodings) == 1 {
+			sender.setFirstEncoding(sendEncodings[0])
+		}
 
-		return pc.newRTPTransceiver(
+		t := pc.newRTPTransceiver(
 			nil,
 			sender,
 			RTPTransceiverDirectionSendonly,
 			track.Kind(),
-		), nil
+		)
+		pc.updateNegotiationNeededFlag()
+		return t, nil
 	default:
 		return nil, fmt.Errorf("AddTransceiverFromTrack currently only supports sendonly and sendrecv")
 	}
@@ -1525,6 +3455,7 @@ func (pc *PeerConnection) CreateDataChannel(label string, options *DataChannelIn
 		}
 	}
 
+	pc.updateNegotiationNeededFlag()
 	return d, nil
 }
 
@@ -1548,41 +3479,103 @@ func (pc *PeerConnection) generateDataChannelID(client bool) (uint16, error) {
 	return 0, &rtcerr.OperationError{Err: ErrMaxDataChannelID}
 }
 
-// SetIdentityProvider is used to configure an identity provider to generate identity assertions
+// SetIdentityProvider selects the IdentityProvider that was registered for a
+// domain with SettingEngine.RegisterIdentityProvider. Descriptions created from
+// then on carry an identity assertion of the local user.
+// https://www.w3.org/TR/webrtc-identity/#dom-rtcpeerconnection-setidentityprovider
 func (pc *PeerConnection) SetIdentityProvider(provider string) error {
-	return fmt.Errorf("TODO SetIdentityProvider")
+	if _, ok := pc.api.settingEngine.identityProviders[provider]; !ok {
+		return &rtcerr.NotSupportedError{Err: ErrIdentityProviderNotRegistered}
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.identityProvider = provider
+	return nil
+}
+
+// PeerIdentity returns the identity of the remote peer, it is nil until an
+// identity assertion of the remote was validated
+// https://www.w3.org/TR/webrtc-identity/#dom-rtcpeerconnection-peeridentity
+func (pc *PeerConnection) PeerIdentity() *IdentityAssertion {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.peerIdentity
 }
 
 // WriteRTCP sends a user provided RTCP packet to the connected peer
 // If no peer is connected the packet is discarded
 func (pc *PeerConnection) WriteRTCP(pkts []rtcp.Packet) error {
+	_, err := pc.interceptorRTCPWriter.Write(pkts, make(interceptor.Attributes))
+	return err
+}
+
+// writeRTCP sends RTCP packets that passed the Interceptors
+func (pc *PeerConnection) writeRTCP(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
 	raw, err := rtcp.Marshal(pkts)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	srtcpSession, err := pc.dtlsTransport.getSRTCPSession()
+	srtcpSession, err := pc.getRTCPTransport(pkts).getSRTCPSession()
 	if err != nil {
-		return nil
+		return 0, nil
 	}
 
 	writeStream, err := srtcpSession.OpenWriteStream()
 	if err != nil {
-		return fmt.Errorf("WriteRTCP failed to open WriteStream: %v", err)
+		return 0, fmt.Errorf("WriteRTCP failed to open WriteStream: %v", err)
 	}
+	return writeStream.Write(raw)
+}
 
-	if _, err := writeStream.Write(raw); err != nil {
-		return err
+// getRTCPTransport returns the DTLSTransport of the media the RTCP packets are about,
+// this is only different from the one of the PeerConnection when BUNDLE is not used
+func (pc *PeerConnection) getRTCPTransport(pkts []rtcp.Packet) *DTLSTransport {
+	if len(pkts) == 0 || len(pkts[0].DestinationSSRC()) == 0 {
+		return pc.dtlsTransport
 	}
-	return nil
+
+	ssrc := pkts[0].DestinationSSRC()[0]
+	for _, t := range pc.GetTransceivers() {
+		if t.Receiver != nil {
+			for _, track := range t.Receiver.Tracks() {
+				if track.SSRC() == ssrc {
+					return t.Receiver.Transport()
+				}
+			}
+		}
+		if t.Sender != nil {
+			for _, encoding := range t.Sender.GetParameters().Encodings {
+				if encoding.SSRC == ssrc {
+					return t.Sender.Transport()
+				}
+			}
+		}
+	}
+	return pc.dtlsTransport
 }
 
 // Close ends the PeerConnection
 func (pc *PeerConnection) Close() error {
+	// The closed flag is checked and set at once, the transports report their
+	// state changes concurrently and ignore them once it is set
+	pc.mu.Lock()
+
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #2)
 	if pc.isClosed {
+		pc.mu.Unlock()
 		return nil
 	}
+
+	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #3)
+	pc.isClosed = true
+
+	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #4)
+	pc.signalingState = SignalingStateClosed
+	pc.mu.Unlock()
+	close(pc.reportsDone)
+
 	// Try closing everything and collect the errors
 	// Shutdown strategy:
 	// 1. All Conn close by closing their underlying Conn.
@@ -1591,12 +3584,6 @@ func (pc *PeerConnection) Close() error {
 	//    continue the chain the Mux has to be closed.
 	var closeErrs []error
 
-	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #3)
-	pc.isClosed = true
-
-	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #4)
-	pc.signalingState = SignalingStateClosed
-
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #11)
 	if pc.iceTransport != nil {
 		if err := pc.iceTransport.Stop(); err != nil {
@@ -1605,23 +3592,50 @@ func (pc *PeerConnection) Close() error {
 	}
 
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-close (step #12)
+	pc.mu.Lock()
 	pc.connectionState = PeerConnectionStateClosed
+	pc.mu.Unlock()
 
 	if err := pc.dtlsTransport.Stop(); err != nil {
 		closeErrs = append(closeErrs, err)
 	}
 
+	pc.mu.RLock()
+	rtcp := pc.rtcpTransport
+	pc.mu.RUnlock()
+	if rtcp != nil {
+		if err := rtcp.stop(); err != nil {
+			closeErrs = append(closeErrs, err)
+		}
+	}
+
 	if pc.sctpTransport != nil {
 		if err := pc.sctpTransport.Stop(); err != nil {
 			closeErrs = append(closeErrs, err)
 		}
 	}
 
+	pc.mu.RLock()
+	mediaTransports := pc.mediaTransports
+	pc.mu.RUnlock()
+	for _, t := range mediaTransports {
+		if t.dtlsTransport == pc.dtlsTransport {
+			continue
+		}
+		if err := t.stop(); err != nil {
+			closeErrs = append(closeErrs, err)
+		}
+	}
+
 	for _, t := range pc.rtpTransceivers {
-		if err := t.Stop(); err != nil {
+		if err := t.stop(); err != nil {
 			closeErrs = append(closeErrs, err)
 		}
 	}
+
+	if err := pc.api.interceptor.Close(); err != nil {
+		closeErrs = append(closeErrs, err)
+	}
 	return util.FlattenErrs(closeErrs)
 }
 
@@ -1645,7 +3659,55 @@ func (pc *PeerConnection) addFingerprint(d *sdp.SessionDescription) error {
 	return nil
 }
 
-func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, transceivers ...*RTPTransceiver) error {
+// addIdentity asserts the identity of the local user for the fingerprints of a
+// description once an IdentityProvider was selected
+// https://tools.ietf.org/html/rfc8827#section-7.4
+func (pc *PeerConnection) addIdentity(d *sdp.SessionDescription) error {
+	pc.mu.RLock()
+	domain := pc.identityProvider
+	pc.mu.RUnlock()
+	if domain == "" {
+		return nil
+	}
+
+	value, err := newIdentityAttribute(domain, pc.api.settingEngine.identityProviders[domain], d)
+	if err != nil {
+		return &rtcerr.OperationError{Err: err}
+	}
+	d.WithValueAttribute(sdp.AttrKeyIdentity, value)
+	return nil
+}
+
+// checkIdentity validates the identity assertion of a remote description. It's
+// required once the configuration has a PeerIdentity or the identity of the
+// peer is known, which can't change from then on
+// https://www.w3.org/TR/webrtc-identity/#verifying-identity-assertions
+func (pc *PeerConnection) checkIdentity(desc *sdp.SessionDescription) (*IdentityAssertion, error) {
+	pc.mu.RLock()
+	expected := pc.configuration.PeerIdentity
+	if pc.peerIdentity != nil {
+		expected = pc.peerIdentity.Name
+	}
+	pc.mu.RUnlock()
+
+	value, ok := desc.Attribute(sdp.AttrKeyIdentity)
+	if !ok {
+		if expected != "" {
+			return nil, &rtcerr.OperationError{Err: ErrNoIdentityAssertion}
+		}
+		return nil, nil
+	}
+
+	identity, err := validateIdentityAttribute(pc.api.settingEngine.identityProviders, value, desc)
+	if err != nil {
+		return nil, &rtcerr.OperationError{Err: err}
+	} else if expected != "" && identity.Name != expected {
+		return nil, &rtcerr.OperationError{Err: ErrPeerIdentityMismatch}
+	}
+	return identity, nil
+}
+
+func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole, direction RTPTransceiverDirection, remoteMedia *sdp.MediaDescription, transceivers ...*RTPTransceiver) error {
 	if len(transceivers) < 1 {
 		return fmt.Errorf("addTransceiverSDP() called with 0 transceivers")
 	}
@@ -1654,35 +3716,65 @@ func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue
 	media := sdp.NewJSEPMediaDescription(t.kind.String(), []string{}).
 		WithValueAttribute(sdp.AttrKeyConnectionSetup, dtlsRole.String()).
 		WithValueAttribute(sdp.AttrKeyMID, midValue).
-		WithICECredentials(iceParams.UsernameFragment, iceParams.Password).
-		WithPropertyAttribute(sdp.AttrKeyRTCPMux).
-		WithPropertyAttribute(sdp.AttrKeyRTCPRsize)
+		WithICECredentials(iceParams.UsernameFragment, iceParams.Password)
+
+	// Offers always multiplex RTCP, answers only if the offer did
+	if remoteMedia == nil || haveRTCPMux(pc.RemoteDescription().parsed, remoteMedia) {
+		media.WithPropertyAttribute(sdp.AttrKeyRTCPMux)
+	}
+	media.WithPropertyAttribute(sdp.AttrKeyRTCPRsize)
 
-	codecs := pc.api.mediaEngine.GetCodecsByKind(t.kind)
+	codecs := t.codecs
+	if len(codecs) == 0 {
+		codecs = pc.api.mediaEngine.GetCodecsByKind(t.kind)
+	}
 	for _, codec := range codecs {
 		media.WithCodec(codec.PayloadType, codec.Name, codec.ClockRate, codec.Channels, codec.SDPFmtpLine)
 
 		for _, feedback := range codec.RTPCodecCapability.RTCPFeedback {
-			media.WithValueAttribute("rtcp-fb", fmt.Sprintf("%d %s %s", codec.PayloadType, feedback.Type, feedback.Parameter))
+			media.WithValueAttribute("rtcp-fb", strings.TrimSpace(fmt.Sprintf("%d %s %s", codec.PayloadType, feedback.Type, feedback.Parameter)))
 		}
 	}
 	if len(codecs) == 0 {
 		// Explicitly reject track if we don't have the codec
-		d.WithMedia(&sdp.MediaDescription{
-			MediaName: sdp.MediaName{
-				Media:   t.kind.String(),
-				Port:    sdp.RangedPort{Value: 0},
-				Protos:  []string{"UDP", "TLS", "RTP", "SAVPF"},
-				Formats: []string{"0"},
-			},
-		})
+		addRejectedMediaSection(d, t.kind.String(), midValue)
 		return nil
 	}
 
+	// Offers use our own extension ids, answers accept what was offered
+	if remoteMedia == nil {
+		addRTPHeaderExtensions(media, defaultRTPHeaderExtensionIDs())
+	} else {
+		addRTPHeaderExtensions(media, getRTPHeaderExtensionIDs(remoteMedia))
+		addSimulcastRecv(media, getRids(remoteMedia))
+	}
+
 	for _, mt := range transceivers {
-		if mt.Sender != nil && mt.Sender.track != nil {
-			track := mt.Sender.track
-			media = media.WithMediaSource(track.SSRC(), track.Label() /* cname */, track.Label() /* streamLabel */, track.ID())
+		if mt.Sender != nil && mt.Sender.Track() != nil {
+			track := mt.Sender.Track()
+			parameters := mt.Sender.GetParameters()
+
+			// Simulcast is only offered with Unified Plan, its encodings are identified by RID instead of SSRC
+			if remoteMedia == nil && mt.Sender.isSimulcast() && pc.configuration.SDPSemantics == SDPSemanticsUnifiedPlan {
+				addSimulcastSend(media, parameters.Encodings)
+			} else {
+				encoding := parameters.Encodings[0]
+				rtx := encoding.RTX.SSRC != 0 && sendsRTX(codecs, remoteMedia, encoding.PayloadType)
+				if rtx {
+					media = media.WithValueAttribute(sdp.AttrKeySSRCGroup, fmt.Sprintf("FID %d %d", encoding.SSRC, encoding.RTX.SSRC))
+				}
+				flexfec := encoding.FEC.SSRC != 0 && getFECMechanism(codecs, remoteMedia, encoding.FEC.SSRC) == fecMechanismFlexFEC
+				if flexfec {
+					media = media.WithValueAttribute(sdp.AttrKeySSRCGroup, fmt.Sprintf("FEC-FR %d %d", encoding.SSRC, encoding.FEC.SSRC))
+				}
+				media = media.WithMediaSource(encoding.SSRC, track.Label() /* cname */, track.Label() /* streamLabel */, track.ID())
+				if rtx {
+					media = media.WithMediaSource(encoding.RTX.SSRC, track.Label() /* cname */, track.Label() /* streamLabel */, track.ID())
+				}
+				if flexfec {
+					media = media.WithMediaSource(encoding.FEC.SSRC, track.Label() /* cname */, track.Label() /* streamLabel */, track.ID())
+				}
+			}
 			if pc.configuration.SDPSemantics == SDPSemanticsUnifiedPlan {
 				media = media.WithPropertyAttribute("msid:" + track.Label() + " " + track.ID())
 				break
@@ -1690,7 +3782,7 @@ func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue
 		}
 	}
 
-	media = media.WithPropertyAttribute(t.Direction.String())
+	media = media.WithPropertyAttribute(direction.String())
 
 	addCandidatesToMediaDescriptions(candidates, media)
 	d.WithMedia(media)
@@ -1698,6 +3790,23 @@ func (pc *PeerConnection) addTransceiverSDP(d *sdp.SessionDescription, midValue
 	return nil
 }
 
+// addRejectedMediaSection adds a media section with a zero port, this is how
+// JSEP rejects or stops a media section without changing the m-line order
+func addRejectedMediaSection(d *sdp.SessionDescription, kind, midValue string) {
+	media := &sdp.MediaDescription{
+		MediaName: sdp.MediaName{
+			Media:   kind,
+			Port:    sdp.RangedPort{Value: 0},
+			Protos:  []string{"UDP", "TLS", "RTP", "SAVPF"},
+			Formats: []string{"0"},
+		},
+	}
+	if midValue != "" {
+		media = media.WithValueAttribute(sdp.AttrKeyMID, midValue)
+	}
+	d.WithMedia(media)
+}
+
 func (pc *PeerConnection) addDataMediaSection(d *sdp.SessionDescription, midValue string, iceParams ICEParameters, candidates []ICECandidate, dtlsRole sdp.ConnectionRole) {
 	media := (&sdp.MediaDescription{
 		MediaName: sdp.MediaName{
@@ -1744,10 +3853,12 @@ func (pc *PeerConnection) newRTPTransceiver(
 ) *RTPTransceiver {
 
 	t := &RTPTransceiver{
-		Receiver:  receiver,
-		Sender:    sender,
-		Direction: direction,
-		kind:      kind,
+		Receiver:                receiver,
+		Sender:                  sender,
+		Direction:               direction,
+		kind:                    kind,
+		api:                     pc.api,
+		updateNegotiationNeeded: pc.updateNegotiationNeededFlag,
 	}
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
@@ -1755,6 +3866,28 @@ func (pc *PeerConnection) newRTPTransceiver(
 	return t
 }
 
+// getLocalCandidates returns the candidates of the transports of the PeerConnection,
+// with the ones of the RTCP component while RTCP may not be multiplexed
+func (pc *PeerConnection) getLocalCandidates() ([]ICECandidate, error) {
+	candidates, err := pc.iceGatherer.GetLocalCandidates()
+	if err != nil {
+		return nil, err
+	}
+
+	pc.mu.RLock()
+	rtcp := pc.rtcpTransport
+	pc.mu.RUnlock()
+	if rtcp == nil {
+		return candidates, nil
+	}
+
+	rtcpCandidates, err := rtcp.iceGatherer.GetLocalCandidates()
+	if err != nil {
+		return nil, err
+	}
+	return append(candidates, rtcpCandidates...), nil
+}
+
 func (pc *PeerConnection) populateLocalCandidates(orig *SessionDescription) *SessionDescription {
 	if orig == nil {
 		return nil
@@ -1762,13 +3895,21 @@ func (pc *PeerConnection) populateLocalCandidates(orig *SessionDescription) *Ses
 		return orig
 	}
 
-	candidates, err := pc.iceGatherer.GetLocalCandidates()
+	candidates, err := pc.getLocalCandidates()
 	if err != nil {
 		return orig
 	}
 
-	parsed := pc.pendingLocalDescription.parsed
+	// The candidates are added to a copy, so the stored description never has them
+	parsed := &sdp.SessionDescription{}
+	if err = parsed.Unmarshal([]byte(orig.SDP)); err != nil {
+		return orig
+	}
 	for _, m := range parsed.MediaDescriptions {
+		// m-lines with transports of their own already have all their candidates
+		if t := pc.getMediaTransport(pc.getMidValue(m)); t != nil && t.dtlsTransport != pc.dtlsTransport {
+			continue
+		}
 		addCandidatesToMediaDescriptions(candidates, m)
 	}
 	sdp, err := parsed.Marshal()
@@ -1777,8 +3918,9 @@ func (pc *PeerConnection) populateLocalCandidates(orig *SessionDescription) *Ses
 	}
 
 	return &SessionDescription{
-		SDP:  string(sdp),
-		Type: pc.pendingLocalDescription.Type,
+		SDP:    string(sdp),
+		Type:   orig.Type,
+		parsed: parsed,
 	}
 }
 
@@ -1818,6 +3960,9 @@ func (pc *PeerConnection) PendingRemoteDescription() *SessionDescription {
 // SignalingState attribute returns the signaling state of the
 // PeerConnection instance.
 func (pc *PeerConnection) SignalingState() SignalingState {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
 	return pc.signalingState
 }
 
@@ -1837,6 +3982,9 @@ func (pc *PeerConnection) ICEGatheringState() ICEGatheringState {
 // ConnectionState attribute returns the connection state of the
 // PeerConnection instance.
 func (pc *PeerConnection) ConnectionState() PeerConnectionState {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
 	return pc.connectionState
 }
 
@@ -1875,15 +4023,313 @@ func (pc *PeerConnection) GetStats() StatsReport {
 }
 
 func addCandidatesToMediaDescriptions(candidates []ICECandidate, m *sdp.MediaDescription) {
+	// RTCP is announced on the RTP candidates unless it has a component of its own
+	haveRTCPComponent := false
+	for _, c := range candidates {
+		if c.Component == uint16(ICEComponentRTCP) {
+			haveRTCPComponent = true
+		}
+	}
+
+	haveRTPCandidates := false
 	for _, c := range candidates {
 		sdpCandidate := iceCandidateToSDP(c)
 		sdpCandidate.ExtensionAttributes = append(sdpCandidate.ExtensionAttributes, sdp.ICECandidateAttribute{Key: "generation", Value: "0"})
-		sdpCandidate.Component = 1
-		m.WithICECandidate(sdpCandidate)
-		sdpCandidate.Component = 2
+		if c.Component == uint16(ICEComponentRTCP) {
+			m.WithICECandidate(sdpCandidate)
+			continue
+		}
+
+		haveRTPCandidates = true
+		sdpCandidate.Component = uint16(ICEComponentRTP)
 		m.WithICECandidate(sdpCandidate)
+		if !haveRTCPComponent {
+			sdpCandidate.Component = uint16(ICEComponentRTCP)
+			m.WithICECandidate(sdpCandidate)
+		}
 	}
-	if len(candidates) != 0 {
+	if haveRTPCandidates {
 		m.WithPropertyAttribute("end-of-candidates")
 	}
 }
+
+// extractFingerprint returns the DTLS fingerprint and its hash function of an m-line.
+// Without an m-line, or if it has none, the one of the session or any m-line is used
+func extractFingerprint(desc *sdp.SessionDescription, media *sdp.MediaDescription) (fingerprint, hash string, err error) {
+	haveFingerprint := false
+	if media != nil {
+		fingerprint, haveFingerprint = media.Attribute("fingerprint")
+	}
+	if !haveFingerprint {
+		fingerprint, haveFingerprint = desc.Attribute("fingerprint")
+	}
+	for _, m := range desc.MediaDescriptions {
+		if !haveFingerprint {
+			fingerprint, haveFingerprint = m.Attribute("fingerprint")
+		}
+	}
+
+	if !haveFingerprint {
+		return "", "", fmt.Errorf("could not find fingerprint")
+	}
+
+	parts := strings.Split(fingerprint, " ")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid fingerprint")
+	}
+	return parts[1], parts[0], nil
+}
+
+// addOfferToReceiveTransceivers adds the recvonly transceivers the legacy
+// OfferToReceiveAudio and OfferToReceiveVideo options ask for
+// https://www.w3.org/TR/webrtc/#legacy-configuration-extensions
+func (pc *PeerConnection) addOfferToReceiveTransceivers(options *OfferOptions) error {
+	for _, offerToReceive := range []struct {
+		kind    RTPCodecType
+		enabled bool
+	}{
+		{RTPCodecTypeAudio, options.OfferToReceiveAudio},
+		{RTPCodecTypeVideo, options.OfferToReceiveVideo},
+	} {
+		if !offerToReceive.enabled {
+			continue
+		}
+
+		receiving := false
+		for _, t := range pc.GetTransceivers() {
+			if t.kind == offerToReceive.kind && !t.stopped &&
+				(t.Direction == RTPTransceiverDirectionSendrecv || t.Direction == RTPTransceiverDirectionRecvonly) {
+				receiving = true
+				break
+			}
+		}
+		if receiving {
+			continue
+		}
+
+		// The offer that is being created negotiates the transceiver, so unlike
+		// AddTransceiverFromKind this doesn't fire OnNegotiationNeeded
+		receiver, err := pc.api.NewRTPReceiver(offerToReceive.kind, pc.dtlsTransport)
+		if err != nil {
+			return err
+		}
+		pc.newRTPTransceiver(receiver, nil, RTPTransceiverDirectionRecvonly, offerToReceive.kind)
+	}
+	return nil
+}
+
+// removeComfortNoise removes the comfort noise codec from the audio m-lines of
+// a description, which is how voice activity detection is turned off
+// https://tools.ietf.org/html/rfc8829#section-5.2.3.2
+func removeComfortNoise(d *sdp.SessionDescription) {
+	for _, media := range d.MediaDescriptions {
+		if media.MediaName.Media != "audio" {
+			continue
+		}
+
+		comfortNoise := map[string]bool{}
+		for _, attr := range media.Attributes {
+			if attr.Key != "rtpmap" {
+				continue
+			}
+			if fields := strings.Fields(attr.Value); len(fields) == 2 && strings.HasPrefix(strings.ToUpper(fields[1]), CN+"/") {
+				comfortNoise[fields[0]] = true
+			}
+		}
+		if len(comfortNoise) == 0 {
+			continue
+		}
+
+		formats := []string{}
+		for _, format := range media.MediaName.Formats {
+			if !comfortNoise[format] {
+				formats = append(formats, format)
+			}
+		}
+		media.MediaName.Formats = formats
+
+		attributes := []sdp.Attribute{}
+		for _, attr := range media.Attributes {
+			switch attr.Key {
+			case "rtpmap", "fmtp", "rtcp-fb":
+				if fields := strings.Fields(attr.Value); len(fields) > 0 && comfortNoise[fields[0]] {
+					continue
+				}
+			}
+			attributes = append(attributes, attr)
+		}
+		media.Attributes = attributes
+	}
+}
+
+// mungeDescription lets a munger of the SettingEngine modify a description, it
+// fails if the munger changed what the PeerConnection keeps state for
+func mungeDescription(munger func(SDPType, *sdp.SessionDescription) error, sdpType SDPType, desc *sdp.SessionDescription) error {
+	if munger == nil {
+		return nil
+	}
+
+	invariants := getMungingInvariants(desc)
+	if err := munger(sdpType, desc); err != nil {
+		return err
+	} else if !reflect.DeepEqual(invariants, getMungingInvariants(desc)) {
+		return &rtcerr.InvalidModificationError{Err: ErrMungedDescriptionInvalid}
+	}
+	return nil
+}
+
+// checkLocalModifications verifies that the application didn't change what the
+// PeerConnection keeps state for in the last offer or answer it created
+// https://tools.ietf.org/html/rfc8829#section-5.4
+func (pc *PeerConnection) checkLocalModifications(desc *SessionDescription) error {
+	var created string
+	switch desc.Type {
+	case SDPTypeAnswer, SDPTypePranswer:
+		created = pc.lastAnswer
+	case SDPTypeOffer:
+		created = pc.lastOffer
+	}
+	if created == "" || created == desc.SDP {
+		return nil
+	}
+
+	parsed := &sdp.SessionDescription{}
+	if err := parsed.Unmarshal([]byte(created)); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(getMungingInvariants(parsed), getMungingInvariants(desc.parsed)) {
+		return &rtcerr.InvalidModificationError{Err: ErrMungedDescriptionInvalid}
+	}
+	return nil
+}
+
+// getMungingInvariants returns the parts of a description a munger can't change:
+// the m-lines with their mids, the BUNDLE group, and the ICE credentials, DTLS
+// fingerprint and setup role every m-line ends up with, whether they are set
+// on the m-line or the session
+func getMungingInvariants(desc *sdp.SessionDescription) []string {
+	invariants := []string{"BUNDLE " + strings.Join(getBundleGroup(desc), " ")}
+	getAttribute := func(media *sdp.MediaDescription, key string) string {
+		if value, ok := media.Attribute(key); ok {
+			return value
+		}
+		value, _ := desc.Attribute(key)
+		return value
+	}
+
+	for i, media := range desc.MediaDescriptions {
+		fingerprint, hash, _ := extractFingerprint(desc, media)
+		invariants = append(invariants, fmt.Sprintf("%d %s rejected=%t mid=%s ice-ufrag=%s ice-pwd=%s setup=%s fingerprint=%s %s",
+			i, media.MediaName.Media, media.MediaName.Port.Value == 0, getAttribute(media, sdp.AttrKeyMID),
+			getAttribute(media, "ice-ufrag"), getAttribute(media, "ice-pwd"), getAttribute(media, sdp.AttrKeyConnectionSetup), hash, fingerprint))
+	}
+	return invariants
+}
+
+// newPeerConnectionStateFromTransports aggregates the states of the ICE and DTLS
+// transports of a PeerConnection
+// https://www.w3.org/TR/webrtc/#rtcpeerconnectionstate-enum
+func newPeerConnectionStateFromTransports(iceStates map[*ICETransport]ICETransportState, dtlsStates map[*DTLSTransport]DTLSTransportState) PeerConnectionState {
+	var failed, disconnected, connecting, connected, isNew bool
+	for _, state := range iceStates {
+		switch state {
+		case ICETransportStateFailed:
+			failed = true
+		case ICETransportStateDisconnected:
+			disconnected = true
+		case ICETransportStateChecking:
+			connecting = true
+		case ICETransportStateConnected, ICETransportStateCompleted:
+			connected = true
+		case ICETransportStateNew:
+			isNew = true
+		}
+	}
+	for _, state := range dtlsStates {
+		switch state {
+		case DTLSTransportStateFailed:
+			failed = true
+		case DTLSTransportStateConnecting:
+			connecting = true
+		case DTLSTransportStateConnected:
+			connected = true
+		case DTLSTransportStateNew:
+			isNew = true
+		}
+	}
+
+	switch {
+	case failed:
+		return PeerConnectionStateFailed
+	case disconnected:
+		return PeerConnectionStateDisconnected
+	case !connecting && !connected:
+		// All transports are new or closed, or there are none
+		return PeerConnectionStateNew
+	case !connecting && !isNew:
+		return PeerConnectionStateConnected
+	default:
+		return PeerConnectionStateConnecting
+	}
+}
+
+// isICELite tells if a SessionDescription is from an ICE-lite agent
+// https://tools.ietf.org/html/rfc8839#section-5.3
+func isICELite(desc *sdp.SessionDescription) bool {
+	_, ok := desc.Attribute(sdp.AttrKeyICELite)
+	return ok
+}
+
+// getICERole returns the role of the local ICE agent. A full agent controls a
+// lite one, otherwise the agent of the offerer is controlling
+// https://tools.ietf.org/html/rfc8445#section-6.1.1
+func getICERole(weOffer, localIsLite, remoteIsLite bool) ICERole {
+	switch {
+	case localIsLite && !remoteIsLite:
+		return ICERoleControlled
+	case !localIsLite && remoteIsLite:
+		return ICERoleControlling
+	case weOffer:
+		return ICERoleControlling
+	default:
+		return ICERoleControlled
+	}
+}
+
+// extractICEDetails returns the ICE credentials and candidates of a remote
+// SessionDescription, these are the ones of the m-line carrying its transport
+func extractICEDetails(desc *sdp.SessionDescription) (remoteUfrag, remotePwd string, candidates []ICECandidate, err error) {
+	m := getTransportMediaDescription(desc)
+	if m == nil {
+		return "", "", nil, nil
+	}
+	return extractMediaICEDetails(m)
+}
+
+// extractMediaICEDetails returns the ICE credentials and candidates of a remote m-line,
+// candidates of the RTCP component are left out
+func extractMediaICEDetails(m *sdp.MediaDescription) (remoteUfrag, remotePwd string, candidates []ICECandidate, err error) {
+	for _, a := range m.Attributes {
+		switch {
+		case a.IsICECandidate():
+			var sdpCandidate sdp.ICECandidate
+			if sdpCandidate, err = a.ToICECandidate(); err != nil {
+				return "", "", nil, err
+			} else if sdpCandidate.Component == uint16(ICEComponentRTCP) {
+				continue
+			}
+
+			var candidate ICECandidate
+			if candidate, err = newICECandidateFromSDP(sdpCandidate); err != nil {
+				return "", "", nil, err
+			}
+			candidates = append(candidates, candidate)
+		case strings.HasPrefix(*a.String(), "ice-ufrag"):
+			remoteUfrag = (*a.String())[len("ice-ufrag:"):]
+		case strings.HasPrefix(*a.String(), "ice-pwd"):
+			remotePwd = (*a.String())[len("ice-pwd:"):]
+		}
+	}
+
+	return remoteUfrag, remotePwd, candidates, nil
+}
@@ -11,10 +11,12 @@ import (
 	"math/big"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pion/ice"
+	"github.com/pion/sdp/v2"
 	"github.com/pion/transport/test"
 	"github.com/pion/webrtc/v2/pkg/rtcerr"
 	"github.com/stretchr/testify/assert"
@@ -362,6 +364,26 @@ func TestPeerConnection_PeropertyGetters(t *testing.T) {
 	assert.Equal(t, pc.connectionState, pc.ConnectionState(), "should match")
 }
 
+func TestPeerConnection_PopulateLocalCandidates(t *testing.T) {
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	// The candidates are added to every call, not to the stored description
+	stored := pcOffer.currentLocalDescription.SDP
+	first := pcOffer.CurrentLocalDescription()
+	assert.Contains(t, first.SDP, "a=candidate:")
+	assert.Equal(t, first.SDP, pcOffer.CurrentLocalDescription().SDP)
+	assert.Equal(t, stored, pcOffer.currentLocalDescription.SDP)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
 func TestPeerConnection_AnswerWithoutOffer(t *testing.T) {
 	pc, err := NewPeerConnection(Configuration{})
 	if err != nil {
@@ -487,3 +509,834 @@ func TestOneAttrKeyConnectionSetupPerMediaDescriptionInSDP(t *testing.T) {
 	// 5 because a datachannel is always added
 	assert.Len(t, matches, 5)
 }
+
+func TestPeerConnection_OnNegotiationNeeded(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	negotiationNeeded := make(chan struct{}, 10)
+	pcOffer.OnNegotiationNeeded(func() {
+		negotiationNeeded <- struct{}{}
+	})
+
+	// Adding a transceiver and a DataChannel only fires once
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.CreateDataChannel("data", nil); err != nil {
+		t.Fatal(err)
+	}
+	<-negotiationNeeded
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcOffer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not fired while the signaling state isn't stable
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, len(negotiationNeeded))
+
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	} else if err = pcOffer.SetRemoteDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+
+	// The audio transceiver still has to be negotiated
+	<-negotiationNeeded
+
+	if err = renegotiatePair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, pcOffer.negotiationNeeded)
+
+	// Stopping a negotiated transceiver requires negotiation again
+	if err = pcOffer.GetTransceivers()[0].Stop(); err != nil {
+		t.Fatal(err)
+	}
+	<-negotiationNeeded
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_Rollback(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcPolite, pcImpolite, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolling back from stable is not allowed
+	assert.Error(t, pcPolite.SetRemoteDescription(SessionDescription{Type: SDPTypeRollback}))
+
+	transceiver, err := pcPolite.AddTransceiverFromKind(RTPCodecTypeAudio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcImpolite.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both sides offer at the same time, the mid is only proposed by the
+	// offer until it is set
+	politeOffer, err := pcPolite.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, transceiver.mid)
+	if err = pcPolite.SetLocalDescription(politeOffer); err != nil {
+		t.Fatal(err)
+	}
+	impoliteOffer, err := pcImpolite.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcImpolite.SetLocalDescription(impoliteOffer); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, transceiver.mid)
+
+	// The polite side backs off and takes the other offer
+	if err = pcPolite.SetLocalDescription(SessionDescription{Type: SDPTypeRollback}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SignalingStateStable, pcPolite.SignalingState())
+	assert.Nil(t, pcPolite.PendingLocalDescription())
+	assert.Empty(t, transceiver.mid)
+
+	if err = pcPolite.SetRemoteDescription(impoliteOffer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := pcPolite.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcPolite.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	} else if err = pcImpolite.SetRemoteDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SignalingStateStable, pcPolite.SignalingState())
+	assert.Equal(t, SignalingStateStable, pcImpolite.SignalingState())
+
+	// A remote offer can be rolled back as well
+	politeOffer, err = pcPolite.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcImpolite.SetRemoteDescription(politeOffer); err != nil {
+		t.Fatal(err)
+	} else if err = pcImpolite.SetRemoteDescription(SessionDescription{Type: SDPTypeRollback}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, SignalingStateStable, pcImpolite.SignalingState())
+	assert.Nil(t, pcImpolite.PendingRemoteDescription())
+	assert.Equal(t, answer.SDP, pcImpolite.CurrentRemoteDescription().SDP)
+
+	closePairConnected(t, pcPolite, pcImpolite)
+}
+
+func TestPeerConnection_Rollback_TransceiverDirection(t *testing.T) {
+	pc, err := NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transceiver, err := pc.AddTransceiverFromKind(RTPCodecTypeVideo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, transceiver.SetDirection(RTPTransceiverDirectionRecvonly))
+
+	// The transceiver goes back to the direction it had before the offer
+	if err = pc.SetLocalDescription(SessionDescription{Type: SDPTypeRollback}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, transceiver.Direction)
+	assert.Equal(t, RTPTransceiverDirection(Unknown), transceiver.CurrentDirection())
+	assert.Empty(t, transceiver.Mid())
+	assert.Equal(t, []*RTPTransceiver{transceiver}, pc.GetTransceivers())
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_Rollback_ICERestart(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connected := make(chan struct{})
+	pcOffer.OnConnectionStateChange(func(state PeerConnectionState) {
+		if state == PeerConnectionStateConnected {
+			close(connected)
+		}
+	})
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	<-connected
+
+	agent := pcOffer.iceGatherer.getAgent()
+	params, err := pcOffer.iceGatherer.GetLocalParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pcOffer.CreateOffer(&OfferOptions{ICERestart: true})
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcOffer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	restartParams, err := pcOffer.iceGatherer.GetLocalParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, params.UsernameFragment, restartParams.UsernameFragment)
+
+	// The rollback goes back to the agent and credentials before the restart
+	if err = pcOffer.SetLocalDescription(SessionDescription{Type: SDPTypeRollback}); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, pcOffer.iceRestartPending)
+	assert.Equal(t, agent, pcOffer.iceGatherer.getAgent())
+	rolledBackParams, err := pcOffer.iceGatherer.GetLocalParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, params, rolledBackParams)
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_Rollback_RejectedMediaSection(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+	transceiver, err := pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer.SDP = strings.Replace(offer.SDP, "m=video 9 ", "m=video 0 ", 1)
+
+	// Rolling back the offer keeps the transceiver of the rejected m-line
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetRemoteDescription(SessionDescription{Type: SDPTypeRollback}); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, transceiver.stopped)
+
+	// Answering it stops the transceiver
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, transceiver.stopped)
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_ICELite(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	s := SettingEngine{}
+	s.SetLite(true)
+	liteAPI := NewAPI(WithSettingEngine(s))
+
+	for _, liteOffers := range []bool{true, false} {
+		liteOffers := liteOffers
+		t.Run(fmt.Sprintf("LiteOffers=%t", liteOffers), func(t *testing.T) {
+			pcLite, err := liteAPI.NewPeerConnection(Configuration{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pcFull, err := NewPeerConnection(Configuration{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pcOffer, pcAnswer := pcFull, pcLite
+			if liteOffers {
+				pcOffer, pcAnswer = pcLite, pcFull
+			}
+			if err = signalPair(pcOffer, pcAnswer); err != nil {
+				t.Fatal(err)
+			}
+
+			liteDesc := pcLite.LocalDescription()
+			assert.True(t, isICELite(liteDesc.parsed))
+			assert.False(t, isICELite(pcFull.LocalDescription().parsed))
+			for _, media := range liteDesc.parsed.MediaDescriptions {
+				for _, attr := range media.Attributes {
+					if attr.IsICECandidate() {
+						assert.Contains(t, attr.Value, "typ host")
+					}
+				}
+			}
+
+			closePairConnected(t, pcOffer, pcAnswer)
+
+			// The full agent is controlling whichever side offered
+			assert.Equal(t, ICERoleControlled, pcLite.iceTransport.Role())
+			assert.Equal(t, ICERoleControlling, pcFull.iceTransport.Role())
+		})
+	}
+}
+
+func TestPeerConnection_OnError_FingerprintMismatch(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transportErr := make(chan *TransportError, 1)
+	pcOffer.OnError(func(err *TransportError) {
+		transportErr <- err
+	})
+
+	if _, err = pcOffer.CreateDataChannel("data", nil); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcOffer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Signal the fingerprint of the offerer in the answer
+	fingerprint := regexp.MustCompile(`a=fingerprint:\S+ \S+`)
+	answer.SDP = fingerprint.ReplaceAllString(answer.SDP, fingerprint.FindString(offer.SDP))
+	if err = pcOffer.SetRemoteDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+
+	terr := <-transportErr
+	assert.Equal(t, TransportComponentDTLS, terr.Component)
+	assert.Equal(t, ErrNoMatchingFingerprint, terr.Err)
+	assert.Equal(t, PeerConnectionStateFailed, pcOffer.ConnectionState())
+	assert.Equal(t, DTLSTransportStateFailed, pcOffer.dtlsTransport.State())
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_ConnectionStateFromTransports(t *testing.T) {
+	iceA, iceB := &ICETransport{}, &ICETransport{}
+	dtlsA, dtlsB := &DTLSTransport{}, &DTLSTransport{}
+
+	testCases := []struct {
+		iceStates     map[*ICETransport]ICETransportState
+		dtlsStates    map[*DTLSTransport]DTLSTransportState
+		expectedState PeerConnectionState
+	}{
+		{nil, nil, PeerConnectionStateNew},
+		{
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateNew},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateNew},
+			PeerConnectionStateNew,
+		},
+		{
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateChecking},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateNew},
+			PeerConnectionStateConnecting,
+		},
+		{
+			// DTLS hasn't started yet on a connected ICETransport
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateConnected},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateNew},
+			PeerConnectionStateConnecting,
+		},
+		{
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateConnected, iceB: ICETransportStateCompleted},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateConnected, dtlsB: DTLSTransportStateClosed},
+			PeerConnectionStateConnected,
+		},
+		{
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateConnected, iceB: ICETransportStateDisconnected},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateConnected, dtlsB: DTLSTransportStateConnecting},
+			PeerConnectionStateDisconnected,
+		},
+		{
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateDisconnected},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateFailed},
+			PeerConnectionStateFailed,
+		},
+		{
+			map[*ICETransport]ICETransportState{iceA: ICETransportStateClosed},
+			map[*DTLSTransport]DTLSTransportState{dtlsA: DTLSTransportStateClosed},
+			PeerConnectionStateNew,
+		},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.expectedState,
+			newPeerConnectionStateFromTransports(testCase.iceStates, testCase.dtlsStates),
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}
+
+func TestPeerConnection_TransportErrorIsSticky(t *testing.T) {
+	pc, err := NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-pc.onTransportError(&TransportError{Component: TransportComponentSCTP, Err: fmt.Errorf("association failed")})
+	assert.Equal(t, PeerConnectionStateFailed, pc.ConnectionState())
+
+	// Transports that report connected don't hide the failure
+	pc.onICETransportStateChange(pc.iceTransport, ICETransportStateConnected)
+	pc.onDTLSTransportStateChange(pc.dtlsTransport, DTLSTransportStateConnected)
+	assert.Equal(t, PeerConnectionStateFailed, pc.ConnectionState())
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_OnConnectionStateChange(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, PeerConnectionStateNew, pcOffer.ConnectionState())
+
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		connecting, connected := make(chan struct{}), make(chan struct{})
+		pc.OnConnectionStateChange(func(state PeerConnectionState) {
+			switch state {
+			case PeerConnectionStateConnecting:
+				close(connecting)
+			case PeerConnectionStateConnected:
+				close(connected)
+			}
+		})
+		defer func() {
+			<-connecting
+			<-connected
+		}()
+	}
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_PeerIdentity(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	secret := []byte("shared secret")
+	newIdentityAPI := func(identity string) *API {
+		s := SettingEngine{}
+		s.RegisterIdentityProvider("example.org", &LocalIdentityProvider{Identity: identity, Secret: secret})
+		return NewAPI(WithSettingEngine(s))
+	}
+	aliceAPI, bobAPI := newIdentityAPI("alice@example.org"), newIdentityAPI("bob@example.org")
+
+	newOffer := func(t *testing.T, api *API) SessionDescription {
+		pc, err := api.NewPeerConnection(Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		} else if err = pc.SetIdentityProvider("example.org"); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { assert.NoError(t, pc.Close()) }()
+
+		if _, err = pc.CreateDataChannel("data", nil); err != nil {
+			t.Fatal(err)
+		}
+		offer, err := pc.CreateOffer(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return offer
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		pcOffer, err := aliceAPI.NewPeerConnection(Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pcAnswer, err := bobAPI.NewPeerConnection(Configuration{PeerIdentity: "alice@example.org"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+			if err = pc.SetIdentityProvider("example.org"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err = signalPair(pcOffer, pcAnswer); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &IdentityAssertion{IdP: "example.org", Name: "alice@example.org"}, pcAnswer.PeerIdentity())
+		assert.Equal(t, &IdentityAssertion{IdP: "example.org", Name: "bob@example.org"}, pcOffer.PeerIdentity())
+
+		closePairConnected(t, pcOffer, pcAnswer)
+	})
+
+	t.Run("Unregistered", func(t *testing.T) {
+		pc, err := NewPeerConnection(Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &rtcerr.NotSupportedError{Err: ErrIdentityProviderNotRegistered}, pc.SetIdentityProvider("example.org"))
+		assert.NoError(t, pc.Close())
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		withoutIdentity, err := NewPeerConnection(Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		unsigned, err := withoutIdentity.CreateOffer(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, withoutIdentity.Close())
+
+		// The fingerprint of another PeerConnection with the assertion of alice
+		assertedOffer := newOffer(t, aliceAPI)
+		fingerprint := regexp.MustCompile(`a=fingerprint:\S+ \S+`)
+		tampered := assertedOffer
+		tampered.SDP = fingerprint.ReplaceAllString(assertedOffer.SDP, fingerprint.FindString(newOffer(t, aliceAPI).SDP))
+
+		otherSecret := SettingEngine{}
+		otherSecret.RegisterIdentityProvider("example.org", &LocalIdentityProvider{Identity: "bob@example.org", Secret: []byte("other secret")})
+
+		testCases := []struct {
+			api           *API
+			peerIdentity  string
+			offer         SessionDescription
+			expectedError error
+		}{
+			{bobAPI, "alice@example.org", unsigned, ErrNoIdentityAssertion},
+			{bobAPI, "carol@example.org", assertedOffer, ErrPeerIdentityMismatch},
+			{bobAPI, "", tampered, ErrIdentityFingerprintMismatch},
+			{NewAPI(), "", assertedOffer, ErrIdentityProviderNotRegistered},
+			{NewAPI(WithSettingEngine(otherSecret)), "", assertedOffer, errInvalidLocalIdentityAssertion},
+		}
+
+		for i, testCase := range testCases {
+			pc, err := testCase.api.NewPeerConnection(Configuration{PeerIdentity: testCase.peerIdentity})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t,
+				&rtcerr.OperationError{Err: testCase.expectedError},
+				pc.SetRemoteDescription(testCase.offer),
+				"testCase: %d", i,
+			)
+			assert.Nil(t, pc.PeerIdentity())
+			assert.NoError(t, pc.Close())
+		}
+	})
+}
+
+func TestPeerConnection_DescriptionMungers(t *testing.T) {
+	lim := test.TimeOut(time.Second * 10)
+	defer lim.Stop()
+
+	offerSettings := SettingEngine{}
+	offerSettings.SetLocalDescriptionMunger(func(sdpType SDPType, desc *sdp.SessionDescription) error {
+		assert.Equal(t, SDPTypeOffer, sdpType)
+		for _, media := range desc.MediaDescriptions {
+			media.Bandwidth = append(media.Bandwidth, sdp.Bandwidth{Type: "AS", Bandwidth: 300})
+		}
+		return nil
+	})
+	offerAPI := NewAPI(WithSettingEngine(offerSettings))
+	offerAPI.mediaEngine.RegisterDefaultCodecs()
+
+	answerSettings := SettingEngine{}
+	answerSettings.SetRemoteDescriptionMunger(func(sdpType SDPType, desc *sdp.SessionDescription) error {
+		assert.Equal(t, SDPTypeOffer, sdpType)
+		for _, media := range desc.MediaDescriptions {
+			attributes := []sdp.Attribute{}
+			for _, attr := range media.Attributes {
+				if attr.Key != "extmap" {
+					attributes = append(attributes, attr)
+				}
+			}
+			media.Attributes = attributes
+		}
+		return nil
+	})
+	answerAPI := NewAPI(WithSettingEngine(answerSettings))
+	answerAPI.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, err := offerAPI.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := answerAPI.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, pcOffer.LocalDescription().SDP, "b=AS:300")
+	assert.Contains(t, pcOffer.LocalDescription().SDP, "a=extmap:")
+	assert.Contains(t, pcAnswer.RemoteDescription().SDP, "b=AS:300")
+	assert.NotContains(t, pcAnswer.RemoteDescription().SDP, "a=extmap:")
+
+	closePairConnected(t, pcOffer, pcAnswer)
+
+	// Neither a munger nor the application can change the m-lines or the transport of a description
+	for _, test := range []struct {
+		name, key, value string
+	}{
+		{"fingerprint", "fingerprint", "sha-256 00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00"},
+		{"setup", sdp.AttrKeyConnectionSetup, "passive"},
+		{"mid", sdp.AttrKeyMID, "munged"},
+		{"bundle", sdp.AttrKeyGroup, "BUNDLE munged"},
+		{"ice-ufrag", "ice-ufrag", "munged"},
+		{"ice-pwd", "ice-pwd", "mungedmungedmungedmunged"},
+	} {
+		invalidSettings := SettingEngine{}
+		invalidSettings.SetLocalDescriptionMunger(func(sdpType SDPType, desc *sdp.SessionDescription) error {
+			assert.True(t, replaceAttribute(desc, test.key, test.value), test.name)
+			return nil
+		})
+		pc, err := NewAPI(WithSettingEngine(invalidSettings)).NewPeerConnection(Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = pc.CreateDataChannel("data", nil); err != nil {
+			t.Fatal(err)
+		}
+		_, err = pc.CreateOffer(nil)
+		assert.Equal(t, &rtcerr.InvalidModificationError{Err: ErrMungedDescriptionInvalid}, err, test.name)
+		assert.NoError(t, pc.Close())
+
+		pc, err = NewPeerConnection(Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = pc.CreateDataChannel("data", nil); err != nil {
+			t.Fatal(err)
+		}
+		offer, err := pc.CreateOffer(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed := &sdp.SessionDescription{}
+		assert.NoError(t, parsed.Unmarshal([]byte(offer.SDP)))
+		assert.True(t, replaceAttribute(parsed, test.key, test.value), test.name)
+		sdpBytes, err := parsed.Marshal()
+		assert.NoError(t, err)
+		offer.SDP = string(sdpBytes)
+		assert.Equal(t, &rtcerr.InvalidModificationError{Err: ErrMungedDescriptionInvalid}, pc.SetLocalDescription(offer), test.name)
+		assert.NoError(t, pc.Close())
+	}
+}
+
+// replaceAttribute sets the value of every attribute of a description with the key
+func replaceAttribute(desc *sdp.SessionDescription, key, value string) (replaced bool) {
+	replace := func(attributes []sdp.Attribute) {
+		for i := range attributes {
+			if attributes[i].Key == key {
+				attributes[i].Value = value
+				replaced = true
+			}
+		}
+	}
+
+	replace(desc.Attributes)
+	for _, media := range desc.MediaDescriptions {
+		replace(media.Attributes)
+	}
+	return replaced
+}
+
+func TestPeerConnection_OfferToReceive(t *testing.T) {
+	pc, err := NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pc.AddTransceiverFromKind(RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &OfferOptions{OfferToReceiveAudio: true, OfferToReceiveVideo: true}
+	for i := 0; i < 2; i++ {
+		offer, err := pc.CreateOffer(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		transceivers := pc.GetTransceivers()
+		if !assert.Len(t, transceivers, 2) {
+			return
+		}
+		assert.Equal(t, RTPCodecTypeAudio, transceivers[0].kind)
+		assert.Equal(t, RTPTransceiverDirectionSendrecv, transceivers[0].Direction)
+		assert.Equal(t, RTPCodecTypeVideo, transceivers[1].kind)
+		assert.Equal(t, RTPTransceiverDirectionRecvonly, transceivers[1].Direction)
+		assert.Contains(t, offer.SDP, "m=video")
+		assert.Contains(t, offer.SDP, "a=recvonly")
+	}
+	assert.NoError(t, pc.Close())
+
+	// The transceivers are negotiated by the offer that adds them
+	pc, err = NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pc.CreateOffer(options); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, pc.GetTransceivers(), 2)
+	assert.False(t, pc.negotiationNeeded)
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_VoiceActivityDetection(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+	api.mediaEngine.RegisterCodec(NewRTPCNCodec(DefaultPayloadTypeCN, 8000))
+
+	pcOffer, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+
+	const comfortNoise = "a=rtpmap:13 CN/8000"
+
+	// Options that leave voice activity detection unset behave like nil options
+	offer, err := pcOffer.CreateOffer(&OfferOptions{OfferToReceiveAudio: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, offer.SDP, comfortNoise)
+
+	offer, err = pcOffer.CreateOffer(&OfferOptions{OfferAnswerOptions: OfferAnswerOptions{DisableVoiceActivityDetection: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, offer.SDP, comfortNoise)
+
+	offer, err = pcOffer.CreateOffer(&OfferOptions{OfferAnswerOptions: OfferAnswerOptions{VoiceActivityDetection: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, offer.SDP, comfortNoise)
+
+	offer, err = pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, offer.SDP, comfortNoise)
+
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := pcAnswer.CreateAnswer(&AnswerOptions{OfferAnswerOptions: OfferAnswerOptions{DisableVoiceActivityDetection: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, answer.SDP, comfortNoise)
+
+	answer, err = pcAnswer.CreateAnswer(&AnswerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, answer.SDP, comfortNoise)
+
+	answer, err = pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, answer.SDP, comfortNoise)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
@@ -19,8 +19,10 @@ type PeerConnection struct {
 	// Keep track of handlers/callbacks so we can call Release as required by the
 	// syscall/js API. Initially nil.
 	onSignalingStateChangeHandler    *js.Func
+	onNegotiationNeededHandler       *js.Func
 	onDataChannelHandler             *js.Func
 	onICEConectionStateChangeHandler *js.Func
+	onConnectionStateChangeHandler   *js.Func
 	onICECandidateHandler            *js.Func
 	onICEGatheringStateChangeHandler *js.Func
 
@@ -65,6 +67,21 @@ func (pc *PeerConnection) OnSignalingStateChange(f func(SignalingState)) {
 	pc.underlying.Set("onsignalingstatechange", onSignalingStateChangeHandler)
 }
 
+// OnNegotiationNeeded sets an event handler which is invoked when a change
+// has occurred which requires session negotiation
+func (pc *PeerConnection) OnNegotiationNeeded(f func()) {
+	if pc.onNegotiationNeededHandler != nil {
+		oldHandler := pc.onNegotiationNeededHandler
+		defer oldHandler.Release()
+	}
+	onNegotiationNeededHandler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		go f()
+		return js.Undefined()
+	})
+	pc.onNegotiationNeededHandler = &onNegotiationNeededHandler
+	pc.underlying.Set("onnegotiationneeded", onNegotiationNeededHandler)
+}
+
 // OnDataChannel sets an event handler which is invoked when a data
 // channel message arrives from a remote peer.
 func (pc *PeerConnection) OnDataChannel(f func(*DataChannel)) {
@@ -111,6 +128,22 @@ func (pc *PeerConnection) OnICEConnectionStateChange(f func(ICEConnectionState))
 	pc.underlying.Set("oniceconnectionstatechange", onICEConectionStateChangeHandler)
 }
 
+// OnConnectionStateChange sets an event handler which is called when the
+// PeerConnectionState changes.
+func (pc *PeerConnection) OnConnectionStateChange(f func(PeerConnectionState)) {
+	if pc.onConnectionStateChangeHandler != nil {
+		oldHandler := pc.onConnectionStateChangeHandler
+		defer oldHandler.Release()
+	}
+	onConnectionStateChangeHandler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		connectionState := newPeerConnectionState(pc.underlying.Get("connectionState").String())
+		go f(connectionState)
+		return js.Undefined()
+	})
+	pc.onConnectionStateChangeHandler = &onConnectionStateChangeHandler
+	pc.underlying.Set("onconnectionstatechange", onConnectionStateChangeHandler)
+}
+
 func (pc *PeerConnection) checkConfiguration(configuration Configuration) error {
 	// https://www.w3.org/TR/webrtc/#dom-rtcpeerconnection-setconfiguration (step #2)
 	if pc.ConnectionState() == PeerConnectionStateClosed {
@@ -386,12 +419,18 @@ func (pc *PeerConnection) Close() (err error) {
 	if pc.onSignalingStateChangeHandler != nil {
 		pc.onSignalingStateChangeHandler.Release()
 	}
+	if pc.onNegotiationNeededHandler != nil {
+		pc.onNegotiationNeededHandler.Release()
+	}
 	if pc.onDataChannelHandler != nil {
 		pc.onDataChannelHandler.Release()
 	}
 	if pc.onICEConectionStateChangeHandler != nil {
 		pc.onICEConectionStateChangeHandler.Release()
 	}
+	if pc.onConnectionStateChangeHandler != nil {
+		pc.onConnectionStateChangeHandler.Release()
+	}
 	if pc.onICECandidateHandler != nil {
 		pc.onICECandidateHandler.Release()
 	}
@@ -594,10 +633,18 @@ func offerOptionsToValue(offerOptions *OfferOptions) js.Value {
 	if offerOptions == nil {
 		return js.Undefined()
 	}
-	return js.ValueOf(map[string]interface{}{
+	value := map[string]interface{}{
 		"iceRestart":             offerOptions.ICERestart,
-		"voiceActivityDetection": offerOptions.VoiceActivityDetection,
-	})
+		"voiceActivityDetection": offerOptions.VoiceActivityDetection && !offerOptions.DisableVoiceActivityDetection,
+	}
+	// A false offerToReceive stops receiving in the browser, so only pass them when set
+	if offerOptions.OfferToReceiveAudio {
+		value["offerToReceiveAudio"] = true
+	}
+	if offerOptions.OfferToReceiveVideo {
+		value["offerToReceiveVideo"] = true
+	}
+	return js.ValueOf(value)
 }
 
 func answerOptionsToValue(answerOptions *AnswerOptions) js.Value {
@@ -605,7 +652,7 @@ func answerOptionsToValue(answerOptions *AnswerOptions) js.Value {
 		return js.Undefined()
 	}
 	return js.ValueOf(map[string]interface{}{
-		"voiceActivityDetection": answerOptions.VoiceActivityDetection,
+		"voiceActivityDetection": answerOptions.VoiceActivityDetection && !answerOptions.DisableVoiceActivityDetection,
 	})
 }
 
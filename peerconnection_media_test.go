@@ -1,9 +1,11 @@
+//go:build !js
 // +build !js
 
 package webrtc
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"math/rand"
@@ -16,8 +18,11 @@ import (
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/sdp/v2"
+	"github.com/pion/srtp"
 	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 	"github.com/pion/webrtc/v2/pkg/media"
+	"github.com/pion/webrtc/v2/pkg/rtcerr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -842,3 +847,2037 @@ func TestAddTransceiverFromTrackFailsRecvOnly(t *testing.T) {
 
 	assert.NotNil(t, err)
 }
+
+// renegotiatePair runs an offer/answer exchange between two PeerConnections
+// that have already been signaled once with signalPair
+func renegotiatePair(pcOffer *PeerConnection, pcAnswer *PeerConnection) error {
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		return err
+	} else if err = pcOffer.SetLocalDescription(offer); err != nil {
+		return err
+	} else if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		return err
+	} else if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	return pcOffer.SetRemoteDescription(answer)
+}
+
+// closePairConnected closes both PeerConnections once their transports are up, the
+// routine starting them would otherwise keep running after Close
+func closePairConnected(t *testing.T, pcOffer *PeerConnection, pcAnswer *PeerConnection) {
+	timeout := time.After(10 * time.Second)
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		for {
+			pc.sctpTransport.lock.RLock()
+			haveAssocation := pc.sctpTransport.association != nil
+			pc.sctpTransport.lock.RUnlock()
+
+			if haveAssocation {
+				break
+			}
+			select {
+			case <-timeout:
+				t.Fatalf("closePairConnected timed out waiting for the SCTP association")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Renegotiation_KeepsMids(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds, mids []string
+	for _, media := range offer.parsed.MediaDescriptions {
+		mid, _ := media.Attribute(sdp.AttrKeyMID)
+		kinds = append(kinds, media.MediaName.Media)
+		mids = append(mids, mid)
+	}
+	assert.Equal(t, []string{"audio", "application", "video"}, kinds)
+	assert.Equal(t, []string{"0", "1", "2"}, mids)
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_Renegotiation_AddTrack(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	haveConnected := make(chan struct{})
+	pcAnswer.OnICEConnectionStateChange(func(iceState ICEConnectionState) {
+		if iceState == ICEConnectionStateConnected {
+			close(haveConnected)
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	<-haveConnected
+
+	onTrackFired, onTrackFiredFunc := context.WithCancel(context.Background())
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		if track.Label() == "pion" {
+			onTrackFiredFunc()
+		}
+	})
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = renegotiatePair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				if routineErr := vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}); routineErr != nil {
+					t.Fatal(routineErr)
+				}
+			case <-onTrackFired.Done():
+				return
+			}
+		}
+	}()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_ICERestart(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteTrack := make(chan *Track, 1)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTrack <- track
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep sending while ICE restarts
+	stopSending := make(chan struct{})
+	sendingDone := make(chan struct{})
+	go func() {
+		defer close(sendingDone)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				if routineErr := vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}); routineErr != nil {
+					t.Error(routineErr)
+					return
+				}
+			case <-stopSending:
+				return
+			}
+		}
+	}()
+
+	track := <-remoteTrack
+	if _, err = track.ReadRTP(); err != nil {
+		t.Fatal(err)
+	}
+
+	previousOfferUfrag, _, _, err := extractICEDetails(pcOffer.currentLocalDescription.parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousAnswerUfrag, _, _, err := extractICEDetails(pcAnswer.currentLocalDescription.parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pcOffer.CreateOffer(&OfferOptions{ICERestart: true})
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcOffer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	} else if err = pcOffer.SetRemoteDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+
+	offerUfrag, _, _, err := extractICEDetails(offer.parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answerUfrag, _, _, err := extractICEDetails(answer.parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, previousOfferUfrag, offerUfrag)
+	assert.NotEqual(t, previousAnswerUfrag, answerUfrag)
+
+	// Wait for both sides to move over to the new agent
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		for {
+			pc.iceTransport.lock.RLock()
+			restarted := pc.iceTransport.agent == pc.iceGatherer.getAgent()
+			pc.iceTransport.lock.RUnlock()
+
+			if restarted {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Media still flows over the same DTLS and SRTP sessions
+	for i := 0; i < 5; i++ {
+		if _, err = track.ReadRTP(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, DTLSTransportStateConnected, pcOffer.dtlsTransport.State())
+	assert.Equal(t, DTLSTransportStateConnected, pcAnswer.dtlsTransport.State())
+
+	close(stopSending)
+	<-sendingDone
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_ReplaceTrack(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := pcOffer.AddTrack(vp8Track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteTrack := make(chan *Track, 1)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTrack <- track
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	opusTrack, err := pcOffer.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ErrRTPSenderNewTrackHasIncorrectKind, sender.ReplaceTrack(opusTrack))
+
+	replacementTrack, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "replacement")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep writing to both Tracks, only the one attached to the RTPSender is sent
+	stopSending := make(chan struct{})
+	sendingDone := make(chan struct{})
+	go func() {
+		defer close(sendingDone)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				for _, sample := range []struct {
+					track *Track
+					data  byte
+				}{{vp8Track, 0xAA}, {replacementTrack, 0xBB}} {
+					if routineErr := sample.track.WriteSample(media.Sample{Data: []byte{sample.data}, Samples: 1}); routineErr != nil && routineErr != io.ErrClosedPipe {
+						t.Error(routineErr)
+						return
+					}
+				}
+			case <-stopSending:
+				return
+			}
+		}
+	}()
+
+	track := <-remoteTrack
+	var last *rtp.Packet
+	readPayload := func(want byte) {
+		for {
+			pkt, readErr := track.ReadRTP()
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			assert.Equal(t, vp8Track.SSRC(), pkt.SSRC)
+
+			previous := last
+			last = pkt
+			if pkt.Payload[len(pkt.Payload)-1] != want {
+				continue
+			}
+
+			// The replacement continues the sequence of the replaced Track
+			if previous != nil && previous.Payload[len(previous.Payload)-1] != want {
+				assert.Equal(t, previous.SequenceNumber+1, pkt.SequenceNumber)
+				assert.True(t, pkt.Timestamp-previous.Timestamp > 0 && pkt.Timestamp-previous.Timestamp < 90000)
+			}
+			return
+		}
+	}
+	readPayload(0xAA)
+
+	if err = sender.ReplaceTrack(replacementTrack); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, replacementTrack, sender.Track())
+	readPayload(0xBB)
+
+	close(stopSending)
+	<-sendingDone
+
+	vp8Track.mu.RLock()
+	assert.Equal(t, 0, vp8Track.totalSenderCount)
+	assert.Equal(t, 0, len(vp8Track.activeSenders))
+	vp8Track.mu.RUnlock()
+
+	replacementTrack.mu.RLock()
+	assert.Equal(t, 1, replacementTrack.totalSenderCount)
+	assert.Equal(t, []*RTPSender{sender}, replacementTrack.activeSenders)
+	replacementTrack.mu.RUnlock()
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_RemoveTrack(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pc, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp8Track, err := pc.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := pc.AddTrack(vp8Track)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPC, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSender, err := otherPC.AddTrack(vp8Track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &rtcerr.InvalidAccessError{Err: ErrSenderNotCreatedByConnection}, pc.RemoveTrack(otherSender))
+	assert.NoError(t, otherPC.Close())
+
+	if err = pc.RemoveTrack(sender); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, sender.Track())
+	assert.Equal(t, RTPTransceiverDirectionRecvonly, pc.GetTransceivers()[0].Direction)
+	assert.Equal(t, io.ErrClosedPipe, vp8Track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offerMediaHasDirection(offer, RTPCodecTypeVideo, RTPTransceiverDirectionRecvonly))
+	assert.NotContains(t, offer.SDP, "msid:pion")
+
+	// Adding the Track again reuses the RTPTransceiver
+	if _, err = pc.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(pc.GetTransceivers()))
+	assert.Equal(t, sender, pc.GetTransceivers()[0].Sender)
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, pc.GetTransceivers()[0].Direction)
+
+	// A Track can be removed from a RTPTransceiver that doesn't send anymore
+	pc.GetTransceivers()[0].Direction = RTPTransceiverDirectionInactive
+	if err = pc.RemoveTrack(sender); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, sender.Track())
+	assert.Equal(t, RTPTransceiverDirectionInactive, pc.GetTransceivers()[0].Direction)
+	if _, err = pc.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+
+	vp8Track.mu.RLock()
+	assert.Equal(t, 1, vp8Track.totalSenderCount)
+	vp8Track.mu.RUnlock()
+
+	assert.NoError(t, pc.Close())
+}
+
+func TestPeerConnection_Renegotiation_Hold(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	offerTransceiver := pcOffer.GetTransceivers()[0]
+	answerTransceiver := pcAnswer.GetTransceivers()[0]
+	assert.Equal(t, "", offerTransceiver.Mid())
+	assert.Equal(t, RTPTransceiverDirection(Unknown), offerTransceiver.CurrentDirection())
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "0", offerTransceiver.Mid())
+	assert.Equal(t, "0", answerTransceiver.Mid())
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, offerTransceiver.CurrentDirection())
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, answerTransceiver.CurrentDirection())
+
+	negotiationNeeded := make(chan struct{}, 1)
+	pcOffer.OnNegotiationNeeded(func() {
+		negotiationNeeded <- struct{}{}
+	})
+
+	assert.Error(t, offerTransceiver.SetDirection(RTPTransceiverDirection(Unknown)))
+
+	// Put the remote peer on hold
+	if err = offerTransceiver.SetDirection(RTPTransceiverDirectionSendonly); err != nil {
+		t.Fatal(err)
+	}
+	<-negotiationNeeded
+	if err = renegotiatePair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, offerMediaHasDirection(*pcAnswer.currentLocalDescription, RTPCodecTypeVideo, RTPTransceiverDirectionRecvonly))
+	assert.Equal(t, RTPTransceiverDirectionSendonly, offerTransceiver.CurrentDirection())
+	assert.Equal(t, RTPTransceiverDirectionRecvonly, answerTransceiver.CurrentDirection())
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, answerTransceiver.Direction)
+	assert.False(t, pcAnswer.checkNegotiationNeeded())
+
+	// And take it off hold again
+	if err = offerTransceiver.SetDirection(RTPTransceiverDirectionSendrecv); err != nil {
+		t.Fatal(err)
+	}
+	<-negotiationNeeded
+	if err = renegotiatePair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, offerTransceiver.CurrentDirection())
+	assert.Equal(t, RTPTransceiverDirectionSendrecv, answerTransceiver.CurrentDirection())
+
+	assert.NoError(t, offerTransceiver.Stop())
+	assert.Error(t, offerTransceiver.SetDirection(RTPTransceiverDirectionSendrecv))
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestRTPTransceiver_SetCodecPreferences(t *testing.T) {
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h264, err := pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp8, err := pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h264Codec := NewRTPH264Codec(DefaultPayloadTypeH264, 90000).RTPCodecCapability
+	vp8Codec := NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000).RTPCodecCapability
+	opusCodec := NewRTPOpusCodec(DefaultPayloadTypeOpus, 48000).RTPCodecCapability
+
+	assert.Equal(t, &rtcerr.InvalidModificationError{Err: ErrRTPTransceiverCodecUnsupported}, h264.SetCodecPreferences([]RTPCodecCapability{opusCodec}))
+	assert.NoError(t, h264.SetCodecPreferences([]RTPCodecCapability{h264Codec, vp8Codec}))
+	assert.NoError(t, vp8.SetCodecPreferences([]RTPCodecCapability{vp8Codec}))
+
+	formats := func(desc SessionDescription) (f [][]string) {
+		parsed := &sdp.SessionDescription{}
+		assert.NoError(t, parsed.Unmarshal([]byte(desc.SDP)))
+		for _, media := range parsed.MediaDescriptions {
+			if media.MediaName.Media == "video" {
+				f = append(f, media.MediaName.Formats)
+			}
+		}
+		return f
+	}
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"102", "96"}, {"96"}}, formats(offer))
+
+	if _, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+	if err = pcOffer.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	} else if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, pcAnswer.GetTransceivers()[0].SetCodecPreferences([]RTPCodecCapability{h264Codec}))
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"102"}, formats(answer)[0])
+
+	// An empty list goes back to every registered codec
+	assert.NoError(t, h264.SetCodecPreferences(nil))
+	offer, err = pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"96", "97", "102", "103", "98", "99"}, formats(offer)[0])
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+// signalPairWithModification is signalPair with a hook to rewrite the offer
+// before the answering PeerConnection sees it
+func signalPairWithModification(pcOffer *PeerConnection, pcAnswer *PeerConnection, modificationFunc func(string) string) error {
+	if _, err := pcOffer.CreateDataChannel("initial_data_channel", nil); err != nil {
+		return err
+	}
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		return err
+	} else if err = pcOffer.SetLocalDescription(offer); err != nil {
+		return err
+	}
+
+	offer.SDP = modificationFunc(offer.SDP)
+	if err = pcAnswer.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+
+	answer, err := pcAnswer.CreateAnswer(nil)
+	if err != nil {
+		return err
+	} else if err = pcAnswer.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	return pcOffer.SetRemoteDescription(answer)
+}
+
+// removeSSRCLines strips all signaled SSRCs, like Firefox does
+func removeSSRCLines(sessionDescription string) string {
+	filtered := []string{}
+	for _, line := range strings.Split(sessionDescription, "\r\n") {
+		if !strings.HasPrefix(line, "a=ssrc:") {
+			filtered = append(filtered, line)
+		}
+	}
+	return strings.Join(filtered, "\r\n")
+}
+
+func TestPeerConnection_UndeclaredSSRC(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opusTrack, err := pcOffer.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(opusTrack); err != nil {
+		t.Fatal(err)
+	}
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []RTPCodecType{RTPCodecTypeAudio, RTPCodecTypeVideo} {
+		if _, err = pcAnswer.AddTransceiver(kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	remoteTrack := make(chan *Track, 1)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTrack <- track
+	})
+
+	if err = signalPairWithModification(pcOffer, pcAnswer, removeSSRCLines); err != nil {
+		t.Fatal(err)
+	}
+
+	videoMid := pcOffer.GetTransceivers()[1].Mid()
+	assert.Equal(t, "1", videoMid)
+
+	// The video mid and a rid are carried in one-byte header extensions, with the ids from our offer
+	extensionPayload := []byte{0x10, videoMid[0], 0x21, 'h', 'i', 0x00, 0x00, 0x00}
+
+	// The first packet has no mid, so it is read while probing for it
+	var track *Track
+	for sequenceNumber := uint16(0); track == nil; {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			header := rtp.Header{
+				Version:        2,
+				SequenceNumber: sequenceNumber,
+				PayloadType:    DefaultPayloadTypeVP8,
+				SSRC:           vp8Track.SSRC(),
+			}
+			if sequenceNumber != 0 {
+				header.Extension, header.ExtensionProfile, header.ExtensionPayload = true, 0xBEDE, extensionPayload
+			}
+			if routineErr := vp8Track.WriteRTP(&rtp.Packet{Header: header, Payload: []byte{0x10, 0x00}}); routineErr != nil {
+				t.Fatal(routineErr)
+			}
+			sequenceNumber++
+		case track = <-remoteTrack:
+		}
+	}
+
+	assert.Equal(t, RTPCodecTypeVideo, track.Kind())
+	assert.Equal(t, vp8Track.SSRC(), track.SSRC())
+	assert.Equal(t, "hi", track.RID())
+	assert.Equal(t, "pion", track.Label())
+	assert.Equal(t, "video", track.ID())
+	assert.Equal(t, track, pcAnswer.GetTransceivers()[1].Receiver.Track())
+
+	// The probed packets reach the Track, the first one told its payload type
+	packet, err := track.ReadRTP()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(1), packet.SequenceNumber)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Simulcast_Receive(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(vp8Track); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+
+	rids := []string{"a", "b"}
+	remoteTracks := make(chan *Track, len(rids))
+	receivers := make(chan *RTPReceiver, len(rids))
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTracks <- track
+		receivers <- r
+	})
+
+	// Offer every RID without SSRCs, like a browser sending simulcast
+	if err = signalPairWithModification(pcOffer, pcAnswer, func(sessionDescription string) string {
+		sessionDescription = removeSSRCLines(sessionDescription)
+		return strings.Replace(sessionDescription, "a=mid:0\r\n", "a=mid:0\r\na=rid:a send\r\na=rid:b send\r\na=simulcast:send a;b\r\n", 1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := pcAnswer.CurrentLocalDescription().SDP
+	assert.Contains(t, answer, "a=rid:a recv\r\n")
+	assert.Contains(t, answer, "a=rid:b recv\r\n")
+	assert.Contains(t, answer, "a=simulcast:recv a;b\r\n")
+
+	ssrcs := map[string]uint32{"a": rand.Uint32(), "b": rand.Uint32()}
+	tracks := map[string]*Track{}
+	for len(tracks) != len(rids) {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			// Write every layer straight to the SRTP session once DTLS is up
+			srtpSession, routineErr := pcOffer.dtlsTransport.getSRTPSession()
+			if routineErr != nil {
+				continue
+			}
+			writeStream, routineErr := srtpSession.OpenWriteStream()
+			if routineErr != nil {
+				t.Fatal(routineErr)
+			}
+
+			for i, rid := range rids {
+				// MID has id 1 and RID has id 2 in our offer
+				extensionPayload := []byte{0x10, '0', 0x20, rid[0]}
+				if _, routineErr := writeStream.WriteRTP(&rtp.Header{
+					Version:          2,
+					Extension:        true,
+					ExtensionProfile: 0xBEDE,
+					ExtensionPayload: extensionPayload,
+					PayloadType:      DefaultPayloadTypeVP8,
+					SequenceNumber:   uint16(i),
+					SSRC:             ssrcs[rid],
+				}, []byte{0x10, 0x00}); routineErr != nil {
+					t.Fatal(routineErr)
+				}
+			}
+		case track := <-remoteTracks:
+			tracks[track.RID()] = track
+		}
+	}
+
+	receiver := pcAnswer.GetTransceivers()[0].Receiver
+	for _, rid := range rids {
+		assert.Equal(t, ssrcs[rid], tracks[rid].SSRC())
+		assert.Equal(t, RTPCodecTypeVideo, tracks[rid].Kind())
+		assert.Equal(t, receiver, <-receivers)
+	}
+	assert.Equal(t, 2, len(receiver.Tracks()))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_Simulcast_Send(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	high, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opus, err := pcOffer.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transceiver, err := pcOffer.AddTransceiverFromTrack(high, RtpTransceiverInit{
+		Direction: RTPTransceiverDirectionSendonly,
+		SendEncodings: []RTPEncodingParameters{
+			{RTPCodingParameters: RTPCodingParameters{RID: "h"}, Active: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := transceiver.Sender
+
+	assert.Equal(t, ErrRTPSenderRIDNil, sender.AddEncoding(low, RTPEncodingParameters{Active: true}))
+	assert.Equal(t, ErrRTPSenderRIDCollision, sender.AddEncoding(low, RTPEncodingParameters{RTPCodingParameters: RTPCodingParameters{RID: "h"}}))
+	assert.Equal(t, ErrRTPSenderNewTrackHasIncorrectKind, sender.AddEncoding(opus, RTPEncodingParameters{RTPCodingParameters: RTPCodingParameters{RID: "a"}}))
+	assert.NoError(t, sender.AddEncoding(low, RTPEncodingParameters{
+		RTPCodingParameters: RTPCodingParameters{RID: "l"},
+		Active:              true,
+		MaxBitrate:          100000,
+	}))
+
+	parameters := sender.GetParameters()
+	assert.Equal(t, 2, len(parameters.Encodings))
+	assert.Equal(t, low.SSRC(), parameters.Encodings[1].SSRC)
+	assert.Equal(t, uint8(DefaultPayloadTypeVP8), parameters.Encodings[1].PayloadType)
+
+	parameters.Encodings[1].RID = "m"
+	assert.Equal(t, ErrRTPSenderEncodingsModified, sender.SetParameters(parameters))
+
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteTracks := make(chan *Track, 2)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTracks <- track
+	})
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, offer.SDP, "a=rid:h send\r\n")
+	assert.Contains(t, offer.SDP, "a=rid:l send max-br=100000\r\n")
+	assert.Contains(t, offer.SDP, "a=simulcast:send h;l\r\n")
+	assert.NotContains(t, offer.SDP, "a=ssrc:")
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := map[string]*Track{}
+	for len(tracks) != 2 {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			for _, track := range []*Track{high, low} {
+				if routineErr := track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}); routineErr != nil {
+					t.Fatal(routineErr)
+				}
+			}
+		case track := <-remoteTracks:
+			tracks[track.RID()] = track
+		}
+	}
+	assert.Equal(t, high.SSRC(), tracks["h"].SSRC())
+	assert.Equal(t, low.SSRC(), tracks["l"].SSRC())
+
+	// A replacement Track is sent with every encoding, each continues its own sequence
+	replacement, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sender.ReplaceTrack(replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	stopSending := make(chan struct{})
+	sendingDone := make(chan struct{})
+	go func() {
+		defer close(sendingDone)
+		for {
+			select {
+			case <-stopSending:
+				return
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, replacement.WriteSample(media.Sample{Data: []byte{0xBB}, Samples: 1}))
+			}
+		}
+	}()
+
+	for _, rid := range []string{"h", "l"} {
+		var previous *rtp.Packet
+		for {
+			pkt, readErr := tracks[rid].ReadRTP()
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if pkt.Payload[len(pkt.Payload)-1] == 0xBB {
+				if previous != nil {
+					assert.Equal(t, previous.SequenceNumber+1, pkt.SequenceNumber)
+				}
+				break
+			}
+			previous = pkt
+		}
+	}
+	close(stopSending)
+	<-sendingDone
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+// removeBundleGroup strips the BUNDLE group, like an endpoint that doesn't support BUNDLE
+func removeBundleGroup(sessionDescription string) string {
+	filtered := []string{}
+	for _, line := range strings.Split(sessionDescription, "\r\n") {
+		if !strings.HasPrefix(line, "a=group:BUNDLE") {
+			filtered = append(filtered, line)
+		}
+	}
+	return strings.Join(filtered, "\r\n")
+}
+
+func TestPeerConnection_BundlePolicy_Unbundled(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opusTrack, err := pcOffer.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp8Track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, track := range []*Track{opusTrack, vp8Track} {
+		if _, err = pcOffer.AddTrack(track); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = pcAnswer.AddTransceiver(track.Kind(), RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	remoteTracks := make(chan *Track, 2)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTracks <- track
+	})
+
+	dataChannelOpened := make(chan struct{})
+	pcAnswer.OnDataChannel(func(d *DataChannel) {
+		d.OnOpen(func() {
+			close(dataChannelOpened)
+		})
+	})
+
+	var offer string
+	if err = signalPairWithModification(pcOffer, pcAnswer, func(sessionDescription string) string {
+		offer = sessionDescription
+		return removeBundleGroup(sessionDescription)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// balanced gathers for every media type, so each m-line has its own credentials
+	parsed := &sdp.SessionDescription{}
+	assert.NoError(t, parsed.Unmarshal([]byte(offer)))
+	ufrags := map[string]bool{}
+	for _, media := range parsed.MediaDescriptions {
+		ufrag, _ := media.Attribute("ice-ufrag")
+		ufrags[ufrag] = true
+	}
+	assert.Equal(t, 3, len(ufrags))
+
+	answer := pcAnswer.LocalDescription().SDP
+	assert.NotContains(t, answer, "a=group:BUNDLE")
+	assert.Equal(t, 3, len(pcAnswer.mediaTransports))
+	assert.Equal(t, 3, len(pcOffer.mediaTransports))
+
+	tracks := map[RTPCodecType]*Track{}
+	for len(tracks) != 2 {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			for _, track := range []*Track{opusTrack, vp8Track} {
+				if routineErr := track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}); routineErr != nil {
+					t.Fatal(routineErr)
+				}
+			}
+		case track := <-remoteTracks:
+			tracks[track.Kind()] = track
+		}
+	}
+	assert.Equal(t, opusTrack.SSRC(), tracks[RTPCodecTypeAudio].SSRC())
+	assert.Equal(t, vp8Track.SSRC(), tracks[RTPCodecTypeVideo].SSRC())
+
+	// Every m-line is received on a transport of its own
+	receivers := pcAnswer.GetReceivers()
+	assert.NotEqual(t, receivers[0].Transport(), receivers[1].Transport())
+	<-dataChannelOpened
+	assert.NotEqual(t, receivers[0].Transport(), pcAnswer.sctpTransport.Transport())
+	assert.NotEqual(t, receivers[1].Transport(), pcAnswer.sctpTransport.Transport())
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_BundlePolicy_Trickle(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	s := SettingEngine{}
+	s.SetTrickle(true)
+	api := NewAPI(WithSettingEngine(s))
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []RTPCodecType{RTPCodecTypeAudio, RTPCodecTypeVideo} {
+		if _, err = pcOffer.AddTransceiver(kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	offerCandidates, answerCandidates := make(chan ICECandidateInit, 100), make(chan ICECandidateInit, 100)
+	pcOffer.OnICECandidate(func(c *ICECandidate) {
+		if c != nil {
+			offerCandidates <- c.ToJSON()
+		}
+	})
+	pcAnswer.OnICECandidate(func(c *ICECandidate) {
+		if c != nil {
+			answerCandidates <- c.ToJSON()
+		}
+	})
+
+	offerConnected, answerConnected := make(chan struct{}), make(chan struct{})
+	pcOffer.OnConnectionStateChange(func(state PeerConnectionState) {
+		if state == PeerConnectionStateConnected {
+			close(offerConnected)
+		}
+	})
+	pcAnswer.OnConnectionStateChange(func(state PeerConnectionState) {
+		if state == PeerConnectionStateConnected {
+			close(answerConnected)
+		}
+	})
+
+	if err = signalPairWithModification(pcOffer, pcAnswer, removeBundleGroup); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(pcOffer.mediaTransports))
+	assert.Equal(t, 3, len(pcAnswer.mediaTransports))
+
+	// The candidates of every m-line are trickled with its mid
+	mids := map[string]bool{}
+	for offerConnected != nil || answerConnected != nil {
+		select {
+		case c := <-offerCandidates:
+			if c.SDPMid != nil {
+				mids[*c.SDPMid] = true
+			}
+			assert.NoError(t, pcAnswer.AddICECandidate(c))
+		case c := <-answerCandidates:
+			assert.NoError(t, pcOffer.AddICECandidate(c))
+		case <-offerConnected:
+			offerConnected = nil
+		case <-answerConnected:
+			answerConnected = nil
+		}
+	}
+	assert.Equal(t, map[string]bool{"1": true, "2": true}, mids)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_BundlePolicy_MaxBundle(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := api.NewPeerConnection(Configuration{BundlePolicy: BundlePolicyMaxBundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []RTPCodecType{RTPCodecTypeAudio, RTPCodecTypeVideo} {
+		if _, err = pcOffer.AddTransceiver(kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A bundled answer moves every m-line onto the first transport
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, pcOffer.mediaTransports)
+	assert.Nil(t, pcAnswer.mediaTransports)
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+
+	// max-bundle only negotiates the first m-line with an endpoint that doesn't do BUNDLE
+	if pcOffer, err = api.NewPeerConnection(Configuration{}); err != nil {
+		t.Fatal(err)
+	}
+	if pcAnswer, err = api.NewPeerConnection(Configuration{BundlePolicy: BundlePolicyMaxBundle}); err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []RTPCodecType{RTPCodecTypeAudio, RTPCodecTypeVideo} {
+		if _, err = pcOffer.AddTransceiver(kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = signalPairWithModification(pcOffer, pcAnswer, removeBundleGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := pcAnswer.LocalDescription().SDP
+	assert.NotContains(t, answer, "a=group:BUNDLE")
+	assert.Contains(t, answer, "m=audio 9")
+	assert.Contains(t, answer, "m=video 0")
+	assert.Contains(t, answer, "m=application 0")
+	assert.Equal(t, 1, len(pcAnswer.mediaTransports))
+
+	// The offer gathered for the rejected m-lines, which is closed again
+	assert.Equal(t, 1, len(pcOffer.mediaTransports))
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func removeRTCPMux(sessionDescription string) string {
+	filtered := []string{}
+	for _, line := range strings.Split(removeBundleGroup(sessionDescription), "\r\n") {
+		if line != "a=rtcp-mux" {
+			filtered = append(filtered, line)
+		}
+	}
+	return strings.Join(filtered, "\r\n")
+}
+
+func TestPeerConnection_RTCPMuxPolicy_Negotiate(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, err := api.NewPeerConnection(Configuration{RTCPMuxPolicy: RTCPMuxPolicyNegotiate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := api.NewPeerConnection(Configuration{RTCPMuxPolicy: RTCPMuxPolicyNegotiate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opusTrack, err := pcOffer.NewTrack(DefaultPayloadTypeOpus, rand.Uint32(), "audio", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := pcOffer.AddTrack(opusTrack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiver(RTPCodecTypeAudio, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	remoteTrack := make(chan *Track, 1)
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		remoteTrack <- track
+	})
+
+	// The answerer sees an offer that doesn't multiplex RTCP
+	if err = signalPairWithModification(pcOffer, pcAnswer, removeRTCPMux); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := &sdp.SessionDescription{}
+	assert.NoError(t, parsed.Unmarshal([]byte(pcAnswer.LocalDescription().SDP)))
+	_, haveRTCPMux := parsed.MediaDescriptions[0].Attribute(sdp.AttrKeyRTCPMux)
+	assert.False(t, haveRTCPMux)
+
+	var track *Track
+	for track == nil {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			if routineErr := opusTrack.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}); routineErr != nil {
+				t.Fatal(routineErr)
+			}
+		case track = <-remoteTrack:
+		}
+	}
+
+	// RTCP is sent on the component of its own
+	if err = pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{SenderSSRC: track.SSRC(), MediaSSRC: track.SSRC()}}); err != nil {
+		t.Fatal(err)
+	}
+	pkts, err := sender.ReadRTCP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, track.SSRC(), pkts[0].DestinationSSRC()[0])
+
+	for _, pc := range []*PeerConnection{pcOffer, pcAnswer} {
+		assert.Equal(t, ICETransportState(ICETransportStateConnected), pc.rtcpTransport.iceTransport.State())
+
+		// The RTCP component is checked with the credentials of the RTP component
+		params, err := pc.iceGatherer.GetLocalParameters()
+		assert.NoError(t, err)
+		rtcpParams, err := pc.rtcpTransport.iceGatherer.GetLocalParameters()
+		assert.NoError(t, err)
+		assert.Equal(t, params, rtcpParams)
+
+		candidates, err := pc.rtcpTransport.iceGatherer.GetLocalCandidates()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, candidates)
+		for _, candidate := range candidates {
+			assert.Equal(t, uint16(2), candidate.Component)
+		}
+	}
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_RTCPMuxPolicy_Require(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, err := api.NewPeerConnection(Configuration{RTCPMuxPolicy: RTCPMuxPolicyNegotiate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := api.NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTransceiver(RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offer.SDP = removeRTCPMux(offer.SDP)
+	assert.Equal(t, &rtcerr.InvalidAccessError{Err: ErrRTCPMuxRequired}, pcAnswer.SetRemoteDescription(offer))
+
+	// A remote that multiplexes RTCP closes the component that was gathered for it
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, pcOffer.rtcpTransport)
+	assert.Nil(t, pcAnswer.rtcpTransport)
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestPeerConnection_OnUnhandledRTP(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	pcOffer, pcAnswer, err := newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unhandledRTP := make(chan []byte, 1)
+	pcAnswer.OnUnhandledRTP(func(stream *srtp.ReadStreamSRTP, ssrc uint32, firstPacket []byte) {
+		assert.Equal(t, uint32(1234), ssrc)
+		unhandledRTP <- firstPacket
+	})
+	unhandledRTCP := make(chan []byte, 1)
+	pcOffer.OnUnhandledRTCP(func(stream *srtp.ReadStreamSRTCP, ssrc uint32, firstPacket []byte) {
+		assert.Equal(t, uint32(5678), ssrc)
+		unhandledRTCP <- firstPacket
+	})
+
+	connected := make(chan struct{})
+	pcOffer.OnConnectionStateChange(func(state PeerConnectionState) {
+		if state == PeerConnectionStateConnected {
+			close(connected)
+		}
+	})
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	<-connected
+
+	// Neither SSRC is signaled, nor can they be bound by mid
+	srtpSession, err := pcOffer.dtlsTransport.getSRTPSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeStream, err := srtpSession.OpenWriteStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rtpPacket, rtcpPacket []byte
+	for rtpPacket == nil || rtcpPacket == nil {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			if _, routineErr := writeStream.WriteRTP(&rtp.Header{Version: 2, SSRC: 1234, PayloadType: DefaultPayloadTypeVP8}, []byte{0xAA}); routineErr != nil {
+				t.Fatal(routineErr)
+			}
+			if routineErr := pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 5678}}); routineErr != nil {
+				t.Fatal(routineErr)
+			}
+		case rtpPacket = <-unhandledRTP:
+		case rtcpPacket = <-unhandledRTCP:
+		}
+	}
+
+	packet := &rtp.Packet{}
+	assert.NoError(t, packet.Unmarshal(rtpPacket))
+	assert.Equal(t, uint32(1234), packet.SSRC)
+	assert.Equal(t, []byte{0xAA}, packet.Payload)
+
+	pkts, err := rtcp.Unmarshal(rtcpPacket)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{5678}, pkts[0].DestinationSSRC())
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
+
+func TestPeerConnection_IsUnboundSSRC(t *testing.T) {
+	pc, err := NewPeerConnection(Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := pc.NewTrack(DefaultPayloadTypeVP8, 1234, "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pc.AddTrack(track); err != nil {
+		t.Fatal(err)
+	}
+
+	pc.mu.Lock()
+	pc.probingSSRCs[5678] = true
+	pc.mu.Unlock()
+
+	// RTCP of sent or probed SSRCs is read by the RTPSender or RTPReceiver
+	assert.False(t, pc.isUnboundSSRC(1234))
+	assert.False(t, pc.isUnboundSSRC(5678))
+	assert.True(t, pc.isUnboundSSRC(9012))
+
+	assert.NoError(t, pc.Close())
+}
+
+// testInterceptor rewrites the payload of outgoing RTP and records the streams and packets it sees
+type testInterceptor struct {
+	interceptor.NoOp
+
+	mu            sync.Mutex
+	localStreams  []*interceptor.StreamInfo
+	remoteStreams []*interceptor.StreamInfo
+	rtpRead       int
+	rtcpRead      int
+	rtcpWritten   int
+	closed        bool
+}
+
+func (i *testInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.localStreams = append(i.localStreams, info)
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		return writer.Write(header, []byte{0xBB}, attributes)
+	})
+}
+
+func (i *testInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remoteStreams = append(i.remoteStreams, info)
+
+	return interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		i.mu.Lock()
+		i.rtpRead++
+		i.mu.Unlock()
+		return n, attributes, err
+	})
+}
+
+func (i *testInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		i.mu.Lock()
+		i.rtcpRead++
+		i.mu.Unlock()
+		return n, attributes, err
+	})
+}
+
+func (i *testInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		i.mu.Lock()
+		i.rtcpWritten++
+		i.mu.Unlock()
+		return writer.Write(pkts, attributes)
+	})
+}
+
+func (i *testInterceptor) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.closed = true
+	return nil
+}
+
+func TestPeerConnection_Interceptor(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	interceptors := []*testInterceptor{}
+	registry := &interceptor.Registry{}
+	registry.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		i := &testInterceptor{}
+		interceptors = append(interceptors, i)
+		return i, nil
+	}))
+
+	api := NewAPI(WithInterceptorRegistry(registry))
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Len(t, interceptors, 2) {
+		return
+	}
+	offerInterceptor, answerInterceptor := interceptors[0], interceptors[1]
+
+	// The transports are created with the API that carries the Interceptor
+	assert.True(t, pcOffer.dtlsTransport.api == pcOffer.api)
+
+	track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := pcOffer.AddTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []byte, 1)
+	pcAnswer.OnTrack(func(remote *Track, r *RTPReceiver) {
+		packet, readErr := remote.ReadRTP()
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		received <- packet.Payload
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2}, Payload: []byte{0xAA}}))
+			case payload := <-received:
+				assert.Equal(t, []byte{0xBB}, payload)
+				return
+			}
+		}
+	}()
+
+	// RTCP is written and read through the Interceptors as well
+	assert.NoError(t, pcAnswer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: track.SSRC()}}))
+	pkts, err := sender.ReadRTCP()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{track.SSRC()}, pkts[0].DestinationSSRC())
+
+	closePairConnected(t, pcOffer, pcAnswer)
+
+	offerInterceptor.mu.Lock()
+	if assert.Len(t, offerInterceptor.localStreams, 1) {
+		info := offerInterceptor.localStreams[0]
+		assert.Equal(t, track.SSRC(), info.SSRC)
+		assert.Equal(t, uint8(DefaultPayloadTypeVP8), info.PayloadType)
+		assert.Equal(t, "video/VP8", info.MimeType)
+		assert.Equal(t, "video", info.ID)
+	}
+	assert.Equal(t, 1, offerInterceptor.rtcpRead)
+	assert.True(t, offerInterceptor.closed)
+	offerInterceptor.mu.Unlock()
+
+	answerInterceptor.mu.Lock()
+	if assert.Len(t, answerInterceptor.remoteStreams, 1) {
+		info := answerInterceptor.remoteStreams[0]
+		assert.Equal(t, track.SSRC(), info.SSRC)
+		assert.Equal(t, uint8(DefaultPayloadTypeVP8), info.PayloadType)
+		assert.Equal(t, "video/VP8", info.MimeType)
+		assert.Contains(t, info.RTCPFeedback, interceptor.RTCPFeedback{Type: TypeRTCPFBNACK})
+	}
+	assert.Equal(t, 2, answerInterceptor.rtpRead)
+	assert.Equal(t, 1, answerInterceptor.rtcpWritten)
+	assert.True(t, answerInterceptor.closed)
+	answerInterceptor.mu.Unlock()
+}
+
+func TestPeerConnection_InterceptorClosedOnError(t *testing.T) {
+	interceptors := []*testInterceptor{}
+	registry := &interceptor.Registry{}
+	registry.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		i := &testInterceptor{}
+		interceptors = append(interceptors, i)
+		return i, nil
+	}))
+
+	api := NewAPI(WithInterceptorRegistry(registry))
+	_, err := api.NewPeerConnection(Configuration{
+		ICEServers: []ICEServer{{URLs: []string{"turns:google.de?transport=tcp"}, Username: "unittest"}},
+	})
+	assert.Error(t, err)
+
+	if assert.Len(t, interceptors, 1) {
+		interceptors[0].mu.Lock()
+		assert.True(t, interceptors[0].closed)
+		interceptors[0].mu.Unlock()
+	}
+}
+
+// dropInterceptor drops the first write of a sequence number, and
+// records the SSRCs packets are written with
+type dropInterceptor struct {
+	interceptor.NoOp
+	seqNum uint16
+
+	mu      sync.Mutex
+	dropped bool
+	ssrcs   map[uint32]bool
+}
+
+func (i *dropInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if header.SSRC == info.SSRC && header.SequenceNumber == i.seqNum && !i.dropped {
+			i.dropped = true
+			return len(payload), nil
+		}
+		i.ssrcs[header.SSRC] = true
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+// sendWithDroppedPacket sends a VP8 track with NACK enabled until the packet
+// dropInterceptor dropped was recovered
+func sendWithDroppedPacket(t *testing.T, m MediaEngine) (pcOffer *PeerConnection, sender *RTPSender, drop *dropInterceptor) {
+	const droppedSeqNum = 5
+
+	registry := &interceptor.Registry{}
+	ConfigureNack(registry)
+	registry.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		i := &dropInterceptor{seqNum: droppedSeqNum, ssrcs: map[uint32]bool{}}
+		if drop == nil {
+			drop = i
+		}
+		return i, nil
+	}))
+
+	api := NewAPI(WithMediaEngine(m), WithInterceptorRegistry(registry))
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err = pcOffer.AddTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	// NACKs are answered while RTCP is read
+	go func() {
+		for {
+			if _, readErr := sender.ReadRTCP(); readErr != nil {
+				return
+			}
+		}
+	}()
+
+	started, recovered := make(chan struct{}), make(chan struct{})
+	pcAnswer.OnTrack(func(remote *Track, r *RTPReceiver) {
+		close(started)
+		for {
+			packet, readErr := remote.ReadRTP()
+			if readErr != nil {
+				return
+			}
+			if packet.SequenceNumber == droppedSeqNum {
+				assert.Equal(t, track.SSRC(), packet.SSRC)
+				assert.Equal(t, uint8(DefaultPayloadTypeVP8), packet.PayloadType)
+				assert.Equal(t, []byte{0xAA}, packet.Payload)
+				close(recovered)
+			}
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, pcOffer.LocalDescription().SDP, fmt.Sprintf("a=rtcp-fb:%d nack\r\n", DefaultPayloadTypeVP8))
+
+	// Sequence numbers only advance once packets arrive, so the dropped one is really sent.
+	// Sending pauses after the packet that reveals the loss, so the retransmission
+	// has to reach the Track without media following it
+	func() {
+		seqNum := uint16(0)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				if seqNum > droppedSeqNum+1 {
+					continue
+				}
+				assert.NoError(t, track.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: seqNum}, Payload: []byte{0xAA}}))
+				select {
+				case <-started:
+					seqNum++
+				default:
+				}
+			case <-recovered:
+				return
+			}
+		}
+	}()
+
+	closePairConnected(t, pcOffer, pcAnswer)
+	return pcOffer, sender, drop
+}
+
+func TestPeerConnection_Nack(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := MediaEngine{}
+	m.RegisterCodec(NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000))
+	pcOffer, sender, drop := sendWithDroppedPacket(t, m)
+
+	// Without RTX the packet is resent on its own SSRC
+	assert.NotContains(t, pcOffer.LocalDescription().SDP, "a=ssrc-group:FID")
+	assert.Equal(t, RTPRtxParameters{}, sender.GetParameters().Encodings[0].RTX)
+	drop.mu.Lock()
+	assert.Equal(t, map[uint32]bool{sender.Track().SSRC(): true}, drop.ssrcs)
+	drop.mu.Unlock()
+}
+
+func TestPeerConnection_RTX(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+	pcOffer, sender, drop := sendWithDroppedPacket(t, m)
+
+	ssrc, rtxSSRC := sender.Track().SSRC(), sender.GetParameters().Encodings[0].RTX.SSRC
+	assert.NotEqual(t, uint32(0), rtxSSRC)
+
+	offer := pcOffer.LocalDescription().SDP
+	assert.Contains(t, offer, fmt.Sprintf("a=rtpmap:%d rtx/90000\r\n", DefaultPayloadTypeRTXVP8))
+	assert.Contains(t, offer, fmt.Sprintf("a=fmtp:%d apt=%d\r\n", DefaultPayloadTypeRTXVP8, DefaultPayloadTypeVP8))
+	assert.Contains(t, offer, fmt.Sprintf("a=ssrc-group:FID %d %d\r\n", ssrc, rtxSSRC))
+	assert.Contains(t, offer, fmt.Sprintf("a=ssrc:%d msid:pion video\r\n", rtxSSRC))
+
+	// The packet was resent on the RTX SSRC
+	drop.mu.Lock()
+	assert.Equal(t, map[uint32]bool{ssrc: true, rtxSSRC: true}, drop.ssrcs)
+	drop.mu.Unlock()
+}
+
+// dropMarkedInterceptor drops the first media packet whose payload ends with 0xBB
+type dropMarkedInterceptor struct {
+	interceptor.NoOp
+
+	mu      sync.Mutex
+	dropped bool
+	ssrcs   map[uint32]bool
+}
+
+func (i *dropMarkedInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if header.SSRC == info.SSRC && len(payload) != 0 && payload[len(payload)-1] == 0xBB && !i.dropped {
+			i.dropped = true
+			return len(payload), nil
+		}
+		i.ssrcs[header.SSRC] = true
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+// sendWithFEC sends a VP8 track protected by FEC until the packet
+// dropMarkedInterceptor dropped was recovered
+func sendWithFEC(t *testing.T, m MediaEngine) (pcOffer *PeerConnection, sender *RTPSender, drop *dropMarkedInterceptor) {
+	const droppedSeqNum = 5
+
+	registry := &interceptor.Registry{}
+	ConfigureFEC(registry, 0.5)
+	registry.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		i := &dropMarkedInterceptor{ssrcs: map[uint32]bool{}}
+		if drop == nil {
+			drop = i
+		}
+		return i, nil
+	}))
+
+	api := NewAPI(WithMediaEngine(m), WithInterceptorRegistry(registry))
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err = pcOffer.AddTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	started, recovered := make(chan struct{}), make(chan struct{})
+	pcAnswer.OnTrack(func(remote *Track, r *RTPReceiver) {
+		close(started)
+		for {
+			packet, readErr := remote.ReadRTP()
+			if readErr != nil {
+				return
+			}
+			assert.Equal(t, track.SSRC(), packet.SSRC)
+			assert.Equal(t, uint8(DefaultPayloadTypeVP8), packet.PayloadType)
+			if bytes.Equal(packet.Payload, []byte{0xBB}) {
+				close(recovered)
+			}
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every packet ends a frame, so every two packets are protected by a FEC packet
+	func() {
+		seqNum := uint16(0)
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				payload := []byte{0xAA}
+				if seqNum == droppedSeqNum {
+					payload = []byte{0xBB}
+				}
+				assert.NoError(t, track.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, Marker: true, SequenceNumber: seqNum}, Payload: payload}))
+				select {
+				case <-started:
+					seqNum++
+				default:
+				}
+			case <-recovered:
+				return
+			}
+		}
+	}()
+
+	closePairConnected(t, pcOffer, pcAnswer)
+	return pcOffer, sender, drop
+}
+
+func TestPeerConnection_ULPFEC(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := MediaEngine{}
+	m.RegisterCodec(NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000))
+	m.RegisterCodec(NewRTPREDCodec(DefaultPayloadTypeRED, 90000))
+	m.RegisterCodec(NewRTPULPFECCodec(DefaultPayloadTypeULPFEC, 90000))
+	pcOffer, sender, drop := sendWithFEC(t, m)
+
+	offer := pcOffer.LocalDescription().SDP
+	assert.Contains(t, offer, fmt.Sprintf("a=rtpmap:%d red/90000\r\n", DefaultPayloadTypeRED))
+	assert.Contains(t, offer, fmt.Sprintf("a=rtpmap:%d ulpfec/90000\r\n", DefaultPayloadTypeULPFEC))
+	assert.NotContains(t, offer, "a=ssrc-group:FEC-FR")
+	assert.Equal(t, RTPFecParameters{Mechanism: "red+ulpfec"}, sender.GetParameters().Encodings[0].FEC)
+
+	// ULPFEC is sent on the SSRC of the Track
+	drop.mu.Lock()
+	assert.Equal(t, map[uint32]bool{sender.Track().SSRC(): true}, drop.ssrcs)
+	drop.mu.Unlock()
+}
+
+func TestPeerConnection_FlexFEC(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	m := MediaEngine{}
+	m.RegisterCodec(NewRTPVP8Codec(DefaultPayloadTypeVP8, 90000))
+	m.RegisterCodec(NewRTPFlexFEC03Codec(DefaultPayloadTypeFlexFEC03, 90000))
+	pcOffer, sender, drop := sendWithFEC(t, m)
+
+	ssrc, fec := sender.Track().SSRC(), sender.GetParameters().Encodings[0].FEC
+	assert.NotEqual(t, uint32(0), fec.SSRC)
+	assert.Equal(t, "flexfec", fec.Mechanism)
+
+	offer := pcOffer.LocalDescription().SDP
+	assert.Contains(t, offer, fmt.Sprintf("a=rtpmap:%d flexfec-03/90000\r\n", DefaultPayloadTypeFlexFEC03))
+	assert.Contains(t, offer, fmt.Sprintf("a=fmtp:%d repair-window=10000000\r\n", DefaultPayloadTypeFlexFEC03))
+	assert.Contains(t, offer, fmt.Sprintf("a=ssrc-group:FEC-FR %d %d\r\n", ssrc, fec.SSRC))
+	assert.Contains(t, offer, fmt.Sprintf("a=ssrc:%d msid:pion video\r\n", fec.SSRC))
+
+	drop.mu.Lock()
+	assert.Equal(t, map[uint32]bool{ssrc: true, fec.SSRC: true}, drop.ssrcs)
+	drop.mu.Unlock()
+}
+
+// reportInterceptor passes the Sender Reports that are written to a channel
+type reportInterceptor struct {
+	interceptor.NoOp
+	reported chan *rtcp.SenderReport
+}
+
+func (i *reportInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		for _, pkt := range pkts {
+			if senderReport, ok := pkt.(*rtcp.SenderReport); ok {
+				select {
+				case i.reported <- senderReport:
+				default:
+				}
+			}
+		}
+		return writer.Write(pkts, attributes)
+	})
+}
+
+func TestPeerConnection_SenderReports(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	reported := make(chan *rtcp.SenderReport, 1)
+	registry := &interceptor.Registry{}
+	registry.Add(interceptor.FactoryFunc(func() (interceptor.Interceptor, error) {
+		return &reportInterceptor{reported: reported}, nil
+	}))
+
+	s := SettingEngine{}
+	s.SetSenderReportInterval(50 * time.Millisecond)
+	m := MediaEngine{}
+	m.RegisterDefaultCodecs()
+	api := NewAPI(WithMediaEngine(m), WithSettingEngine(s), WithInterceptorRegistry(registry))
+	pcOffer, pcAnswer, err := api.newPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	track, err := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), "video", "pion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTrack(track); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	pcAnswer.OnTrack(func(remote *Track, r *RTPReceiver) {
+		for {
+			if _, readErr := remote.ReadRTP(); readErr != nil {
+				return
+			}
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := uint32(0)
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				assert.NoError(t, track.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: uint16(sent), Timestamp: sent * 3000}, Payload: []byte{0xAA, 0xBB}}))
+				sent++
+			case senderReport := <-reported:
+				assert.Equal(t, track.SSRC(), senderReport.SSRC)
+				assert.True(t, senderReport.PacketCount > 0 && senderReport.PacketCount <= sent)
+				assert.Equal(t, senderReport.PacketCount*2, senderReport.OctetCount)
+				assert.True(t, senderReport.RTPTime >= (senderReport.PacketCount-1)*3000)
+				return
+			}
+		}
+	}()
+
+	closePairConnected(t, pcOffer, pcAnswer)
+}
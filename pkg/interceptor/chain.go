@@ -0,0 +1,71 @@
+package interceptor
+
+import (
+	"github.com/pion/webrtc/v2/internal/util"
+)
+
+// Chain is an Interceptor that runs a list of Interceptors. Outgoing packets
+// pass them in order, so the last one is closest to the network
+type Chain struct {
+	interceptors []Interceptor
+}
+
+// NewChain returns a new Chain of interceptors
+func NewChain(interceptors []Interceptor) *Chain {
+	return &Chain{interceptors: interceptors}
+}
+
+// BindRTCPReader binds the RTCPReader of every Interceptor, the last one reads first
+func (c *Chain) BindRTCPReader(reader RTCPReader) RTCPReader {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		reader = c.interceptors[i].BindRTCPReader(reader)
+	}
+	return reader
+}
+
+// BindRTCPWriter binds the RTCPWriter of every Interceptor, the first one writes first
+func (c *Chain) BindRTCPWriter(writer RTCPWriter) RTCPWriter {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		writer = c.interceptors[i].BindRTCPWriter(writer)
+	}
+	return writer
+}
+
+// BindLocalStream binds the RTPWriter of every Interceptor, the first one writes first
+func (c *Chain) BindLocalStream(info *StreamInfo, writer RTPWriter) RTPWriter {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		writer = c.interceptors[i].BindLocalStream(info, writer)
+	}
+	return writer
+}
+
+// UnbindLocalStream unbinds the local stream from every Interceptor
+func (c *Chain) UnbindLocalStream(info *StreamInfo) {
+	for _, i := range c.interceptors {
+		i.UnbindLocalStream(info)
+	}
+}
+
+// BindRemoteStream binds the RTPReader of every Interceptor, the last one reads first
+func (c *Chain) BindRemoteStream(info *StreamInfo, reader RTPReader) RTPReader {
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		reader = c.interceptors[i].BindRemoteStream(info, reader)
+	}
+	return reader
+}
+
+// UnbindRemoteStream unbinds the remote stream from every Interceptor
+func (c *Chain) UnbindRemoteStream(info *StreamInfo) {
+	for _, i := range c.interceptors {
+		i.UnbindRemoteStream(info)
+	}
+}
+
+// Close closes all the Interceptors
+func (c *Chain) Close() error {
+	var closeErrs []error
+	for _, i := range c.interceptors {
+		closeErrs = append(closeErrs, i.Close())
+	}
+	return util.FlattenErrs(closeErrs)
+}
@@ -0,0 +1,96 @@
+package interceptor
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+// namedInterceptor records the order it is passed in
+type namedInterceptor struct {
+	NoOp
+	name     string
+	order    *[]string
+	closeErr error
+}
+
+func (i *namedInterceptor) BindLocalStream(_ *StreamInfo, writer RTPWriter) RTPWriter {
+	return RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes Attributes) (int, error) {
+		*i.order = append(*i.order, i.name)
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func (i *namedInterceptor) BindRemoteStream(_ *StreamInfo, reader RTPReader) RTPReader {
+	return RTPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		*i.order = append(*i.order, i.name)
+		return n, attributes, err
+	})
+}
+
+func (i *namedInterceptor) BindRTCPWriter(writer RTCPWriter) RTCPWriter {
+	return RTCPWriterFunc(func(pkts []rtcp.Packet, attributes Attributes) (int, error) {
+		*i.order = append(*i.order, i.name)
+		return writer.Write(pkts, attributes)
+	})
+}
+
+func (i *namedInterceptor) BindRTCPReader(reader RTCPReader) RTCPReader {
+	return RTCPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		*i.order = append(*i.order, i.name)
+		return n, attributes, err
+	})
+}
+
+func (i *namedInterceptor) Close() error {
+	return i.closeErr
+}
+
+func TestChain_Order(t *testing.T) {
+	order := []string{}
+	chain := NewChain([]Interceptor{
+		&namedInterceptor{name: "first", order: &order},
+		&namedInterceptor{name: "second", order: &order},
+	})
+
+	writer := chain.BindLocalStream(&StreamInfo{}, RTPWriterFunc(func(header *rtp.Header, payload []byte, _ Attributes) (int, error) {
+		order = append(order, "network")
+		return len(payload), nil
+	}))
+	_, err := writer.Write(&rtp.Header{}, []byte{0x00}, Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "network"}, order)
+
+	order = []string{}
+	reader := chain.BindRemoteStream(&StreamInfo{}, RTPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		order = append(order, "network")
+		return len(b), attributes, nil
+	}))
+	_, _, err = reader.Read(make([]byte, 1), Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"network", "second", "first"}, order)
+
+	order = []string{}
+	rtcpWriter := chain.BindRTCPWriter(RTCPWriterFunc(func(pkts []rtcp.Packet, _ Attributes) (int, error) {
+		order = append(order, "network")
+		return 0, nil
+	}))
+	_, err = rtcpWriter.Write([]rtcp.Packet{}, Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "network"}, order)
+
+	order = []string{}
+	rtcpReader := chain.BindRTCPReader(RTCPReaderFunc(func(b []byte, attributes Attributes) (int, Attributes, error) {
+		order = append(order, "network")
+		return len(b), attributes, nil
+	}))
+	_, _, err = rtcpReader.Read(make([]byte, 1), Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"network", "second", "first"}, order)
+
+	assert.NoError(t, chain.Close())
+}
@@ -0,0 +1,238 @@
+package fec
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+const (
+	// receivedPacketsSize is how many media packets of a stream are kept to
+	// recover packets with, it must be a power of two
+	receivedPacketsSize = 256
+
+	// maxFECPackets is how many FEC packets wait for the packets they protect
+	maxFECPackets = 32
+)
+
+// remoteStream is a remote stream that negotiated FEC
+type remoteStream struct {
+	ssrc             uint32
+	fecSSRC          uint32
+	fecPayloadType   uint8
+	redPayloadType   uint8
+	received         [receivedPacketsSize][]byte
+	lastSeqNum       uint16
+	started          bool
+	fecPackets       []*fecPacket
+	recoveredPackets [][]byte
+	mu               sync.Mutex
+}
+
+// DecoderInterceptor recovers the lost packets of remote streams that
+// negotiated FEC, before they are read. FEC packets are consumed, and RED
+// packets are unwrapped
+type DecoderInterceptor struct {
+	interceptor.NoOp
+}
+
+// DecoderOption can be used to configure a DecoderInterceptor
+type DecoderOption func(d *DecoderInterceptor) error
+
+// NewDecoderInterceptor returns a new DecoderInterceptor
+func NewDecoderInterceptor(opts ...DecoderOption) (*DecoderInterceptor, error) {
+	d := &DecoderInterceptor{}
+
+	for _, opt := range opts {
+		if err := opt(d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// BindRemoteStream recovers the packets of streams that negotiated FEC. A remote
+// stream may use either mechanism, so RED is unwrapped if its payload type is
+// set and FlexFEC is read if its SSRC is set
+func (d *DecoderInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	if info.PayloadTypeForwardErrorCorrection == 0 || (info.PayloadTypeRED == 0 && info.SSRCForwardErrorCorrection == 0) {
+		return reader
+	}
+
+	stream := &remoteStream{
+		ssrc:           info.SSRC,
+		fecSSRC:        info.SSRCForwardErrorCorrection,
+		fecPayloadType: info.PayloadTypeForwardErrorCorrection,
+		redPayloadType: info.PayloadTypeRED,
+	}
+
+	return interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		for {
+			if n, ok := stream.popRecovered(b); ok {
+				return n, attributes, nil
+			}
+
+			n, attributes, err := reader.Read(b, attributes)
+			if err != nil {
+				return n, attributes, err
+			}
+
+			n, ok := stream.handle(b, n)
+			if ok {
+				return n, attributes, nil
+			}
+		}
+	})
+}
+
+// popRecovered copies the oldest recovered packet that wasn't read yet into b
+func (s *remoteStream) popRecovered(b []byte) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.recoveredPackets) == 0 {
+		return 0, false
+	}
+	n := copy(b, s.recoveredPackets[0])
+	s.recoveredPackets = s.recoveredPackets[1:]
+	return n, true
+}
+
+// handle processes the packet of n bytes read into b, it returns false if
+// the packet is consumed. Media packets are unwrapped in place
+func (s *remoteStream) handle(b []byte, n int) (int, bool) {
+	header := rtp.Header{}
+	if err := header.Unmarshal(b[:n]); err != nil {
+		return n, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.fecSSRC != 0 && header.SSRC == s.fecSSRC:
+		p, ssrc, err := unmarshalFlexFEC(b[header.PayloadOffset:n])
+		if err == nil && ssrc == s.ssrc {
+			s.addFEC(p)
+		}
+		return 0, false
+	case header.SSRC != s.ssrc:
+		return n, true
+	case s.redPayloadType != 0 && header.PayloadType == s.redPayloadType:
+		payloadType, payload, err := unwrapRED(b[header.PayloadOffset:n])
+		if err != nil {
+			return 0, false
+		}
+		if payloadType == s.fecPayloadType {
+			if p, err := unmarshalULPFEC(payload); err == nil {
+				s.addFEC(p)
+			}
+			return 0, false
+		}
+
+		header.PayloadType = payloadType
+		raw, err := (&rtp.Packet{Header: header, Payload: payload}).Marshal()
+		if err != nil {
+			return 0, false
+		}
+		n = copy(b, raw)
+	}
+
+	if s.get(header.SequenceNumber) != nil {
+		// A retransmission of a packet that already arrived or was recovered
+		return 0, false
+	}
+	s.add(append([]byte{}, b[:n]...))
+	s.recover()
+	return n, true
+}
+
+// get returns the media packet with the sequence number, if it is still kept
+func (s *remoteStream) get(seqNum uint16) []byte {
+	raw := s.received[seqNum%receivedPacketsSize]
+	if raw == nil || binary.BigEndian.Uint16(raw[2:]) != seqNum || !s.isRecent(seqNum) {
+		return nil
+	}
+	return raw
+}
+
+// add keeps a media packet
+func (s *remoteStream) add(raw []byte) {
+	seqNum := binary.BigEndian.Uint16(raw[2:])
+	s.received[seqNum%receivedPacketsSize] = raw
+	if !s.started || seqNum-s.lastSeqNum < uint16SizeHalf {
+		s.lastSeqNum = seqNum
+		s.started = true
+	}
+}
+
+// isRecent tells if the sequence number is still in the window of kept packets
+func (s *remoteStream) isRecent(seqNum uint16) bool {
+	diff := s.lastSeqNum - seqNum
+	return diff < receivedPacketsSize/2 || diff >= uint16SizeHalf
+}
+
+// addFEC keeps a FEC packet until the packets it protects arrived
+func (s *remoteStream) addFEC(p *fecPacket) {
+	if len(p.offsets) == 0 {
+		return
+	}
+	if len(s.fecPackets) == maxFECPackets {
+		s.fecPackets = s.fecPackets[1:]
+	}
+	s.fecPackets = append(s.fecPackets, p)
+	s.recover()
+}
+
+// recover uses FEC packets that miss a single packet to recover it, which
+// may make other FEC packets usable. FEC packets that are complete or miss
+// packets that aren't kept anymore are dropped
+func (s *remoteStream) recover() {
+	for recovered := true; recovered; {
+		recovered = false
+
+		pending := []*fecPacket{}
+		for _, p := range s.fecPackets {
+			missing := []uint16{}
+			usable := true
+			for _, seqNum := range p.protects() {
+				if !s.isRecent(seqNum) {
+					usable = false
+				} else if s.get(seqNum) == nil {
+					missing = append(missing, seqNum)
+				}
+			}
+
+			switch {
+			case !usable || len(missing) == 0:
+			case len(missing) == 1:
+				if raw := s.recoverPacket(p, missing[0]); raw != nil {
+					s.add(raw)
+					s.recoveredPackets = append(s.recoveredPackets, raw)
+					recovered = true
+				}
+			default:
+				pending = append(pending, p)
+			}
+		}
+		s.fecPackets = pending
+	}
+}
+
+// recoverPacket XORs the FEC packet with all packets it protects but the missing one
+func (s *remoteStream) recoverPacket(p *fecPacket, missing uint16) []byte {
+	bits := bitString{header: p.bits.header, body: append([]byte{}, p.bits.body...)}
+	for _, seqNum := range p.protects() {
+		if seqNum != missing {
+			bits.xor(s.get(seqNum))
+		}
+	}
+
+	raw, err := bits.recover(missing, s.ssrc)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
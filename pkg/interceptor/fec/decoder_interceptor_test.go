@@ -0,0 +1,115 @@
+package fec
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+// decode reads packets from a stream bound to a DecoderInterceptor, and
+// returns the media packets that were read
+func decode(t *testing.T, info *interceptor.StreamInfo, packets []*rtp.Packet) []*rtp.Packet {
+	d, err := NewDecoderInterceptor()
+	assert.NoError(t, err)
+
+	reader := d.BindRemoteStream(info, interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		if len(packets) == 0 {
+			return 0, attributes, io.EOF
+		}
+		raw, err := packets[0].Marshal()
+		assert.NoError(t, err)
+		packets = packets[1:]
+		return copy(b, raw), attributes, nil
+	}))
+
+	read := []*rtp.Packet{}
+	for {
+		b := make([]byte, 1500)
+		n, _, err := reader.Read(b, interceptor.Attributes{})
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		p := &rtp.Packet{}
+		assert.NoError(t, p.Unmarshal(b[:n]))
+		read = append(read, p)
+	}
+
+	assert.NoError(t, d.Close())
+	return read
+}
+
+// sequenceNumbers returns the sequence numbers of media packets, and checks they weren't changed
+func sequenceNumbers(t *testing.T, packets []*rtp.Packet) []uint16 {
+	seqNums := []uint16{}
+	for _, p := range packets {
+		assert.Equal(t, uint32(1), p.SSRC)
+		assert.Equal(t, uint8(96), p.PayloadType)
+		assert.Equal(t, uint32(p.SequenceNumber)*3000, p.Timestamp)
+		assert.Equal(t, p.SequenceNumber == 13, p.Marker)
+		seqNums = append(seqNums, p.SequenceNumber)
+	}
+	return seqNums
+}
+
+func TestDecoderInterceptor_ULPFEC(t *testing.T) {
+	info := &interceptor.StreamInfo{
+		SSRC:                              1,
+		PayloadType:                       96,
+		PayloadTypeRED:                    116,
+		PayloadTypeForwardErrorCorrection: 117,
+	}
+	written := encode(t, info, []uint16{10, 11, 12, 13}, 13)
+
+	// Every FEC packet recovers one of the packets it protects
+	read := decode(t, info, []*rtp.Packet{written[0], written[3], written[4], written[5]})
+	assert.Equal(t, []uint16{10, 13, 12, 11}, sequenceNumbers(t, read))
+	assert.Equal(t, []byte{12, 0xAA}, read[2].Payload)
+	assert.Equal(t, []byte{11, 0xAA}, read[3].Payload)
+
+	// Packets that arrive again after they were recovered are dropped
+	read = decode(t, info, []*rtp.Packet{written[0], written[4], written[2], written[2], written[3]})
+	assert.Equal(t, []uint16{10, 12, 13}, sequenceNumbers(t, read))
+
+	// Two missing packets can't be recovered by one FEC packet
+	read = decode(t, info, []*rtp.Packet{written[1], written[3], written[4]})
+	assert.Equal(t, []uint16{11, 13}, sequenceNumbers(t, read))
+}
+
+func TestDecoderInterceptor_FlexFEC(t *testing.T) {
+	info := &interceptor.StreamInfo{
+		SSRC:                              1,
+		PayloadType:                       96,
+		SSRCForwardErrorCorrection:        2,
+		PayloadTypeForwardErrorCorrection: 118,
+	}
+	written := encode(t, info, []uint16{10, 11, 12, 13}, 13)
+
+	// A FEC packet that arrives first waits for the packets it protects
+	read := decode(t, info, []*rtp.Packet{written[4], written[2], written[1], written[5]})
+	assert.Equal(t, []uint16{12, 10, 11, 13}, sequenceNumbers(t, read))
+
+	// FEC packets of other SSRCs are dropped, packets of other streams are read as they are
+	other := *written[0]
+	other.SSRC = 3
+	fec, _, err := unmarshalFlexFEC(written[4].Payload)
+	assert.NoError(t, err)
+	otherFEC := *written[4]
+	otherFEC.Payload = marshalFlexFEC(&fec.bits, 3, fec.seqNumBase, fec.offsets)
+	read = decode(t, info, []*rtp.Packet{&otherFEC, &other, written[2]})
+	assert.Equal(t, 2, len(read))
+	assert.Equal(t, uint32(3), read[0].SSRC)
+	assert.Equal(t, uint16(12), read[1].SequenceNumber)
+}
+
+func TestDecoderInterceptor_Unprotected(t *testing.T) {
+	written := encode(t, &interceptor.StreamInfo{SSRC: 1, PayloadType: 96}, []uint16{10, 11})
+
+	// Without a FEC payload type packets are read as they are
+	read := decode(t, &interceptor.StreamInfo{SSRC: 1, PayloadType: 96, PayloadTypeRED: 116}, []*rtp.Packet{written[0], written[0]})
+	assert.Equal(t, []uint16{10, 10}, sequenceNumbers(t, read))
+}
@@ -0,0 +1,205 @@
+package fec
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/pion/logging"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+// localStream is a local stream that negotiated FEC
+type localStream struct {
+	rtpWriter       interceptor.RTPWriter
+	ssrc            uint32
+	protectionRatio float64
+
+	// ULPFEC packets are sent on the stream itself, so the sequence numbers
+	// of all media packets that follow them are shifted
+	ulpfec         bool
+	redPayloadType uint8
+	seqNumOffset   uint16
+
+	// FlexFEC packets are sent on a stream of their own
+	fecSSRC           uint32
+	fecPayloadType    uint8
+	fecSequenceNumber uint16
+
+	// The marshaled media packets protected by the next FEC packets
+	block [][]byte
+	mu    sync.Mutex
+}
+
+// EncoderInterceptor sends FEC packets along with the media packets of local
+// streams that negotiated FEC, so the receiver can recover lost packets
+type EncoderInterceptor struct {
+	interceptor.NoOp
+	protectionRatio float64
+	log             logging.LeveledLogger
+}
+
+// EncoderOption can be used to configure an EncoderInterceptor
+type EncoderOption func(e *EncoderInterceptor) error
+
+// EncoderProtectionRatio sets how many FEC packets are sent per media packet,
+// it must be greater than 0 and at most 1
+func EncoderProtectionRatio(protectionRatio float64) EncoderOption {
+	return func(e *EncoderInterceptor) error {
+		e.protectionRatio = protectionRatio
+		return nil
+	}
+}
+
+// EncoderLog sets the logger of the EncoderInterceptor
+func EncoderLog(log logging.LeveledLogger) EncoderOption {
+	return func(e *EncoderInterceptor) error {
+		e.log = log
+		return nil
+	}
+}
+
+// NewEncoderInterceptor returns a new EncoderInterceptor
+func NewEncoderInterceptor(opts ...EncoderOption) (*EncoderInterceptor, error) {
+	e := &EncoderInterceptor{
+		protectionRatio: defaultProtectionRatio,
+		log:             logging.NewDefaultLoggerFactory().NewLogger("fec_encoder"),
+	}
+
+	for _, opt := range opts {
+		if err := opt(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.protectionRatio <= 0 || e.protectionRatio > 1 {
+		return nil, errInvalidProtectionRatio
+	}
+	return e, nil
+}
+
+// BindLocalStream protects the packets written to streams that negotiated FEC
+func (e *EncoderInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	ulpfec, flexfec := streamFEC(info)
+	if !ulpfec && !flexfec {
+		return writer
+	}
+
+	stream := &localStream{
+		rtpWriter:         writer,
+		ssrc:              info.SSRC,
+		protectionRatio:   e.protectionRatio,
+		ulpfec:            ulpfec,
+		redPayloadType:    info.PayloadTypeRED,
+		fecSSRC:           info.SSRCForwardErrorCorrection,
+		fecPayloadType:    info.PayloadTypeForwardErrorCorrection,
+		fecSequenceNumber: uint16(rand.Uint32()), // nolint:gosec
+	}
+
+	// A block is at least as long as it takes to send one FEC packet for it
+	minBlockSize := int(math.Ceil(1 / e.protectionRatio))
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		// Packets of other streams, like retransmissions, are not protected
+		if header.SSRC != stream.ssrc {
+			return writer.Write(header, payload, attributes)
+		}
+
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+
+		// The offsets of a block must fit the mask of a FEC packet
+		if len(stream.block) != 0 && header.SequenceNumber+stream.seqNumOffset-stream.seqNumBase() >= maxBlockSize {
+			if err := stream.flush(); err != nil {
+				e.log.Warnf("failed sending fec packet: %v", err)
+			}
+		}
+
+		n, err := stream.write(header, payload, attributes)
+		if err != nil {
+			return n, err
+		}
+
+		if len(stream.block) == maxBlockSize || (header.Marker && len(stream.block) >= minBlockSize) {
+			if err := stream.flush(); err != nil {
+				e.log.Warnf("failed sending fec packet: %v", err)
+			}
+		}
+		return n, nil
+	})
+}
+
+// write sends a media packet and adds it to the block, s.mu must be held
+func (s *localStream) write(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+	protected := *header
+	protected.SequenceNumber += s.seqNumOffset
+	raw, err := (&rtp.Packet{Header: protected, Payload: payload}).Marshal()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	if s.ulpfec {
+		red := protected
+		red.PayloadType = s.redPayloadType
+		n, err = s.rtpWriter.Write(&red, wrapRED(protected.PayloadType, payload), attributes)
+	} else {
+		n, err = s.rtpWriter.Write(&protected, payload, attributes)
+	}
+	if err != nil {
+		return n, err
+	}
+
+	s.block = append(s.block, raw)
+	return n, nil
+}
+
+// seqNumBase returns the sequence number of the first packet of the block, s.mu must be held
+func (s *localStream) seqNumBase() uint16 {
+	return binary.BigEndian.Uint16(s.block[0][2:])
+}
+
+// flush sends the FEC packets of the block, every one of them protects an
+// interleaved part of it. They carry the timestamp of its last packet. s.mu must be held
+func (s *localStream) flush() error {
+	seqNumBase := s.seqNumBase()
+	block := s.block
+	s.block = nil
+
+	fecCount := int(math.Ceil(float64(len(block)) * s.protectionRatio))
+	lastSeqNum := binary.BigEndian.Uint16(block[len(block)-1][2:])
+	timestamp := binary.BigEndian.Uint32(block[len(block)-1][4:])
+
+	for i := 0; i < fecCount; i++ {
+		bits := &bitString{}
+		offsets := []uint16{}
+		for j := i; j < len(block); j += fecCount {
+			bits.xor(block[j])
+			offsets = append(offsets, binary.BigEndian.Uint16(block[j][2:])-seqNumBase)
+		}
+
+		header := rtp.Header{Version: 2, Timestamp: timestamp}
+		var payload []byte
+		if s.ulpfec {
+			lastSeqNum++
+			s.seqNumOffset++
+			header.SSRC = s.ssrc
+			header.PayloadType = s.redPayloadType
+			header.SequenceNumber = lastSeqNum
+			payload = wrapRED(s.fecPayloadType, marshalULPFEC(bits, seqNumBase, offsets))
+		} else {
+			header.SSRC = s.fecSSRC
+			header.PayloadType = s.fecPayloadType
+			header.SequenceNumber = s.fecSequenceNumber
+			s.fecSequenceNumber++
+			payload = marshalFlexFEC(bits, s.ssrc, seqNumBase, offsets)
+		}
+
+		if _, err := s.rtpWriter.Write(&header, payload, interceptor.Attributes{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package fec
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+// encode writes media packets with the sequence numbers to a stream bound to
+// an EncoderInterceptor, and returns everything it wrote. Frames end with
+// the marker sequence numbers
+func encode(t *testing.T, info *interceptor.StreamInfo, seqNums []uint16, markers ...uint16) []*rtp.Packet {
+	e, err := NewEncoderInterceptor(EncoderProtectionRatio(0.5))
+	assert.NoError(t, err)
+
+	written := []*rtp.Packet{}
+	writer := e.BindLocalStream(info, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		written = append(written, &rtp.Packet{Header: *header, Payload: append([]byte{}, payload...)})
+		return len(payload), nil
+	}))
+
+	for _, seqNum := range seqNums {
+		header := &rtp.Header{Version: 2, SSRC: 1, PayloadType: 96, SequenceNumber: seqNum, Timestamp: uint32(seqNum) * 3000}
+		for _, marker := range markers {
+			header.Marker = header.Marker || marker == seqNum
+		}
+		_, err = writer.Write(header, []byte{byte(seqNum), 0xAA}, interceptor.Attributes{})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, e.Close())
+	return written
+}
+
+func TestNewEncoderInterceptor(t *testing.T) {
+	for _, protectionRatio := range []float64{0, -0.5, 1.5} {
+		_, err := NewEncoderInterceptor(EncoderProtectionRatio(protectionRatio))
+		assert.Equal(t, errInvalidProtectionRatio, err)
+	}
+}
+
+func TestEncoderInterceptor_ULPFEC(t *testing.T) {
+	written := encode(t, &interceptor.StreamInfo{
+		SSRC:                              1,
+		PayloadType:                       96,
+		PayloadTypeRED:                    116,
+		PayloadTypeForwardErrorCorrection: 117,
+	}, []uint16{10, 11, 12, 13, 14}, 13)
+
+	// The two FEC packets of the frame take sequence numbers of the stream
+	seqNums, blockPayloadTypes := []uint16{}, []uint8{}
+	for _, p := range written {
+		assert.Equal(t, uint32(1), p.SSRC)
+		assert.Equal(t, uint8(116), p.PayloadType)
+		seqNums = append(seqNums, p.SequenceNumber)
+		blockPayloadTypes = append(blockPayloadTypes, p.Payload[0])
+	}
+	assert.Equal(t, []uint16{10, 11, 12, 13, 14, 15, 16}, seqNums)
+	assert.Equal(t, []uint8{96, 96, 96, 96, 117, 117, 96}, blockPayloadTypes)
+	assert.Equal(t, []byte{96, 14, 0xAA}, written[6].Payload)
+
+	p, err := unmarshalULPFEC(written[4].Payload[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{10, 12}, p.protects())
+	assert.Equal(t, uint32(13*3000), written[4].Timestamp)
+	p, err = unmarshalULPFEC(written[5].Payload[1:])
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{11, 13}, p.protects())
+}
+
+func TestEncoderInterceptor_FlexFEC(t *testing.T) {
+	seqNums := []uint16{}
+	for seqNum := uint16(0); seqNum < 20; seqNum++ {
+		seqNums = append(seqNums, seqNum)
+	}
+	written := encode(t, &interceptor.StreamInfo{
+		SSRC:                              1,
+		PayloadType:                       96,
+		SSRCForwardErrorCorrection:        2,
+		PayloadTypeForwardErrorCorrection: 118,
+	}, seqNums)
+
+	// Without a marker a block ends after 15 packets, the media packets are sent as they are
+	media, protected := []uint16{}, [][]uint16{}
+	for _, p := range written {
+		if p.SSRC == 1 {
+			assert.Equal(t, uint8(96), p.PayloadType)
+			media = append(media, p.SequenceNumber)
+			continue
+		}
+
+		assert.Equal(t, uint32(2), p.SSRC)
+		assert.Equal(t, uint8(118), p.PayloadType)
+		fec, ssrc, err := unmarshalFlexFEC(p.Payload)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1), ssrc)
+		protected = append(protected, fec.protects())
+	}
+	assert.Equal(t, seqNums, media)
+	assert.Equal(t, 8, len(protected))
+	assert.Equal(t, []uint16{0, 8}, protected[0])
+	assert.Equal(t, []uint16{6, 14}, protected[6])
+	assert.Equal(t, []uint16{7}, protected[7])
+}
+
+func TestEncoderInterceptor_Unprotected(t *testing.T) {
+	written := encode(t, &interceptor.StreamInfo{SSRC: 1, PayloadType: 96}, []uint16{10, 11, 12, 13}, 13)
+	assert.Equal(t, 4, len(written))
+
+	// Packets of other SSRCs aren't protected
+	written = encode(t, &interceptor.StreamInfo{
+		SSRC:                              3,
+		PayloadType:                       96,
+		PayloadTypeRED:                    116,
+		PayloadTypeForwardErrorCorrection: 117,
+	}, []uint16{10, 11, 12, 13}, 13)
+	assert.Equal(t, 4, len(written))
+	for _, p := range written {
+		assert.Equal(t, uint8(96), p.PayloadType)
+	}
+}
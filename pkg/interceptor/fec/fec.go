@@ -0,0 +1,118 @@
+// Package fec provides the Interceptors that protect video streams with
+// forward error correction, so receivers recover lost packets without
+// waiting for a retransmission. Streams are protected with ULPFEC inside
+// RED or with FlexFEC-03, depending on what was negotiated
+// https://tools.ietf.org/html/rfc5109
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03
+package fec
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+const (
+	rtpHeaderSize  = 12
+	uint16SizeHalf = 1 << 15
+
+	// maxBlockSize is the most media packets a FEC packet protects, their
+	// offsets fit the short masks of ULPFEC and FlexFEC
+	maxBlockSize = 15
+
+	defaultProtectionRatio = 0.25
+)
+
+var (
+	errInvalidProtectionRatio = errors.New("protection ratio must be greater than 0 and at most 1")
+	errPacketTooShort         = errors.New("packet too short")
+	errUnsupportedFEC         = errors.New("unsupported FEC packet")
+)
+
+// streamFEC tells how a stream is protected, packets are either sent
+// as RED with ULPFEC in the same stream or FlexFEC on a stream of its own
+func streamFEC(info *interceptor.StreamInfo) (ulpfec, flexfec bool) {
+	switch {
+	case info.PayloadTypeForwardErrorCorrection == 0:
+		return false, false
+	case info.PayloadTypeRED != 0:
+		return true, false
+	default:
+		return false, info.SSRCForwardErrorCorrection != 0
+	}
+}
+
+// bitString is the XOR of the parts of RTP packets a FEC packet recovers: the
+// first 8 bytes of the header but the sequence number, the length of the packet
+// without its fixed header, and everything that follows the fixed header
+// https://tools.ietf.org/html/rfc5109#section-7.3
+type bitString struct {
+	header [10]byte
+	body   []byte
+}
+
+// xor adds a marshaled RTP packet to the bit string
+func (s *bitString) xor(raw []byte) {
+	s.header[0] ^= raw[0]
+	s.header[1] ^= raw[1]
+	for i := 4; i < 8; i++ {
+		s.header[i] ^= raw[i]
+	}
+	length := binary.BigEndian.Uint16(s.header[8:]) ^ uint16(len(raw)-rtpHeaderSize)
+	binary.BigEndian.PutUint16(s.header[8:], length)
+
+	body := raw[rtpHeaderSize:]
+	if len(body) > len(s.body) {
+		s.body = append(s.body, make([]byte, len(body)-len(s.body))...)
+	}
+	for i := range body {
+		s.body[i] ^= body[i]
+	}
+}
+
+// recover returns the packet with the sequence number and SSRC once the bit
+// string of the FEC packet was XORed with all other packets it protects
+func (s *bitString) recover(seqNum uint16, ssrc uint32) ([]byte, error) {
+	length := int(binary.BigEndian.Uint16(s.header[8:]))
+	if length > len(s.body) {
+		return nil, errPacketTooShort
+	}
+
+	raw := make([]byte, rtpHeaderSize+length)
+	raw[0] = 0x80 | s.header[0]&0x3F
+	raw[1] = s.header[1]
+	binary.BigEndian.PutUint16(raw[2:], seqNum)
+	copy(raw[4:8], s.header[4:8])
+	binary.BigEndian.PutUint32(raw[8:], ssrc)
+	copy(raw[rtpHeaderSize:], s.body[:length])
+	return raw, nil
+}
+
+// fecPacket is a parsed ULPFEC or FlexFEC packet
+type fecPacket struct {
+	bits       bitString
+	seqNumBase uint16
+	offsets    []uint16
+}
+
+// protects returns the sequence numbers the FEC packet protects
+func (p *fecPacket) protects() []uint16 {
+	seqNums := make([]uint16, len(p.offsets))
+	for i, offset := range p.offsets {
+		seqNums[i] = p.seqNumBase + offset
+	}
+	return seqNums
+}
+
+// maskOffsets returns the offsets of the bits set in a mask, the most
+// significant bit of the mask is offset 0
+func maskOffsets(mask uint64, bits, first uint16) []uint16 {
+	offsets := []uint16{}
+	for i := uint16(0); i < bits; i++ {
+		if mask&(1<<(bits-1-i)) != 0 {
+			offsets = append(offsets, first+i)
+		}
+	}
+	return offsets
+}
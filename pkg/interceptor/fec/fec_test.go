@@ -0,0 +1,60 @@
+package fec
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func marshalPacket(t *testing.T, seqNum uint16, payload []byte) []byte {
+	raw, err := (&rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         seqNum%2 == 0,
+			PayloadType:    96,
+			SequenceNumber: seqNum,
+			Timestamp:      uint32(seqNum) * 3000,
+			SSRC:           1,
+		},
+		Payload: payload,
+	}).Marshal()
+	assert.NoError(t, err)
+	return raw
+}
+
+func TestBitString(t *testing.T) {
+	packets := [][]byte{
+		marshalPacket(t, 10, []byte{0x01, 0x02, 0x03}),
+		marshalPacket(t, 11, []byte{0x04}),
+		marshalPacket(t, 12, []byte{0x05, 0x06, 0x07, 0x08, 0x09}),
+	}
+
+	for missing := range packets {
+		bits := &bitString{}
+		for _, raw := range packets {
+			bits.xor(raw)
+		}
+		for i, raw := range packets {
+			if i != missing {
+				bits.xor(raw)
+			}
+		}
+
+		recovered, err := bits.recover(uint16(10+missing), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, packets[missing], recovered)
+	}
+
+	bits := &bitString{}
+	bits.header[9] = 4
+	_, err := bits.recover(10, 1)
+	assert.Equal(t, errPacketTooShort, err)
+}
+
+func TestMaskOffsets(t *testing.T) {
+	assert.Equal(t, []uint16{}, maskOffsets(0, 16, 0))
+	assert.Equal(t, []uint16{0, 2, 15}, maskOffsets(0xA001, 16, 0))
+	assert.Equal(t, []uint16{15, 45}, maskOffsets(0x40000001, 31, 15))
+	assert.Equal(t, []uint16{46, 108}, maskOffsets(0x4000000000000001, 63, 46))
+}
@@ -0,0 +1,76 @@
+package fec
+
+import (
+	"encoding/binary"
+)
+
+const (
+	flexfecHeaderSize = 20 // with a single SSRC and the shortest mask
+)
+
+// marshalFlexFEC returns the payload of a FlexFEC-03 packet with a flexible
+// mask, that protects the packets of the SSRC at the offsets from seqNumBase
+// https://tools.ietf.org/html/draft-ietf-payload-flexible-fec-scheme-03#section-4.2
+func marshalFlexFEC(bits *bitString, ssrc uint32, seqNumBase uint16, offsets []uint16) []byte {
+	payload := make([]byte, flexfecHeaderSize+len(bits.body))
+
+	// R and F are left unset, the mask is flexible
+	payload[0] = bits.header[0] & 0x3F
+	payload[1] = bits.header[1]
+	copy(payload[2:4], bits.header[8:10])
+	copy(payload[4:8], bits.header[4:8])
+	payload[8] = 1 // SSRCCount
+	binary.BigEndian.PutUint32(payload[12:], ssrc)
+	binary.BigEndian.PutUint16(payload[16:], seqNumBase)
+
+	// The k bit ends the mask after 15 bits
+	mask := uint16(0x8000)
+	for _, offset := range offsets {
+		mask |= 1 << (14 - offset)
+	}
+	binary.BigEndian.PutUint16(payload[18:], mask)
+	copy(payload[flexfecHeaderSize:], bits.body)
+	return payload
+}
+
+// unmarshalFlexFEC parses the payload of a FlexFEC-03 packet, it must
+// protect a single SSRC with a flexible mask
+func unmarshalFlexFEC(payload []byte) (*fecPacket, uint32, error) {
+	if len(payload) < flexfecHeaderSize {
+		return nil, 0, errPacketTooShort
+	} else if payload[0]&0xC0 != 0 || payload[8] != 1 {
+		return nil, 0, errUnsupportedFEC
+	}
+
+	p := &fecPacket{seqNumBase: binary.BigEndian.Uint16(payload[16:])}
+	p.bits.header[0] = payload[0] & 0x3F
+	p.bits.header[1] = payload[1]
+	copy(p.bits.header[4:8], payload[4:8])
+	copy(p.bits.header[8:10], payload[2:4])
+	ssrc := binary.BigEndian.Uint32(payload[12:])
+
+	// Every part of the mask starts with a k bit that tells if it is the last one
+	offset := 18
+	mask := binary.BigEndian.Uint16(payload[offset:])
+	p.offsets = maskOffsets(uint64(mask&0x7FFF), 15, 0)
+	offset += 2
+	if mask&0x8000 == 0 {
+		if len(payload) < offset+4 {
+			return nil, 0, errPacketTooShort
+		}
+		mask := binary.BigEndian.Uint32(payload[offset:])
+		p.offsets = append(p.offsets, maskOffsets(uint64(mask&0x7FFFFFFF), 31, 15)...)
+		offset += 4
+
+		if mask&0x80000000 == 0 {
+			if len(payload) < offset+8 {
+				return nil, 0, errPacketTooShort
+			}
+			p.offsets = append(p.offsets, maskOffsets(binary.BigEndian.Uint64(payload[offset:])&0x7FFFFFFFFFFFFFFF, 63, 46)...)
+			offset += 8
+		}
+	}
+
+	p.bits.body = append([]byte{}, payload[offset:]...)
+	return p, ssrc, nil
+}
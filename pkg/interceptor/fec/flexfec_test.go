@@ -0,0 +1,52 @@
+package fec
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlexFEC(t *testing.T) {
+	bits := &bitString{}
+	bits.xor(marshalPacket(t, 10, []byte{0x01, 0x02}))
+	bits.xor(marshalPacket(t, 24, []byte{0x03}))
+
+	p, ssrc, err := unmarshalFlexFEC(marshalFlexFEC(bits, 1, 10, []uint16{0, 14}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), ssrc)
+	assert.Equal(t, *bits, p.bits)
+	assert.Equal(t, []uint16{10, 24}, p.protects())
+
+	_, _, err = unmarshalFlexFEC([]byte{0x00})
+	assert.Equal(t, errPacketTooShort, err)
+
+	// Fixed masks aren't supported
+	payload := marshalFlexFEC(bits, 1, 10, []uint16{0})
+	payload[0] |= 0x40
+	_, _, err = unmarshalFlexFEC(payload)
+	assert.Equal(t, errUnsupportedFEC, err)
+}
+
+func TestFlexFEC_LongMask(t *testing.T) {
+	payload := marshalFlexFEC(&bitString{body: []byte{0xAA}}, 1, 100, []uint16{1})
+
+	// k is unset in the first two parts of the mask, so it is 15+31+63 bits long
+	long := append([]byte{}, payload[:18]...)
+	long = append(long, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01)
+	mask := make([]byte, 8)
+	binary.BigEndian.PutUint64(mask, 0xC000000000000001)
+	long = append(long, mask...)
+	binary.BigEndian.PutUint16(long[18:], 0x2000)
+	long = append(long, payload[20:]...)
+
+	p, _, err := unmarshalFlexFEC(long)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{101, 145, 146, 208}, p.protects())
+	assert.Equal(t, []byte{0xAA}, p.bits.body)
+
+	_, _, err = unmarshalFlexFEC(long[:24])
+	assert.Equal(t, errPacketTooShort, err)
+	_, _, err = unmarshalFlexFEC(long[:21])
+	assert.Equal(t, errPacketTooShort, err)
+}
@@ -0,0 +1,104 @@
+package fec
+
+import (
+	"encoding/binary"
+)
+
+const (
+	ulpfecHeaderSize      = 10
+	ulpfecLevelHeaderSize = 4 // with a short mask
+)
+
+// marshalULPFEC returns the payload of a ULPFEC packet with one protection
+// level, that protects the packets at the offsets from seqNumBase
+// https://tools.ietf.org/html/rfc5109#section-7.3
+func marshalULPFEC(bits *bitString, seqNumBase uint16, offsets []uint16) []byte {
+	payload := make([]byte, ulpfecHeaderSize+ulpfecLevelHeaderSize+len(bits.body))
+
+	// E and L are left unset, the mask is 16 bits long
+	payload[0] = bits.header[0] & 0x3F
+	payload[1] = bits.header[1]
+	binary.BigEndian.PutUint16(payload[2:], seqNumBase)
+	copy(payload[4:8], bits.header[4:8])
+	copy(payload[8:10], bits.header[8:10])
+
+	mask := uint16(0)
+	for _, offset := range offsets {
+		mask |= 1 << (15 - offset)
+	}
+	binary.BigEndian.PutUint16(payload[10:], uint16(len(bits.body)))
+	binary.BigEndian.PutUint16(payload[12:], mask)
+	copy(payload[ulpfecHeaderSize+ulpfecLevelHeaderSize:], bits.body)
+	return payload
+}
+
+// unmarshalULPFEC parses the payload of a ULPFEC packet, only the first
+// protection level is used
+func unmarshalULPFEC(payload []byte) (*fecPacket, error) {
+	if len(payload) < ulpfecHeaderSize+ulpfecLevelHeaderSize {
+		return nil, errPacketTooShort
+	} else if payload[0]&0x80 != 0 {
+		return nil, errUnsupportedFEC
+	}
+
+	p := &fecPacket{seqNumBase: binary.BigEndian.Uint16(payload[2:])}
+	p.bits.header[0] = payload[0] & 0x3F
+	p.bits.header[1] = payload[1]
+	copy(p.bits.header[4:8], payload[4:8])
+	copy(p.bits.header[8:10], payload[8:10])
+
+	protectionLength := int(binary.BigEndian.Uint16(payload[10:]))
+	offset := ulpfecHeaderSize + ulpfecLevelHeaderSize
+	if payload[0]&0x40 != 0 {
+		// L is set, the mask is 48 bits long
+		offset += 4
+		if len(payload) < offset {
+			return nil, errPacketTooShort
+		}
+		p.offsets = maskOffsets(uint64(binary.BigEndian.Uint16(payload[12:]))<<32|uint64(binary.BigEndian.Uint32(payload[14:])), 48, 0)
+	} else {
+		p.offsets = maskOffsets(uint64(binary.BigEndian.Uint16(payload[12:])), 16, 0)
+	}
+
+	if len(payload) < offset+protectionLength {
+		return nil, errPacketTooShort
+	}
+	p.bits.body = append([]byte{}, payload[offset:offset+protectionLength]...)
+	return p, nil
+}
+
+// wrapRED returns the payload of a RED packet with a single block
+// https://tools.ietf.org/html/rfc2198#section-3
+func wrapRED(payloadType uint8, payload []byte) []byte {
+	red := make([]byte, 1+len(payload))
+	red[0] = payloadType & 0x7F
+	copy(red[1:], payload)
+	return red
+}
+
+// unwrapRED returns the payload type and payload of the primary block of
+// a RED packet, the redundant blocks before it are skipped
+func unwrapRED(payload []byte) (uint8, []byte, error) {
+	offset, skipped := 0, 0
+	for {
+		if len(payload) <= offset {
+			return 0, nil, errPacketTooShort
+		}
+
+		// The last block header is a single byte without the F bit
+		if payload[offset]&0x80 == 0 {
+			payloadType := payload[offset] & 0x7F
+			offset++
+			if len(payload) < offset+skipped {
+				return 0, nil, errPacketTooShort
+			}
+			return payloadType, payload[offset+skipped:], nil
+		}
+
+		if len(payload) < offset+4 {
+			return 0, nil, errPacketTooShort
+		}
+		skipped += int(binary.BigEndian.Uint16(payload[offset+2:]) & 0x03FF)
+		offset += 4
+	}
+}
@@ -0,0 +1,68 @@
+package fec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestULPFEC(t *testing.T) {
+	bits := &bitString{}
+	bits.xor(marshalPacket(t, 10, []byte{0x01, 0x02}))
+	bits.xor(marshalPacket(t, 12, []byte{0x03}))
+
+	p, err := unmarshalULPFEC(marshalULPFEC(bits, 10, []uint16{0, 2}))
+	assert.NoError(t, err)
+	assert.Equal(t, *bits, p.bits)
+	assert.Equal(t, []uint16{10, 12}, p.protects())
+
+	_, err = unmarshalULPFEC([]byte{0x00, 0x00})
+	assert.Equal(t, errPacketTooShort, err)
+
+	// E is set, the extension isn't supported
+	payload := marshalULPFEC(bits, 10, []uint16{0})
+	payload[0] |= 0x80
+	_, err = unmarshalULPFEC(payload)
+	assert.Equal(t, errUnsupportedFEC, err)
+
+	// The protection length is longer than the packet
+	payload = marshalULPFEC(bits, 10, []uint16{0})
+	_, err = unmarshalULPFEC(payload[:len(payload)-1])
+	assert.Equal(t, errPacketTooShort, err)
+}
+
+func TestULPFEC_LongMask(t *testing.T) {
+	bits := &bitString{body: []byte{0xAA}}
+	payload := marshalULPFEC(bits, 100, []uint16{1})
+
+	// L is set, the mask is extended by 32 bits
+	long := append([]byte{}, payload[:14]...)
+	long[0] |= 0x40
+	long = append(long, 0x00, 0x00, 0x00, 0x01)
+	long = append(long, payload[14:]...)
+
+	p, err := unmarshalULPFEC(long)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{101, 147}, p.protects())
+	assert.Equal(t, []byte{0xAA}, p.bits.body)
+}
+
+func TestRED(t *testing.T) {
+	payloadType, payload, err := unwrapRED(wrapRED(96, []byte{0x01, 0x02}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(96), payloadType)
+	assert.Equal(t, []byte{0x01, 0x02}, payload)
+
+	// A redundant block of 2 bytes is skipped
+	payloadType, payload, err = unwrapRED([]byte{0x80 | 97, 0x00, 0x00, 0x02, 96, 0xAA, 0xBB, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(96), payloadType)
+	assert.Equal(t, []byte{0x01}, payload)
+
+	_, _, err = unwrapRED([]byte{})
+	assert.Equal(t, errPacketTooShort, err)
+	_, _, err = unwrapRED([]byte{0x80 | 97, 0x00})
+	assert.Equal(t, errPacketTooShort, err)
+	_, _, err = unwrapRED([]byte{0x80 | 97, 0x00, 0x00, 0x04, 96, 0xAA})
+	assert.Equal(t, errPacketTooShort, err)
+}
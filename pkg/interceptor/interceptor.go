@@ -0,0 +1,95 @@
+// Package interceptor lets RTP and RTCP packets be observed, modified, injected
+// or dropped on their way between a PeerConnection and its SRTP sessions
+package interceptor
+
+import (
+	"io"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// Interceptor is bound to the streams of a PeerConnection. Every Bind method
+// gets the reader or writer of the next step towards the network and returns
+// the one the PeerConnection uses instead
+type Interceptor interface {
+	// BindRTCPReader is called once for every stream incoming RTCP is read from,
+	// the returned reader is used for every RTCP packet of the stream
+	BindRTCPReader(reader RTCPReader) RTCPReader
+
+	// BindRTCPWriter is called once per PeerConnection, the returned writer is
+	// used for all outgoing RTCP. Interceptors keep it to send RTCP of their own
+	BindRTCPWriter(writer RTCPWriter) RTCPWriter
+
+	// BindLocalStream is called when a stream starts to be sent, the returned
+	// writer is used for every RTP packet of the stream
+	BindLocalStream(info *StreamInfo, writer RTPWriter) RTPWriter
+
+	// UnbindLocalStream is called when a stream isn't sent anymore
+	UnbindLocalStream(info *StreamInfo)
+
+	// BindRemoteStream is called when a stream starts to be received, the
+	// returned reader is used for every RTP packet of the stream
+	BindRemoteStream(info *StreamInfo, reader RTPReader) RTPReader
+
+	// UnbindRemoteStream is called when a stream isn't received anymore
+	UnbindRemoteStream(info *StreamInfo)
+
+	io.Closer
+}
+
+// Attributes are passed along with a packet, so the steps of a chain can
+// share what they learned about it
+type Attributes map[interface{}]interface{}
+
+// RTPWriter writes RTP packets of a local stream
+type RTPWriter interface {
+	Write(header *rtp.Header, payload []byte, attributes Attributes) (int, error)
+}
+
+// RTPReader reads RTP packets of a remote stream
+type RTPReader interface {
+	Read(b []byte, attributes Attributes) (int, Attributes, error)
+}
+
+// RTCPWriter writes outgoing RTCP packets
+type RTCPWriter interface {
+	Write(pkts []rtcp.Packet, attributes Attributes) (int, error)
+}
+
+// RTCPReader reads incoming RTCP packets
+type RTCPReader interface {
+	Read(b []byte, attributes Attributes) (int, Attributes, error)
+}
+
+// RTPWriterFunc is an adapter for RTPWriter interface
+type RTPWriterFunc func(header *rtp.Header, payload []byte, attributes Attributes) (int, error)
+
+// RTPReaderFunc is an adapter for RTPReader interface
+type RTPReaderFunc func(b []byte, attributes Attributes) (int, Attributes, error)
+
+// RTCPWriterFunc is an adapter for RTCPWriter interface
+type RTCPWriterFunc func(pkts []rtcp.Packet, attributes Attributes) (int, error)
+
+// RTCPReaderFunc is an adapter for RTCPReader interface
+type RTCPReaderFunc func(b []byte, attributes Attributes) (int, Attributes, error)
+
+// Write a rtp packet
+func (f RTPWriterFunc) Write(header *rtp.Header, payload []byte, attributes Attributes) (int, error) {
+	return f(header, payload, attributes)
+}
+
+// Read a rtp packet
+func (f RTPReaderFunc) Read(b []byte, attributes Attributes) (int, Attributes, error) {
+	return f(b, attributes)
+}
+
+// Write a batch of rtcp packets
+func (f RTCPWriterFunc) Write(pkts []rtcp.Packet, attributes Attributes) (int, error) {
+	return f(pkts, attributes)
+}
+
+// Read a batch of rtcp packets
+func (f RTCPReaderFunc) Read(b []byte, attributes Attributes) (int, Attributes, error) {
+	return f(b, attributes)
+}
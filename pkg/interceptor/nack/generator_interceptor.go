@@ -0,0 +1,204 @@
+package nack
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pion/logging"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+// GeneratorInterceptor logs the sequence numbers of remote streams that
+// negotiated NACK and periodically sends NACKs for the missing ones
+type GeneratorInterceptor struct {
+	interceptor.NoOp
+	size      uint16
+	skipLastN uint16
+	interval  time.Duration
+	log       logging.LeveledLogger
+
+	senderSSRC  uint32
+	receiveLogs map[uint32]*receiveLog
+	started     bool
+	mu          sync.Mutex
+
+	wg    sync.WaitGroup
+	close chan struct{}
+}
+
+// GeneratorOption can be used to configure a GeneratorInterceptor
+type GeneratorOption func(g *GeneratorInterceptor) error
+
+// GeneratorSize sets how many sequence numbers of a stream are logged,
+// it must be a power of two of at least 64
+func GeneratorSize(size uint16) GeneratorOption {
+	return func(g *GeneratorInterceptor) error {
+		g.size = size
+		return nil
+	}
+}
+
+// GeneratorSkipLastN sets how many of the newest sequence numbers are
+// not NACKed yet, as they may still arrive out of order
+func GeneratorSkipLastN(skipLastN uint16) GeneratorOption {
+	return func(g *GeneratorInterceptor) error {
+		g.skipLastN = skipLastN
+		return nil
+	}
+}
+
+// GeneratorInterval sets how often NACKs are sent
+func GeneratorInterval(interval time.Duration) GeneratorOption {
+	return func(g *GeneratorInterceptor) error {
+		g.interval = interval
+		return nil
+	}
+}
+
+// GeneratorLog sets the logger of the GeneratorInterceptor
+func GeneratorLog(log logging.LeveledLogger) GeneratorOption {
+	return func(g *GeneratorInterceptor) error {
+		g.log = log
+		return nil
+	}
+}
+
+// NewGeneratorInterceptor returns a new GeneratorInterceptor
+func NewGeneratorInterceptor(opts ...GeneratorOption) (*GeneratorInterceptor, error) {
+	g := &GeneratorInterceptor{
+		size:        defaultSize,
+		interval:    100 * time.Millisecond,
+		log:         logging.NewDefaultLoggerFactory().NewLogger("nack_generator"),
+		senderSSRC:  rand.Uint32(), // nolint:gosec
+		receiveLogs: map[uint32]*receiveLog{},
+		close:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		if err := opt(g); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := newReceiveLog(g.size); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// BindRTCPWriter starts sending NACKs with the writer
+func (g *GeneratorInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.started {
+		g.started = true
+		g.wg.Add(1)
+		go g.loop(writer)
+	}
+	return writer
+}
+
+// BindRemoteStream logs the sequence numbers read from streams that negotiated NACK
+func (g *GeneratorInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	if !streamSupportNack(info) {
+		return reader
+	}
+
+	// size was validated in NewGeneratorInterceptor
+	receiveLog, _ := newReceiveLog(g.size)
+	g.mu.Lock()
+	g.receiveLogs[info.SSRC] = receiveLog
+	g.mu.Unlock()
+
+	return interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		if err != nil {
+			return n, attributes, err
+		}
+
+		// Packets of other streams, like FlexFEC, are read along with the stream
+		header := rtp.Header{}
+		if err := header.Unmarshal(b[:n]); err == nil && header.SSRC == info.SSRC {
+			receiveLog.add(header.SequenceNumber)
+		}
+		return n, attributes, nil
+	})
+}
+
+// UnbindRemoteStream stops sending NACKs for the stream
+func (g *GeneratorInterceptor) UnbindRemoteStream(info *interceptor.StreamInfo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.receiveLogs, info.SSRC)
+}
+
+// Close stops sending NACKs
+func (g *GeneratorInterceptor) Close() error {
+	g.mu.Lock()
+	select {
+	case <-g.close:
+	default:
+		close(g.close)
+	}
+	g.mu.Unlock()
+
+	g.wg.Wait()
+	return nil
+}
+
+func (g *GeneratorInterceptor) loop(writer interceptor.RTCPWriter) {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.mu.Lock()
+			pkts := []rtcp.Packet{}
+			for ssrc, receiveLog := range g.receiveLogs {
+				missing := receiveLog.missingSeqNumbers(g.skipLastN)
+				if len(missing) == 0 {
+					continue
+				}
+
+				pkts = append(pkts, &rtcp.TransportLayerNack{
+					SenderSSRC: g.senderSSRC,
+					MediaSSRC:  ssrc,
+					Nacks:      nackPairsFromSequenceNumbers(missing),
+				})
+			}
+			g.mu.Unlock()
+
+			if len(pkts) == 0 {
+				continue
+			}
+			if _, err := writer.Write(pkts, interceptor.Attributes{}); err != nil {
+				g.log.Warnf("failed sending nack: %v", err)
+			}
+		case <-g.close:
+			return
+		}
+	}
+}
+
+// nackPairsFromSequenceNumbers packs sorted sequence numbers into NackPairs,
+// each one covers its PacketID and the 16 sequence numbers after it
+func nackPairsFromSequenceNumbers(seqNums []uint16) []rtcp.NackPair {
+	pairs := []rtcp.NackPair{}
+	for _, seq := range seqNums {
+		if n := len(pairs); n != 0 {
+			if diff := seq - pairs[n-1].PacketID; diff != 0 && diff <= 16 {
+				pairs[n-1].LostPackets |= rtcp.PacketBitmap(1 << (diff - 1))
+				continue
+			}
+		}
+		pairs = append(pairs, rtcp.NackPair{PacketID: seq})
+	}
+	return pairs
+}
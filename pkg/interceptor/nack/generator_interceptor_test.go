@@ -0,0 +1,90 @@
+package nack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorInterceptor(t *testing.T) {
+	g, err := NewGeneratorInterceptor(
+		GeneratorSize(64),
+		GeneratorSkipLastN(2),
+		GeneratorInterval(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	written := make(chan []rtcp.Packet, 10)
+	g.BindRTCPWriter(interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+		written <- pkts
+		return 0, nil
+	}))
+
+	incoming := make(chan []byte, 10)
+	reader := g.BindRemoteStream(&interceptor.StreamInfo{
+		SSRC:         1,
+		RTCPFeedback: []interceptor.RTCPFeedback{{Type: "nack"}},
+	}, interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return copy(b, <-incoming), attributes, nil
+	}))
+
+	// Packets of other SSRCs, like FlexFEC, don't count
+	raw, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: 100, SSRC: 2}}).Marshal()
+	assert.NoError(t, err)
+	incoming <- raw
+	_, _, err = reader.Read(make([]byte, 1500), interceptor.Attributes{})
+	assert.NoError(t, err)
+
+	for _, seqNum := range []uint16{10, 11, 12, 14, 16, 18} {
+		raw, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: seqNum, SSRC: 1}}).Marshal()
+		assert.NoError(t, err)
+
+		incoming <- raw
+		_, _, err = reader.Read(make([]byte, 1500), interceptor.Attributes{})
+		assert.NoError(t, err)
+	}
+
+	select {
+	case pkts := <-written:
+		assert.Equal(t, 1, len(pkts))
+		nack, ok := pkts[0].(*rtcp.TransportLayerNack)
+		if assert.True(t, ok, "not a nack") {
+			assert.Equal(t, uint32(1), nack.MediaSSRC)
+			assert.Equal(t, []rtcp.NackPair{{PacketID: 13, LostPackets: 0x2}}, nack.Nacks)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no nack sent")
+	}
+
+	assert.NoError(t, g.Close())
+}
+
+func TestGeneratorInterceptor_NoNack(t *testing.T) {
+	g, err := NewGeneratorInterceptor()
+	assert.NoError(t, err)
+
+	reader := interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return 0, attributes, nil
+	})
+	g.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1}, reader)
+	assert.Equal(t, 0, len(g.receiveLogs))
+
+	assert.NoError(t, g.Close())
+}
+
+func TestGeneratorInterceptor_InvalidSize(t *testing.T) {
+	_, err := NewGeneratorInterceptor(GeneratorSize(5))
+	assert.Equal(t, errInvalidSize, err)
+}
+
+func TestNackPairsFromSequenceNumbers(t *testing.T) {
+	assert.Equal(t, []rtcp.NackPair{}, nackPairsFromSequenceNumbers([]uint16{}))
+	assert.Equal(t, []rtcp.NackPair{
+		{PacketID: 65534, LostPackets: 0x8003},
+		{PacketID: 15},
+	}, nackPairsFromSequenceNumbers([]uint16{65534, 65535, 0, 14, 15}))
+}
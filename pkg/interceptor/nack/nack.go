@@ -0,0 +1,36 @@
+// Package nack provides the Interceptors that repair packet loss with generic
+// NACK feedback, the receiver asks for lost packets and the sender sends them again
+// https://tools.ietf.org/html/rfc4585#section-6.2.1
+package nack
+
+import (
+	"errors"
+
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+const (
+	// uint16SizeHalf is used to tell if a sequence number is ahead of another
+	// one or behind it, taking wrap around into account
+	uint16SizeHalf = 1 << 15
+
+	defaultSize = 1024
+)
+
+var errInvalidSize = errors.New("invalid buffer size")
+
+// streamSupportNack returns true if generic NACK was negotiated for the stream
+func streamSupportNack(info *interceptor.StreamInfo) bool {
+	for _, fb := range info.RTCPFeedback {
+		if fb.Type == "nack" && fb.Parameter == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validSize returns true if size is a power of two that is small enough
+// to tell ahead from behind sequence numbers within the buffer
+func validSize(size uint16) bool {
+	return size != 0 && size <= uint16SizeHalf && size&(size-1) == 0
+}
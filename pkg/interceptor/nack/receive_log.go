@@ -0,0 +1,113 @@
+package nack
+
+import (
+	"sync"
+)
+
+// receiveLog records which of the last size sequence numbers of a remote
+// stream were received, the others are reported as missing
+type receiveLog struct {
+	received []uint64
+	size     uint16
+	end      uint16
+	started  bool
+
+	// lastConsecutive is the newest sequence number every one before was
+	// received or given up on
+	lastConsecutive uint16
+
+	mu sync.RWMutex
+}
+
+func newReceiveLog(size uint16) (*receiveLog, error) {
+	if !validSize(size) || size < 64 {
+		return nil, errInvalidSize
+	}
+
+	return &receiveLog{
+		received: make([]uint64, size/64),
+		size:     size,
+	}, nil
+}
+
+func (r *receiveLog) add(seq uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.started {
+		r.setReceived(seq)
+		r.end = seq
+		r.lastConsecutive = seq
+		r.started = true
+		return
+	}
+
+	diff := seq - r.end
+	switch {
+	case diff == 0:
+		return
+	case diff < uint16SizeHalf:
+		// Forget what was logged in the slots of the skipped sequence numbers
+		if diff >= r.size {
+			for i := range r.received {
+				r.received[i] = 0
+			}
+		} else {
+			for i := r.end + 1; i != seq; i++ {
+				r.delReceived(i)
+			}
+		}
+		r.end = seq
+		if r.end-r.lastConsecutive > r.size {
+			r.lastConsecutive = r.end - r.size
+		}
+	case r.end-seq >= r.size:
+		// Too old to be in the log
+		return
+	}
+
+	r.setReceived(seq)
+	r.fixLastConsecutive()
+}
+
+// missingSeqNumbers returns the sequence numbers that weren't received,
+// skipping the newest skipLastN as they may just be late
+func (r *receiveLog) missingSeqNumbers(skipLastN uint16) []uint16 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	until := r.end - skipLastN
+	if !r.started || until-r.lastConsecutive >= uint16SizeHalf {
+		return nil
+	}
+
+	var missing []uint16
+	for i := r.lastConsecutive + 1; i != until+1; i++ {
+		if !r.getReceived(i) {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+func (r *receiveLog) fixLastConsecutive() {
+	i := r.lastConsecutive + 1
+	for ; i != r.end+1 && r.getReceived(i); i++ {
+	}
+	r.lastConsecutive = i - 1
+}
+
+func (r *receiveLog) setReceived(seq uint16) {
+	pos := seq % r.size
+	r.received[pos/64] |= 1 << (pos % 64)
+}
+
+func (r *receiveLog) delReceived(seq uint16) {
+	pos := seq % r.size
+	r.received[pos/64] &^= 1 << (pos % 64)
+}
+
+func (r *receiveLog) getReceived(seq uint16) bool {
+	pos := seq % r.size
+	return r.received[pos/64]&(1<<(pos%64)) != 0
+}
@@ -0,0 +1,71 @@
+package nack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveLog(t *testing.T) {
+	for _, start := range []uint16{0, 1, 127, 128, 129, 511, 512, 513, 32767, 32768, 32769, 65407, 65408, 65409, 65534, 65535} {
+		start := start
+
+		rl, err := newReceiveLog(128)
+		assert.NoError(t, err)
+
+		add := func(nums ...uint16) {
+			for _, n := range nums {
+				rl.add(start + n)
+			}
+		}
+
+		assertMissing := func(skipLastN uint16, nums ...uint16) {
+			t.Helper()
+			var missing []uint16
+			for _, n := range nums {
+				missing = append(missing, start+n)
+			}
+			assert.Equal(t, missing, rl.missingSeqNumbers(skipLastN), "start %d", start)
+		}
+
+		add(0, 1, 2, 3)
+		assertMissing(0)
+
+		add(5, 8)
+		assertMissing(0, 4, 6, 7)
+		assertMissing(2, 4, 6)
+		assertMissing(10)
+
+		add(4, 7)
+		assertMissing(0, 6)
+
+		add(6)
+		assertMissing(0)
+
+		// Sequence numbers that left the log are given up on
+		add(200)
+		assertMissing(0, seqRange(73, 199)...)
+
+		add(100)
+		assertMissing(0, append(seqRange(73, 99), seqRange(101, 199)...)...)
+
+		// Too old to be logged
+		add(50)
+		assertMissing(0, append(seqRange(73, 99), seqRange(101, 199)...)...)
+	}
+}
+
+func TestReceiveLog_InvalidSize(t *testing.T) {
+	for _, size := range []uint16{0, 32, 100, 1<<15 + 1} {
+		_, err := newReceiveLog(size)
+		assert.Equal(t, errInvalidSize, err)
+	}
+}
+
+func seqRange(from, to uint16) []uint16 {
+	nums := []uint16{}
+	for i := from; i <= to; i++ {
+		nums = append(nums, i)
+	}
+	return nums
+}
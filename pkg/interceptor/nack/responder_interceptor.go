@@ -0,0 +1,187 @@
+package nack
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"sync"
+
+	"github.com/pion/logging"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+// localStream is a local stream that negotiated NACK
+type localStream struct {
+	sendBuffer  *sendBuffer
+	rtpWriter   interceptor.RTPWriter
+	payloadType uint8
+
+	// Packets are resent on a RTX stream if one was negotiated, it
+	// has sequence numbers of its own
+	rtxSSRC           uint32
+	rtxPayloadType    uint8
+	rtxSequenceNumber uint16
+	mu                sync.Mutex
+}
+
+// ResponderInterceptor keeps the packets of local streams that negotiated
+// NACK and sends them again when they are NACKed. NACKs are only answered
+// while RTCP is read from the stream
+type ResponderInterceptor struct {
+	interceptor.NoOp
+	size uint16
+	log  logging.LeveledLogger
+
+	streams map[uint32]*localStream
+	mu      sync.Mutex
+}
+
+// ResponderOption can be used to configure a ResponderInterceptor
+type ResponderOption func(r *ResponderInterceptor) error
+
+// ResponderSize sets how many packets of a stream are kept, it must be a power of two
+func ResponderSize(size uint16) ResponderOption {
+	return func(r *ResponderInterceptor) error {
+		r.size = size
+		return nil
+	}
+}
+
+// ResponderLog sets the logger of the ResponderInterceptor
+func ResponderLog(log logging.LeveledLogger) ResponderOption {
+	return func(r *ResponderInterceptor) error {
+		r.log = log
+		return nil
+	}
+}
+
+// NewResponderInterceptor returns a new ResponderInterceptor
+func NewResponderInterceptor(opts ...ResponderOption) (*ResponderInterceptor, error) {
+	r := &ResponderInterceptor{
+		size:    defaultSize,
+		log:     logging.NewDefaultLoggerFactory().NewLogger("nack_responder"),
+		streams: map[uint32]*localStream{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := newSendBuffer(r.size); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// BindRTCPReader answers the NACKs that are read
+func (r *ResponderInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	return interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attributes, err := reader.Read(b, attributes)
+		if err != nil {
+			return n, attributes, err
+		}
+
+		// Broken RTCP is left to the application to handle
+		pkts, err := rtcp.Unmarshal(b[:n])
+		if err != nil {
+			return n, attributes, nil
+		}
+		for _, pkt := range pkts {
+			if nack, ok := pkt.(*rtcp.TransportLayerNack); ok {
+				r.resendPackets(nack)
+			}
+		}
+		return n, attributes, nil
+	})
+}
+
+// BindLocalStream keeps the packets written to streams that negotiated NACK
+func (r *ResponderInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	if !streamSupportNack(info) {
+		return writer
+	}
+
+	// size was validated in NewResponderInterceptor
+	sendBuffer, _ := newSendBuffer(r.size)
+	r.mu.Lock()
+	r.streams[info.SSRC] = &localStream{
+		sendBuffer:        sendBuffer,
+		rtpWriter:         writer,
+		payloadType:       info.PayloadType,
+		rtxSSRC:           info.SSRCRetransmission,
+		rtxPayloadType:    info.PayloadTypeRetransmission,
+		rtxSequenceNumber: uint16(rand.Uint32()), // nolint:gosec
+	}
+	r.mu.Unlock()
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		// Packets of other streams, like FlexFEC, are written along with the stream
+		if header.SSRC != info.SSRC {
+			return writer.Write(header, payload, attributes)
+		}
+
+		// The caller may reuse payload once Write returned
+		sendBuffer.add(&rtp.Packet{Header: *header, Payload: append([]byte{}, payload...)})
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+// UnbindLocalStream forgets the packets of the stream
+func (r *ResponderInterceptor) UnbindLocalStream(info *interceptor.StreamInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.streams, info.SSRC)
+}
+
+func (r *ResponderInterceptor) resendPackets(nack *rtcp.TransportLayerNack) {
+	r.mu.Lock()
+	stream, ok := r.streams[nack.MediaSSRC]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	for i := range nack.Nacks {
+		for _, seq := range nack.Nacks[i].PacketList() {
+			p := stream.sendBuffer.get(seq)
+			if p == nil {
+				continue
+			}
+
+			if err := stream.resend(p); err != nil {
+				r.log.Warnf("failed resending nacked packet: %v", err)
+			}
+		}
+	}
+}
+
+// resend sends a packet again, wrapped in a RTX packet if the stream negotiated RTX.
+// RTX only retransmits the payload type of the stream, so packets of another
+// one, like RED, are resent as they are
+// https://tools.ietf.org/html/rfc4588#section-4
+func (s *localStream) resend(p *rtp.Packet) error {
+	if s.rtxSSRC == 0 || p.PayloadType != s.payloadType {
+		_, err := s.rtpWriter.Write(&p.Header, p.Payload, interceptor.Attributes{})
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header := p.Header
+	header.SSRC = s.rtxSSRC
+	header.PayloadType = s.rtxPayloadType
+	header.SequenceNumber = s.rtxSequenceNumber
+	s.rtxSequenceNumber++
+
+	payload := make([]byte, 2+len(p.Payload))
+	binary.BigEndian.PutUint16(payload, p.SequenceNumber)
+	copy(payload[2:], p.Payload)
+
+	_, err := s.rtpWriter.Write(&header, payload, interceptor.Attributes{})
+	return err
+}
@@ -0,0 +1,117 @@
+package nack
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponderInterceptor(t *testing.T) {
+	r, err := NewResponderInterceptor(ResponderSize(8))
+	assert.NoError(t, err)
+
+	written := []uint16{}
+	writer := r.BindLocalStream(&interceptor.StreamInfo{
+		SSRC:         1,
+		RTCPFeedback: []interceptor.RTCPFeedback{{Type: "nack"}},
+	}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		written = append(written, header.SequenceNumber)
+		return len(payload), nil
+	}))
+
+	for _, seqNum := range []uint16{10, 11, 12, 14, 15} {
+		_, err = writer.Write(&rtp.Header{SequenceNumber: seqNum, SSRC: 1}, []byte{0x00}, interceptor.Attributes{})
+		assert.NoError(t, err)
+	}
+	written = []uint16{}
+
+	incoming, err := rtcp.Marshal([]rtcp.Packet{&rtcp.TransportLayerNack{
+		MediaSSRC: 1,
+		Nacks: []rtcp.NackPair{
+			{PacketID: 11, LostPackets: 0xb}, // sequence numbers 11, 12, 13 and 15
+		},
+	}})
+	assert.NoError(t, err)
+
+	reader := r.BindRTCPReader(interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return copy(b, incoming), attributes, nil
+	}))
+	_, _, err = reader.Read(make([]byte, 1500), interceptor.Attributes{})
+	assert.NoError(t, err)
+
+	// 13 was never sent
+	assert.Equal(t, []uint16{11, 12, 15}, written)
+
+	r.UnbindLocalStream(&interceptor.StreamInfo{SSRC: 1})
+	written = []uint16{}
+	_, _, err = reader.Read(make([]byte, 1500), interceptor.Attributes{})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{}, written)
+
+	assert.NoError(t, r.Close())
+}
+
+func TestResponderInterceptor_RTX(t *testing.T) {
+	r, err := NewResponderInterceptor(ResponderSize(8))
+	assert.NoError(t, err)
+
+	written := []*rtp.Packet{}
+	writer := r.BindLocalStream(&interceptor.StreamInfo{
+		SSRC:                      1,
+		PayloadType:               96,
+		RTCPFeedback:              []interceptor.RTCPFeedback{{Type: "nack"}},
+		SSRCRetransmission:        2,
+		PayloadTypeRetransmission: 97,
+	}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		written = append(written, &rtp.Packet{Header: *header, Payload: payload})
+		return len(payload), nil
+	}))
+
+	for _, seqNum := range []uint16{10, 11} {
+		_, err = writer.Write(&rtp.Header{SequenceNumber: seqNum, SSRC: 1, PayloadType: 96, Timestamp: 3000}, []byte{0xAA, 0xBB}, interceptor.Attributes{})
+		assert.NoError(t, err)
+	}
+	// RED packets can't be retransmitted with RTX
+	_, err = writer.Write(&rtp.Header{SequenceNumber: 12, SSRC: 1, PayloadType: 116, Timestamp: 3000}, []byte{96, 0xAA, 0xBB}, interceptor.Attributes{})
+	assert.NoError(t, err)
+	written = []*rtp.Packet{}
+
+	incoming, err := rtcp.Marshal([]rtcp.Packet{&rtcp.TransportLayerNack{
+		MediaSSRC: 1,
+		Nacks:     []rtcp.NackPair{{PacketID: 10, LostPackets: 0x3}},
+	}})
+	assert.NoError(t, err)
+
+	reader := r.BindRTCPReader(interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return copy(b, incoming), attributes, nil
+	}))
+	_, _, err = reader.Read(make([]byte, 1500), interceptor.Attributes{})
+	assert.NoError(t, err)
+
+	// RTX packets have consecutive sequence numbers of their own and
+	// carry the original one in front of the payload
+	if assert.Len(t, written, 3) {
+		for i, originalSeqNum := range []uint16{10, 11} {
+			assert.Equal(t, uint32(2), written[i].SSRC)
+			assert.Equal(t, uint8(97), written[i].PayloadType)
+			assert.Equal(t, uint32(3000), written[i].Timestamp)
+			assert.Equal(t, []byte{byte(originalSeqNum >> 8), byte(originalSeqNum), 0xAA, 0xBB}, written[i].Payload)
+		}
+		assert.Equal(t, written[0].SequenceNumber+1, written[1].SequenceNumber)
+
+		assert.Equal(t, uint32(1), written[2].SSRC)
+		assert.Equal(t, uint8(116), written[2].PayloadType)
+		assert.Equal(t, uint16(12), written[2].SequenceNumber)
+		assert.Equal(t, []byte{96, 0xAA, 0xBB}, written[2].Payload)
+	}
+
+	assert.NoError(t, r.Close())
+}
+
+func TestResponderInterceptor_InvalidSize(t *testing.T) {
+	_, err := NewResponderInterceptor(ResponderSize(5))
+	assert.Equal(t, errInvalidSize, err)
+}
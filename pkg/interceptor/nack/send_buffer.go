@@ -0,0 +1,82 @@
+package nack
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// sendBuffer keeps the last size packets of a local stream, so they can be
+// sent again when they are NACKed
+type sendBuffer struct {
+	packets   []*rtp.Packet
+	size      uint16
+	lastAdded uint16
+	started   bool
+
+	mu sync.RWMutex
+}
+
+func newSendBuffer(size uint16) (*sendBuffer, error) {
+	if !validSize(size) {
+		return nil, errInvalidSize
+	}
+
+	return &sendBuffer{
+		packets: make([]*rtp.Packet, size),
+		size:    size,
+	}, nil
+}
+
+func (s *sendBuffer) add(packet *rtp.Packet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := packet.SequenceNumber
+	if !s.started {
+		s.packets[seq%s.size] = packet
+		s.lastAdded = seq
+		s.started = true
+		return
+	}
+
+	diff := seq - s.lastAdded
+	switch {
+	case diff == 0:
+		return
+	case diff < uint16SizeHalf:
+		// Packets skipped by the sender mustn't be answered with older ones of the same slot
+		start := s.lastAdded + 1
+		if diff >= s.size {
+			start = seq - s.size + 1
+		}
+		for i := start; i != seq; i++ {
+			s.packets[i%s.size] = nil
+		}
+		s.lastAdded = seq
+	case s.lastAdded-seq >= s.size:
+		// Too old to ever be NACKed
+		return
+	}
+	s.packets[seq%s.size] = packet
+}
+
+func (s *sendBuffer) get(seq uint16) *rtp.Packet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.started {
+		return nil
+	}
+
+	diff := s.lastAdded - seq
+	if diff >= uint16SizeHalf || diff >= s.size {
+		return nil
+	}
+
+	packet := s.packets[seq%s.size]
+	if packet == nil || packet.SequenceNumber != seq {
+		return nil
+	}
+	return packet
+}
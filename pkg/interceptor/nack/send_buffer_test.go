@@ -0,0 +1,71 @@
+package nack
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendBuffer(t *testing.T) {
+	for _, start := range []uint16{0, 1, 127, 128, 129, 511, 512, 513, 32767, 32768, 32769, 65407, 65408, 65409, 65534, 65535} {
+		start := start
+
+		sb, err := newSendBuffer(128)
+		assert.NoError(t, err)
+
+		add := func(nums ...uint16) {
+			for _, n := range nums {
+				sb.add(&rtp.Packet{Header: rtp.Header{SequenceNumber: start + n}})
+			}
+		}
+
+		assertGet := func(nums ...uint16) {
+			t.Helper()
+			for _, n := range nums {
+				packet := sb.get(start + n)
+				if assert.NotNil(t, packet, "packet not found: %d", start+n) {
+					assert.Equal(t, start+n, packet.SequenceNumber)
+				}
+			}
+		}
+
+		assertNOTGet := func(nums ...uint16) {
+			t.Helper()
+			for _, n := range nums {
+				assert.Nil(t, sb.get(start+n), "packet found: %d", start+n)
+			}
+		}
+
+		add(0, 1, 2, 3, 4, 5, 6, 7)
+		assertGet(0, 1, 2, 3, 4, 5, 6, 7)
+
+		add(127)
+		assertGet(0, 1, 2, 3, 4, 5, 6, 7, 127)
+
+		add(128)
+		assertGet(1, 2, 3, 4, 5, 6, 7, 127, 128)
+		assertNOTGet(0)
+
+		// Skipped sequence numbers aren't answered with older packets
+		add(130)
+		assertGet(3, 4, 5, 6, 7, 127, 128, 130)
+		assertNOTGet(0, 1, 2, 129)
+
+		// Late packets are kept if they are still in the buffer
+		add(129, 2)
+		assertGet(127, 128, 129, 130)
+		assertNOTGet(2)
+
+		add(400)
+		assertGet(400)
+		assertNOTGet(127, 128, 129, 130, 399)
+	}
+}
+
+func TestSendBuffer_InvalidSize(t *testing.T) {
+	for _, size := range []uint16{0, 100, 1<<15 + 1} {
+		_, err := newSendBuffer(size)
+		assert.Equal(t, errInvalidSize, err)
+	}
+}
@@ -0,0 +1,36 @@
+package interceptor
+
+// NoOp is an Interceptor that does not modify any packets. It can be embedded
+// in Interceptors that only implement some of the methods
+type NoOp struct{}
+
+// BindRTCPReader returns reader unmodified
+func (i *NoOp) BindRTCPReader(reader RTCPReader) RTCPReader {
+	return reader
+}
+
+// BindRTCPWriter returns writer unmodified
+func (i *NoOp) BindRTCPWriter(writer RTCPWriter) RTCPWriter {
+	return writer
+}
+
+// BindLocalStream returns writer unmodified
+func (i *NoOp) BindLocalStream(_ *StreamInfo, writer RTPWriter) RTPWriter {
+	return writer
+}
+
+// UnbindLocalStream does nothing
+func (i *NoOp) UnbindLocalStream(_ *StreamInfo) {}
+
+// BindRemoteStream returns reader unmodified
+func (i *NoOp) BindRemoteStream(_ *StreamInfo, reader RTPReader) RTPReader {
+	return reader
+}
+
+// UnbindRemoteStream does nothing
+func (i *NoOp) UnbindRemoteStream(_ *StreamInfo) {}
+
+// Close does nothing
+func (i *NoOp) Close() error {
+	return nil
+}
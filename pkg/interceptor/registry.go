@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"github.com/pion/webrtc/v2/internal/util"
+)
+
+// Factory creates an Interceptor. Interceptors keep state about the streams
+// of a PeerConnection, so every PeerConnection gets Interceptors of its own
+type Factory interface {
+	NewInterceptor() (Interceptor, error)
+}
+
+// FactoryFunc is an adapter for Factory interface
+type FactoryFunc func() (Interceptor, error)
+
+// NewInterceptor creates an Interceptor
+func (f FactoryFunc) NewInterceptor() (Interceptor, error) {
+	return f()
+}
+
+// Registry collects the Factories of the Interceptors a PeerConnection uses
+type Registry struct {
+	factories []Factory
+}
+
+// Add adds a Factory to the Registry, its Interceptors come after the ones
+// of the Factories added before
+func (r *Registry) Add(f Factory) {
+	r.factories = append(r.factories, f)
+}
+
+// Build creates a Chain of the Interceptors of all Factories
+func (r *Registry) Build() (Interceptor, error) {
+	if len(r.factories) == 0 {
+		return &NoOp{}, nil
+	}
+
+	interceptors := []Interceptor{}
+	for _, f := range r.factories {
+		i, err := f.NewInterceptor()
+		if err != nil {
+			// The Interceptors that were already created are closed again
+			return nil, util.FlattenErrs([]error{err, NewChain(interceptors).Close()})
+		}
+		interceptors = append(interceptors, i)
+	}
+	return NewChain(interceptors), nil
+}
@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Build(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		i, err := (&Registry{}).Build()
+		assert.NoError(t, err)
+		assert.IsType(t, &NoOp{}, i)
+	})
+
+	t.Run("NewInstances", func(t *testing.T) {
+		created := 0
+		r := &Registry{}
+		r.Add(FactoryFunc(func() (Interceptor, error) {
+			created++
+			return &NoOp{}, nil
+		}))
+
+		for i := 0; i < 2; i++ {
+			if _, err := r.Build(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		assert.Equal(t, 2, created)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		errFactory := errors.New("factory failed")
+		errClose := errors.New("close failed")
+
+		r := &Registry{}
+		r.Add(FactoryFunc(func() (Interceptor, error) {
+			return &namedInterceptor{closeErr: errClose}, nil
+		}))
+		r.Add(FactoryFunc(func() (Interceptor, error) {
+			return nil, errFactory
+		}))
+
+		_, err := r.Build()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), errFactory.Error())
+			assert.Contains(t, err.Error(), errClose.Error())
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package interceptor
+
+// RTPHeaderExtension is a negotiated RTP header extension and the id it is sent with
+type RTPHeaderExtension struct {
+	URI string
+	ID  int
+}
+
+// RTCPFeedback is a negotiated RTCP feedback mechanism, like type "nack" with parameter "pli"
+type RTCPFeedback struct {
+	Type      string
+	Parameter string
+}
+
+// StreamInfo describes the stream an Interceptor is bound to. A remote
+// stream is bound once its first packet arrived, its codec and RTCP feedback
+// are the ones negotiated for the payload type of that packet
+type StreamInfo struct {
+	ID                  string
+	SSRC                uint32
+	PayloadType         uint8
+	RTPHeaderExtensions []RTPHeaderExtension
+	MimeType            string
+	ClockRate           uint32
+	Channels            uint16
+	SDPFmtpLine         string
+	RTCPFeedback        []RTCPFeedback
+
+	// The SSRC and payload type retransmissions are sent with, if the
+	// stream negotiated RTX. Otherwise they are resent on the stream itself
+	SSRCRetransmission        uint32
+	PayloadTypeRetransmission uint8
+
+	// The forward error correction the stream negotiated. FlexFEC is sent on
+	// its own SSRC, ULPFEC is sent on the stream itself wrapped in RED. The
+	// payload type is the one of the FEC codec in use
+	SSRCForwardErrorCorrection        uint32
+	PayloadTypeForwardErrorCorrection uint8
+	PayloadTypeRED                    uint8
+}
@@ -0,0 +1,105 @@
+// +build !js
+
+package webrtc
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pion/sdp/v2"
+)
+
+// planBDataMid is the mid of the data m-line of a Plan-B description, the
+// m-line that carries all tracks of a kind uses the kind as its mid
+// https://tools.ietf.org/html/draft-uberti-rtcweb-plan-00
+const planBDataMid = "data"
+
+// getPlanBMids returns the mids of a Plan-B offer. The m-lines that were
+// negotiated before keep their position, kinds that have transceivers for
+// the first time are appended
+func getPlanBMids(negotiated []*sdp.MediaDescription, transceivers map[string][]*RTPTransceiver) []string {
+	mids := []string{}
+	added := map[string]bool{}
+	add := func(midValue string) {
+		if !added[midValue] {
+			added[midValue] = true
+			mids = append(mids, midValue)
+		}
+	}
+
+	for _, media := range negotiated {
+		if midValue, ok := media.Attribute(sdp.AttrKeyMID); ok {
+			add(midValue)
+		}
+	}
+	for _, kind := range []RTPCodecType{RTPCodecTypeVideo, RTPCodecTypeAudio} {
+		if len(transceivers[kind.String()]) != 0 {
+			add(kind.String())
+		}
+	}
+	add(planBDataMid)
+	return mids
+}
+
+// getSharedDirection returns the direction of an m-line shared by transceivers,
+// it sends if any of them sends and receives if any of them receives
+func getSharedDirection(transceivers []*RTPTransceiver) RTPTransceiverDirection {
+	send, recv := false, false
+	for _, t := range transceivers {
+		send = send || t.Direction.hasSend()
+		recv = recv || t.Direction.hasRecv()
+	}
+	return newRTPTransceiverDirectionFromSendRecv(send, recv)
+}
+
+// addMsidSemantic announces the media streams of the tracks that are sent,
+// Plan-B endpoints need it to read the msid of the a=ssrc lines
+// https://tools.ietf.org/html/draft-ietf-mmusic-msid-05#section-4
+func addMsidSemantic(d *sdp.SessionDescription, transceivers []*RTPTransceiver) {
+	streams := []string{sdp.SemanticTokenWebRTCMediaStreams}
+	added := map[string]bool{}
+	for _, t := range transceivers {
+		if t.stopped || t.Sender == nil || t.Sender.Track() == nil || !t.Direction.hasSend() {
+			continue
+		}
+		if label := t.Sender.Track().Label(); !added[label] {
+			added[label] = true
+			streams = append(streams, label)
+		}
+	}
+	d.WithValueAttribute(sdp.AttrKeyMsidSemantic, strings.Join(streams, " "))
+}
+
+// getRepairSSRCs returns the SSRCs an m-line uses to repair its tracks, they
+// follow the first SSRC of a FID or FEC-FR group and don't carry a track of their own.
+// A group may list more than one of them, none are left out
+// https://tools.ietf.org/html/rfc5576#section-4.2
+func getRepairSSRCs(media *sdp.MediaDescription) map[uint32]bool {
+	repairSSRCs := map[uint32]bool{}
+	for _, attr := range media.Attributes {
+		if attr.Key != sdp.AttrKeySSRCGroup {
+			continue
+		}
+
+		fields := strings.Fields(attr.Value)
+		if len(fields) < 3 || (fields[0] != "FID" && fields[0] != "FEC-FR") {
+			continue
+		}
+		for _, field := range fields[2:] {
+			if ssrc, err := strconv.ParseUint(field, 10, 32); err == nil {
+				repairSSRCs[uint32(ssrc)] = true
+			}
+		}
+	}
+	return repairSSRCs
+}
+
+// getMediaOfKind returns the first m-line of a kind, Plan-B descriptions have one for all tracks
+func getMediaOfKind(d *sdp.SessionDescription, kind RTPCodecType) *sdp.MediaDescription {
+	for _, media := range d.MediaDescriptions {
+		if NewRTPCodecType(media.MediaName.Media) == kind {
+			return media
+		}
+	}
+	return nil
+}
@@ -1,5 +1,22 @@
 package webrtc
 
+const (
+	// TypeRTCPFBTransportCC is transport wide congestion control feedback
+	TypeRTCPFBTransportCC = "transport-cc"
+
+	// TypeRTCPFBGoogREMB is receiver estimated maximum bitrate feedback
+	TypeRTCPFBGoogREMB = "goog-remb"
+
+	// TypeRTCPFBACK is positive acknowledgement feedback
+	TypeRTCPFBACK = "ack"
+
+	// TypeRTCPFBCCM is codec control feedback, like parameter "fir"
+	TypeRTCPFBCCM = "ccm"
+
+	// TypeRTCPFBNACK is negative acknowledgement feedback, like parameter "pli"
+	TypeRTCPFBNACK = "nack"
+)
+
 // RTCPFeedback signals the connection to use additional RTCP packet types.
 // https://draft.ortc.org/#dom-rtcrtcpfeedback
 type RTCPFeedback struct {
@@ -0,0 +1,154 @@
+// +build !js
+
+package webrtc
+
+import (
+	"github.com/pion/sdp/v2"
+	"github.com/pion/webrtc/v2/internal/util"
+)
+
+// rtcpTransport carries the RTCP of the PeerConnection if the remote doesn't
+// multiplex RTP and RTCP. ICE runs for component 2 on an agent of its own, it
+// gathers like the one of the RTP component and shares its credentials. The
+// component is secured by a DTLS association of its own.
+// https://tools.ietf.org/html/rfc5761
+// https://tools.ietf.org/html/rfc5764#section-4.1
+type rtcpTransport struct {
+	iceGatherer   *ICEGatherer
+	iceTransport  *ICETransport
+	dtlsTransport *DTLSTransport
+}
+
+// newRTCPTransport creates the transports of the RTCP component. Without trickle
+// its candidates are gathered right away, so they are complete in the description.
+// Otherwise they are gathered once the transports start and signaled through the
+// OnICECandidate handler
+func (pc *PeerConnection) newRTCPTransport() (*rtcpTransport, error) {
+	iceGatherer, err := pc.createICEGatherer()
+	if err != nil {
+		return nil, err
+	}
+	iceGatherer.rtpGatherer = pc.iceGatherer
+	if !iceGatherer.agentIsTrickle {
+		if err = iceGatherer.Gather(); err != nil {
+			return nil, err
+		}
+	} else {
+		iceGatherer.OnLocalCandidate(func(candidate *ICECandidate) {
+			// The end of gathering is signaled by the ICEGatherer of the RTP component
+			if candidate == nil {
+				return
+			}
+
+			pc.iceGatherer.lock.RLock()
+			onLocalCandidateHdlr := pc.iceGatherer.onLocalCandidateHdlr
+			pc.iceGatherer.lock.RUnlock()
+			if onLocalCandidateHdlr != nil {
+				onLocalCandidateHdlr(candidate)
+			}
+		})
+	}
+
+	iceTransport := pc.api.NewICETransport(iceGatherer)
+	dtlsTransport, err := pc.api.NewDTLSTransport(iceTransport, pc.configuration.Certificates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rtcpTransport{
+		iceGatherer:   iceGatherer,
+		iceTransport:  iceTransport,
+		dtlsTransport: dtlsTransport,
+	}, nil
+}
+
+// start connects the RTCP component and runs DTLS over it, it blocks until
+// both are connected or have failed
+func (t *rtcpTransport) start(remoteParams ICEParameters, iceRole ICERole, dtlsParams DTLSParameters) *TransportError {
+	if err := t.gather(); err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+
+	if err := t.iceTransport.Start(t.iceGatherer, remoteParams, &iceRole); err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+	if err := t.dtlsTransport.Start(dtlsParams); err != nil {
+		return &TransportError{Component: TransportComponentDTLS, Err: err}
+	}
+	return nil
+}
+
+// restart connects the agent the ICEGatherer created for an ICE restart, it
+// blocks until it is connected
+func (t *rtcpTransport) restart(remoteParams ICEParameters) *TransportError {
+	if err := t.gather(); err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+
+	if err := t.iceTransport.restart(remoteParams); err != nil {
+		return &TransportError{Component: TransportComponentICE, Err: err}
+	}
+	return nil
+}
+
+// gather starts trickling the candidates of the RTCP component, they are only
+// of use once the remote turned out not to multiplex RTCP
+func (t *rtcpTransport) gather() error {
+	if !t.iceGatherer.agentIsTrickle || t.iceGatherer.State() != ICEGathererStateNew {
+		return nil
+	}
+	return t.iceGatherer.Gather()
+}
+
+// stop closes the transports, an ICEGatherer that was never started is closed as well
+func (t *rtcpTransport) stop() error {
+	var closeErrs []error
+	if err := t.dtlsTransport.Stop(); err != nil {
+		closeErrs = append(closeErrs, err)
+	}
+	if err := t.iceTransport.Stop(); err != nil {
+		closeErrs = append(closeErrs, err)
+	}
+	return util.FlattenErrs(closeErrs)
+}
+
+// haveRTCPMux tells if RTCP is multiplexed for an m-line of a remote description.
+// Bundled m-lines always multiplex RTCP, so do m-lines that don't carry RTP.
+// https://tools.ietf.org/html/rfc8843#section-9.3
+func haveRTCPMux(desc *sdp.SessionDescription, media *sdp.MediaDescription) bool {
+	if getBundleGroup(desc) != nil || media.MediaName.Port.Value == 0 {
+		return true
+	}
+
+	switch NewRTPCodecType(media.MediaName.Media) {
+	case RTPCodecTypeAudio, RTPCodecTypeVideo:
+		_, ok := media.Attribute(sdp.AttrKeyRTCPMux)
+		return ok
+	default:
+		return true
+	}
+}
+
+// extractRTCPCandidates returns the candidates of the RTCP component of a remote m-line
+func extractRTCPCandidates(m *sdp.MediaDescription) ([]ICECandidate, error) {
+	candidates := []ICECandidate{}
+	for _, a := range m.Attributes {
+		if !a.IsICECandidate() {
+			continue
+		}
+
+		sdpCandidate, err := a.ToICECandidate()
+		if err != nil {
+			return nil, err
+		} else if sdpCandidate.Component != uint16(ICEComponentRTCP) {
+			continue
+		}
+
+		candidate, err := newICECandidateFromSDP(sdpCandidate)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
@@ -5,4 +5,12 @@ package webrtc
 // http://draft.ortc.org/#dom-rtcrtpencodingparameters
 type RTPEncodingParameters struct {
 	RTPCodingParameters
+
+	// Active tells if media is sent for this encoding. An inactive encoding
+	// stays negotiated, so it can be resumed without renegotiation
+	Active bool `json:"active"`
+
+	// MaxBitrate is the maximum bitrate of this encoding in bits per second, it
+	// is signaled as a RID restriction. 0 means there is no limit
+	MaxBitrate uint64 `json:"maxBitrate"`
 }
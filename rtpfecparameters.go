@@ -0,0 +1,8 @@
+package webrtc
+
+// RTPFecParameters dictionary contains information relating to forward error correction (FEC) settings.
+// https://draft.ortc.org/#dom-rtcrtpfecparameters
+type RTPFecParameters struct {
+	SSRC      uint32 `json:"ssrc"`
+	Mechanism string `json:"mechanism"`
+}
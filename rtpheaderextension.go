@@ -0,0 +1,167 @@
+// +build !js
+
+package webrtc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v2"
+)
+
+// RTP header extensions used to demultiplex media without signaled SSRCs
+const (
+	// https://tools.ietf.org/html/rfc8843#section-15
+	sdesMidURI = "urn:ietf:params:rtp-hdrext:sdes:mid"
+
+	// https://tools.ietf.org/html/rfc8852#section-4.3
+	sdesRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+)
+
+// rtpHeaderExtensionURIs are the RTP header extensions we negotiate, in the order they are offered
+var rtpHeaderExtensionURIs = []string{sdesMidURI, sdesRTPStreamIDURI}
+
+const (
+	rtpHeaderExtensionProfileOneByte = 0xBEDE
+	rtpHeaderExtensionProfileTwoByte = 0x1000
+)
+
+// defaultRTPHeaderExtensionIDs returns the ids we use for RTP header extensions in offers
+func defaultRTPHeaderExtensionIDs() map[string]int {
+	ids := map[string]int{}
+	for i, uri := range rtpHeaderExtensionURIs {
+		ids[uri] = i + 1
+	}
+	return ids
+}
+
+// getRTPHeaderExtensionIDs returns the ids of the RTP header extensions we
+// understand that were negotiated in a media section, keyed by URI
+func getRTPHeaderExtensionIDs(media *sdp.MediaDescription) map[string]int {
+	ids := map[string]int{}
+	for _, attr := range media.Attributes {
+		if attr.Key != "extmap" {
+			continue
+		}
+
+		fields := strings.Fields(attr.Value)
+		if len(fields) < 2 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.Split(fields[0], "/")[0])
+		if err != nil {
+			continue
+		}
+
+		for _, uri := range rtpHeaderExtensionURIs {
+			if fields[1] == uri {
+				ids[uri] = id
+			}
+		}
+	}
+	return ids
+}
+
+// addRTPHeaderExtensions adds an extmap attribute for every RTP header extension in ids
+func addRTPHeaderExtensions(media *sdp.MediaDescription, ids map[string]int) {
+	for _, uri := range rtpHeaderExtensionURIs {
+		if id, ok := ids[uri]; ok {
+			media.WithValueAttribute("extmap", fmt.Sprintf("%d %s", id, uri))
+		}
+	}
+}
+
+// getRTPHeaderExtension returns the value of the RTP header extension with the
+// given id, both the one-byte and two-byte header forms of RFC 8285 are supported
+func getRTPHeaderExtension(header *rtp.Header, id int) ([]byte, bool) {
+	if !header.Extension || id <= 0 {
+		return nil, false
+	}
+
+	payload := header.ExtensionPayload
+	switch {
+	case header.ExtensionProfile == rtpHeaderExtensionProfileOneByte:
+		for i := 0; i < len(payload); {
+			if payload[i] == 0x00 { // padding
+				i++
+				continue
+			}
+
+			extID := int(payload[i] >> 4)
+			extLen := int(payload[i]&0x0F) + 1
+			if extID == 15 || i+1+extLen > len(payload) {
+				return nil, false
+			} else if extID == id {
+				return payload[i+1 : i+1+extLen], true
+			}
+			i += 1 + extLen
+		}
+	case header.ExtensionProfile&0xFFF0 == rtpHeaderExtensionProfileTwoByte:
+		for i := 0; i < len(payload); {
+			if payload[i] == 0x00 { // padding
+				i++
+				continue
+			} else if i+2 > len(payload) {
+				return nil, false
+			}
+
+			extID := int(payload[i])
+			extLen := int(payload[i+1])
+			if i+2+extLen > len(payload) {
+				return nil, false
+			} else if extID == id {
+				return payload[i+2 : i+2+extLen], true
+			}
+			i += 2 + extLen
+		}
+	}
+	return nil, false
+}
+
+// setRTPHeaderExtensions replaces the header extensions of header with values keyed by
+// extension id, using the one-byte header form of RFC 8285. Values that can't be
+// represented in that form are skipped
+func setRTPHeaderExtensions(header *rtp.Header, values map[int][]byte) {
+	ids := []int{}
+	for id, value := range values {
+		if id > 0 && id < 15 && len(value) > 0 && len(value) <= 16 {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Ints(ids)
+
+	payload := []byte{}
+	for _, id := range ids {
+		payload = append(payload, byte(id<<4)|byte(len(values[id])-1))
+		payload = append(payload, values[id]...)
+	}
+	for len(payload)%4 != 0 {
+		payload = append(payload, 0x00)
+	}
+
+	header.Extension = true
+	header.ExtensionProfile = rtpHeaderExtensionProfileOneByte
+	header.ExtensionPayload = payload
+}
+
+// getMidAndRIDFromPacket parses the MID and RID extensions of a raw RTP packet
+func getMidAndRIDFromPacket(buf []byte, ids map[string]int) (mid, rid string, err error) {
+	header := &rtp.Header{}
+	if err = header.Unmarshal(buf); err != nil {
+		return "", "", err
+	}
+
+	if value, ok := getRTPHeaderExtension(header, ids[sdesMidURI]); ok {
+		mid = string(value)
+	}
+	if value, ok := getRTPHeaderExtension(header, ids[sdesRTPStreamIDURI]); ok {
+		rid = string(value)
+	}
+	return mid, rid, nil
+}
@@ -0,0 +1,79 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRTPHeaderExtension(t *testing.T) {
+	oneByte := &rtp.Header{
+		Extension:        true,
+		ExtensionProfile: 0xBEDE,
+		ExtensionPayload: []byte{0x10, 'a', 0x00, 0x21, 'b', 'c', 0x00, 0x00},
+	}
+	value, ok := getRTPHeaderExtension(oneByte, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), value)
+	value, ok = getRTPHeaderExtension(oneByte, 2)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("bc"), value)
+	_, ok = getRTPHeaderExtension(oneByte, 3)
+	assert.False(t, ok)
+
+	twoByte := &rtp.Header{
+		Extension:        true,
+		ExtensionProfile: 0x1000,
+		ExtensionPayload: []byte{0x01, 0x00, 0x02, 0x02, 'd', 'e', 0x00, 0x00},
+	}
+	value, ok = getRTPHeaderExtension(twoByte, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte{}, value)
+	value, ok = getRTPHeaderExtension(twoByte, 2)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("de"), value)
+
+	truncated := &rtp.Header{
+		Extension:        true,
+		ExtensionProfile: 0xBEDE,
+		ExtensionPayload: []byte{0x13, 'a'},
+	}
+	_, ok = getRTPHeaderExtension(truncated, 1)
+	assert.False(t, ok)
+}
+
+func TestGetRTPHeaderExtensionIDs(t *testing.T) {
+	media := (&sdp.MediaDescription{}).
+		WithValueAttribute("extmap", "3 "+sdesMidURI).
+		WithValueAttribute("extmap", "4/recvonly "+sdesRTPStreamIDURI).
+		WithValueAttribute("extmap", "5 urn:ietf:params:rtp-hdrext:toffset")
+
+	assert.Equal(t, map[string]int{sdesMidURI: 3, sdesRTPStreamIDURI: 4}, getRTPHeaderExtensionIDs(media))
+}
+
+func TestSetRTPHeaderExtensions(t *testing.T) {
+	header := &rtp.Header{}
+	setRTPHeaderExtensions(header, map[int][]byte{
+		2: []byte("h"),
+		1: []byte("0"),
+		0: []byte("ignored"),
+	})
+	assert.True(t, header.Extension)
+	assert.Equal(t, uint16(0xBEDE), header.ExtensionProfile)
+	assert.Equal(t, []byte{0x10, '0', 0x20, 'h'}, header.ExtensionPayload)
+
+	header = &rtp.Header{}
+	setRTPHeaderExtensions(header, map[int][]byte{1: []byte("abc")})
+	assert.Equal(t, []byte{0x12, 'a', 'b', 'c'}, header.ExtensionPayload)
+	value, ok := getRTPHeaderExtension(header, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("abc"), value)
+
+	header = &rtp.Header{}
+	setRTPHeaderExtensions(header, map[int][]byte{1: {}})
+	assert.False(t, header.Extension)
+}
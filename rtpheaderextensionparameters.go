@@ -0,0 +1,9 @@
+package webrtc
+
+// RTPHeaderExtensionParameters maps a negotiated RTP header extension to the
+// id it is sent with
+// http://draft.ortc.org/#dom-rtcrtpheaderextensionparameters
+type RTPHeaderExtensionParameters struct {
+	URI string `json:"uri"`
+	ID  int    `json:"id"`
+}
@@ -4,25 +4,57 @@ package webrtc
 
 import (
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/srtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 )
 
+// trackStreams maintains a Track and the streams it is read from
+type trackStreams struct {
+	track *Track
+
+	rtpReadStream  *srtp.ReadStreamSRTP
+	rtcpReadStream *srtp.ReadStreamSRTCP
+
+	// Retransmissions of the Track if the remote sends RTX, they are read
+	// along with the packets of the Track once they were unwrapped. So are
+	// FlexFEC packets, which the Interceptors recover lost packets with
+	repairReadStream *srtp.ReadStreamSRTP
+	fecReadStream    *srtp.ReadStreamSRTP
+	repairPackets    chan []byte
+
+	// Packets read from the stream before the Track was added, they are read first
+	replayPackets [][]byte
+
+	// RTP and RTCP of the Track pass the Interceptors of the API. The RTP
+	// stream is bound once its first packet tells the payload type
+	streamInfo *interceptor.StreamInfo
+	rtpSource  interceptor.RTPReader
+	rtpReader  interceptor.RTPReader
+	rtcpReader interceptor.RTCPReader
+	bindOnce   sync.Once
+	bindErr    error
+}
+
 // RTPReceiver allows an application to inspect the receipt of a Track
 type RTPReceiver struct {
 	kind      RTPCodecType
 	transport *DTLSTransport
 
-	track *Track
+	// One Track per RID when receiving simulcast, otherwise a single Track
+	tracks []*trackStreams
+
+	// Returns the RTCP feedback negotiated for a payload type, set by the
+	// PeerConnection. Otherwise the feedback of the MediaEngine is used
+	rtcpFeedback func(payloadType uint8) []RTCPFeedback
 
 	closed, received chan interface{}
 	mu               sync.RWMutex
 
-	rtpReadStream  *srtp.ReadStreamSRTP
-	rtcpReadStream *srtp.ReadStreamSRTCP
-
 	// A reference to the associated api object
 	api *API
 }
@@ -50,11 +82,48 @@ func (r *RTPReceiver) Transport() *DTLSTransport {
 	return r.transport
 }
 
-// Track returns the RTCRtpTransceiver track
+// setTransport moves a RTPReceiver that is not receiving yet to another DTLSTransport
+func (r *RTPReceiver) setTransport(transport *DTLSTransport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.received:
+	default:
+		r.transport = transport
+	}
+}
+
+// setRTCPFeedback sets how the RTCP feedback of a payload type is looked up
+func (r *RTPReceiver) setRTCPFeedback(rtcpFeedback func(payloadType uint8) []RTCPFeedback) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rtcpFeedback = rtcpFeedback
+}
+
+// Track returns the RTCRtpTransceiver track, when receiving simulcast
+// this is the Track of the first RID that arrived
 func (r *RTPReceiver) Track() *Track {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.track
+
+	if len(r.tracks) == 0 {
+		return nil
+	}
+	return r.tracks[0].track
+}
+
+// Tracks returns all the Tracks of the RTPReceiver, there is one Track
+// for every RID when receiving simulcast
+func (r *RTPReceiver) Tracks() []*Track {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tracks := []*Track{}
+	for _, t := range r.tracks {
+		tracks = append(tracks, t.track)
+	}
+	return tracks
 }
 
 // Receive initialize the track and starts all the transports
@@ -66,41 +135,167 @@ func (r *RTPReceiver) Receive(parameters RTPReceiveParameters) error {
 		return fmt.Errorf("Receive has already been called")
 	default:
 	}
-	close(r.received)
 
-	r.track = &Track{
-		kind:     r.kind,
-		ssrc:     parameters.Encodings.SSRC,
-		receiver: r,
+	_, err := r.addTrack(parameters.Encodings.RTPCodingParameters)
+	return err
+}
+
+// receiveForRID starts receiving one layer of a simulcast stream, every
+// RID gets its own Track
+func (r *RTPReceiver) receiveForRID(rid string, ssrc uint32) (*Track, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.closed:
+		return nil, fmt.Errorf("RTPReceiver has been stopped")
+	default:
+	}
+
+	for _, t := range r.tracks {
+		if t.track.rid == rid {
+			return nil, fmt.Errorf("RTPReceiver is already receiving RID %s", rid)
+		}
+	}
+
+	return r.addTrack(RTPCodingParameters{SSRC: ssrc, RID: rid})
+}
+
+// addTrack opens the streams of an encoding and adds a Track reading them, r.mu must be held
+func (r *RTPReceiver) addTrack(parameters RTPCodingParameters) (*Track, error) {
+	select {
+	case <-r.received:
+	default:
+		close(r.received)
+	}
+
+	t := &trackStreams{
+		track: &Track{
+			kind:     r.kind,
+			ssrc:     parameters.SSRC,
+			rid:      parameters.RID,
+			receiver: r,
+		},
 	}
 
 	srtpSession, err := r.transport.getSRTPSession()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	r.rtpReadStream, err = srtpSession.OpenReadStream(parameters.Encodings.SSRC)
+	t.rtpReadStream, err = srtpSession.OpenReadStream(parameters.SSRC)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	srtcpSession, err := r.transport.getSRTCPSession()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	r.rtcpReadStream, err = srtcpSession.OpenReadStream(parameters.Encodings.SSRC)
+	t.rtcpReadStream, err = srtcpSession.OpenReadStream(parameters.SSRC)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if parameters.RTX.SSRC != 0 || parameters.FEC.SSRC != 0 {
+		t.repairPackets = make(chan []byte, repairPacketsBufferSize)
+	}
+	if parameters.RTX.SSRC != 0 {
+		if t.repairReadStream, err = srtpSession.OpenReadStream(parameters.RTX.SSRC); err != nil {
+			return nil, err
+		}
+		go r.readRTX(t.repairReadStream, parameters.SSRC, t.repairPackets)
+	}
+	if parameters.FEC.SSRC != 0 {
+		if t.fecReadStream, err = srtpSession.OpenReadStream(parameters.FEC.SSRC); err != nil {
+			return nil, err
+		}
+		go r.readFEC(t.fecReadStream, t.repairPackets)
+	}
+
+	// With repair packets the stream is read by a goroutine of its own, so
+	// either is read as soon as it arrives
+	var mediaPackets chan []byte
+	if t.repairPackets != nil {
+		mediaPackets = make(chan []byte)
+		go r.readMedia(t.rtpReadStream, mediaPackets)
+	}
+
+	rtpReadStream, rtcpReadStream, repairPackets := t.rtpReadStream, t.rtcpReadStream, t.repairPackets
+	t.streamInfo = &interceptor.StreamInfo{SSRC: parameters.SSRC, SSRCRetransmission: parameters.RTX.SSRC}
+	// The remote protects the Track with FlexFEC if it signaled a SSRC for it, otherwise it may use ULPFEC
+	flexfecCodec := r.api.mediaEngine.getCodecByName(r.kind, FlexFEC03)
+	redCodec, ulpfecCodec := r.api.mediaEngine.getCodecByName(r.kind, RED), r.api.mediaEngine.getCodecByName(r.kind, ULPFEC)
+	switch {
+	case parameters.FEC.SSRC != 0 && flexfecCodec != nil:
+		t.streamInfo.SSRCForwardErrorCorrection = parameters.FEC.SSRC
+		t.streamInfo.PayloadTypeForwardErrorCorrection = flexfecCodec.PayloadType
+	case redCodec != nil && ulpfecCodec != nil:
+		t.streamInfo.PayloadTypeRED = redCodec.PayloadType
+		t.streamInfo.PayloadTypeForwardErrorCorrection = ulpfecCodec.PayloadType
+	}
+	t.rtpSource = interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		r.mu.Lock()
+		if len(t.replayPackets) != 0 {
+			packet := t.replayPackets[0]
+			t.replayPackets = t.replayPackets[1:]
+			r.mu.Unlock()
+			return copy(b, packet), attributes, nil
+		}
+		r.mu.Unlock()
+
+		if mediaPackets == nil {
+			n, err := rtpReadStream.Read(b)
+			return n, attributes, err
+		}
+
+		select {
+		case packet := <-repairPackets:
+			return copy(b, packet), attributes, nil
+		case packet, ok := <-mediaPackets:
+			if !ok {
+				return 0, attributes, io.EOF
+			}
+			return copy(b, packet), attributes, nil
+		}
+	})
+	t.rtcpReader = r.api.interceptor.BindRTCPReader(interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, err := rtcpReadStream.Read(b)
+		return n, attributes, err
+	}))
+
+	r.tracks = append(r.tracks, t)
+	return t.track, nil
+}
+
+// replay hands packets that were read from the stream of a Track before it
+// was added to the Track, so they are read before the stream
+func (r *RTPReceiver) replay(track *Track, packets [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.tracks {
+		if t.track == track {
+			t.replayPackets = append(t.replayPackets, packets...)
+		}
+	}
 }
 
 // Read reads incoming RTCP for this RTPReceiver
 func (r *RTPReceiver) Read(b []byte) (n int, err error) {
 	<-r.received
-	return r.rtcpReadStream.Read(b)
+
+	r.mu.RLock()
+	if len(r.tracks) == 0 {
+		r.mu.RUnlock()
+		return 0, fmt.Errorf("RTPReceiver has no Track to read RTCP for")
+	}
+	rtcpReader := r.tracks[0].rtcpReader
+	r.mu.RUnlock()
+
+	n, _, err = rtcpReader.Read(b, make(interceptor.Attributes))
+	return n, err
 }
 
 // ReadRTCP is a convenience method that wraps Read and unmarshals for you
@@ -125,15 +320,30 @@ func (r *RTPReceiver) Stop() error {
 	default:
 	}
 
-	select {
-	case <-r.received:
-		if err := r.rtcpReadStream.Close(); err != nil {
-			return err
+	for _, t := range r.tracks {
+		if t.rtpReader != nil {
+			r.api.interceptor.UnbindRemoteStream(t.streamInfo)
 		}
-		if err := r.rtpReadStream.Close(); err != nil {
-			return err
+		if t.rtcpReadStream != nil {
+			if err := t.rtcpReadStream.Close(); err != nil {
+				return err
+			}
+		}
+		if t.rtpReadStream != nil {
+			if err := t.rtpReadStream.Close(); err != nil {
+				return err
+			}
+		}
+		if t.repairReadStream != nil {
+			if err := t.repairReadStream.Close(); err != nil {
+				return err
+			}
+		}
+		if t.fecReadStream != nil {
+			if err := t.fecReadStream.Close(); err != nil {
+				return err
+			}
 		}
-	default:
 	}
 
 	close(r.closed)
@@ -141,7 +351,168 @@ func (r *RTPReceiver) Stop() error {
 }
 
 // readRTP should only be called by a track, this only exists so we can keep state in one place
-func (r *RTPReceiver) readRTP(b []byte) (n int, err error) {
+func (r *RTPReceiver) readRTP(b []byte, reader *Track) (n int, err error) {
 	<-r.received
-	return r.rtpReadStream.Read(b)
+
+	r.mu.RLock()
+	var track *trackStreams
+	for _, t := range r.tracks {
+		if t.track == reader {
+			track = t
+		}
+	}
+	r.mu.RUnlock()
+
+	if track == nil {
+		return 0, fmt.Errorf("Track is not received by this RTPReceiver")
+	}
+
+	track.bindOnce.Do(func() {
+		track.bindErr = r.bindRemoteStream(track)
+	})
+	if track.bindErr != nil {
+		return 0, track.bindErr
+	}
+
+	r.mu.RLock()
+	rtpReader, ssrc := track.rtpReader, track.streamInfo.SSRC
+	r.mu.RUnlock()
+
+	// FlexFEC packets are read along with the Track, they only reach it if no Interceptor consumed them
+	for {
+		n, _, err = rtpReader.Read(b, make(interceptor.Attributes))
+		header := rtp.Header{}
+		if err != nil || header.Unmarshal(b[:n]) != nil || header.SSRC == ssrc {
+			return n, err
+		}
+	}
+}
+
+// bindRemoteStream binds the RTP stream of a Track to the Interceptors once
+// its first packet arrived, so they know the codec and the RTCP feedback
+// negotiated for it. The packets read until then are read again through them
+func (r *RTPReceiver) bindRemoteStream(t *trackStreams) error {
+	pending := [][]byte{}
+	header := rtp.Header{}
+	for {
+		b := make([]byte, receiveMTU)
+		n, _, err := t.rtpSource.Read(b, make(interceptor.Attributes))
+		if err != nil {
+			return err
+		}
+		pending = append(pending, b[:n])
+
+		// FlexFEC packets are read along with the Track
+		if header.Unmarshal(b[:n]) == nil && header.SSRC == t.streamInfo.SSRC {
+			break
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.closed:
+		return io.EOF
+	default:
+	}
+
+	t.streamInfo.PayloadType = header.PayloadType
+	feedback := []RTCPFeedback{}
+	if codec, err := r.api.mediaEngine.getCodec(header.PayloadType); err == nil {
+		t.streamInfo.MimeType = codec.MimeType
+		t.streamInfo.ClockRate = codec.ClockRate
+		t.streamInfo.Channels = codec.Channels
+		t.streamInfo.SDPFmtpLine = codec.SDPFmtpLine
+		feedback = codec.RTCPFeedback
+	}
+	if r.rtcpFeedback != nil {
+		feedback = r.rtcpFeedback(header.PayloadType)
+	}
+	for _, f := range feedback {
+		t.streamInfo.RTCPFeedback = append(t.streamInfo.RTCPFeedback, interceptor.RTCPFeedback{Type: f.Type, Parameter: f.Parameter})
+	}
+
+	t.rtpReader = r.api.interceptor.BindRemoteStream(t.streamInfo, interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		if len(pending) != 0 {
+			packet := pending[0]
+			pending = pending[1:]
+			return copy(b, packet), attributes, nil
+		}
+		return t.rtpSource.Read(b, attributes)
+	}))
+	return nil
+}
+
+// readMedia reads the packets of a Track that is repaired by a stream of
+// its own until the stream is closed, which closes mediaPackets
+func (r *RTPReceiver) readMedia(stream *srtp.ReadStreamSRTP, mediaPackets chan<- []byte) {
+	defer close(mediaPackets)
+
+	b := make([]byte, receiveMTU)
+	for {
+		n, err := stream.Read(b)
+		if err != nil {
+			return
+		}
+
+		select {
+		case mediaPackets <- append([]byte{}, b[:n]...):
+		case <-r.closed:
+			return
+		}
+	}
+}
+
+// readRTX unwraps the packets of a RTX stream until it is closed, and
+// hands them to the Track of the SSRC they retransmit
+func (r *RTPReceiver) readRTX(stream *srtp.ReadStreamSRTP, ssrc uint32, repairPackets chan<- []byte) {
+	b := make([]byte, receiveMTU)
+	for {
+		n, err := stream.Read(b)
+		if err != nil {
+			return
+		}
+
+		p := &rtp.Packet{}
+		if err = p.Unmarshal(b[:n]); err != nil {
+			continue
+		}
+		codec, err := r.api.mediaEngine.getCodec(p.PayloadType)
+		if err != nil {
+			continue
+		}
+		payloadType, ok := codec.getAssociatedPayloadType()
+		if !ok {
+			continue
+		}
+
+		packet, err := unwrapRTX(p, ssrc, payloadType)
+		if err != nil {
+			continue
+		}
+		select {
+		case repairPackets <- packet:
+		default:
+			// The Track isn't read fast enough, the retransmission is lost as well
+		}
+	}
+}
+
+// readFEC reads the packets of a FlexFEC stream until it is closed, and
+// hands them to the Track they protect
+func (r *RTPReceiver) readFEC(stream *srtp.ReadStreamSRTP, repairPackets chan<- []byte) {
+	b := make([]byte, receiveMTU)
+	for {
+		n, err := stream.Read(b)
+		if err != nil {
+			return
+		}
+
+		select {
+		case repairPackets <- append([]byte{}, b[:n]...):
+		default:
+			// The Track isn't read fast enough, the packet is lost as well
+		}
+	}
 }
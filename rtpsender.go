@@ -4,20 +4,94 @@ package webrtc
 
 import (
 	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/srtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
 )
 
+// trackEncoding is a single encoding sent by an RTPSender, and the Track it is sent from
+type trackEncoding struct {
+	track *Track
+
+	// The SSRC and PayloadType media is sent with, these stay the same when the Track is replaced
+	RTPEncodingParameters
+
+	rtcpReadStream *srtp.ReadStreamSRTCP
+
+	// Set by Send, RTP and RTCP of the encoding pass the Interceptors of the API
+	streamInfo *interceptor.StreamInfo
+	rtpWriter  interceptor.RTPWriter
+	rtcpReader interceptor.RTCPReader
+
+	// What was sent of the encoding, for its Sender Reports
+	packetCount      uint32
+	octetCount       uint32
+	lastRTPTimestamp uint32
+	lastSentTime     time.Time
+	statsMu          sync.Mutex
+
+	// The sequence number and timestamp the last packet was sent with, and what
+	// is added to the ones of the Track so a replaced Track continues from there
+	sequenceMu         sync.Mutex
+	sentPacket         bool
+	lastSequenceNumber uint16
+	lastTimestamp      uint32
+	lastPacketTime     time.Time
+	sequenceOffset     uint16
+	timestampOffset    uint32
+	trackReplaced      bool
+}
+
+// continueSequence rewrites the sequence number and timestamp of a packet of
+// the Track of the encoding. The first packet of a replaced Track follows the
+// last packet that was sent, its timestamp advanced by the time in between.
+func (e *trackEncoding) continueSequence(header *rtp.Header, clockRate uint32) {
+	e.sequenceMu.Lock()
+	defer e.sequenceMu.Unlock()
+
+	now := time.Now()
+	if e.trackReplaced {
+		e.trackReplaced = false
+		if e.sentPacket {
+			elapsed := uint32(now.Sub(e.lastPacketTime).Seconds() * float64(clockRate))
+			if elapsed == 0 {
+				elapsed = 1
+			}
+			e.sequenceOffset = e.lastSequenceNumber + 1 - header.SequenceNumber
+			e.timestampOffset = e.lastTimestamp + elapsed - header.Timestamp
+		}
+	}
+
+	header.SequenceNumber += e.sequenceOffset
+	header.Timestamp += e.timestampOffset
+
+	e.sentPacket = true
+	e.lastSequenceNumber = header.SequenceNumber
+	e.lastTimestamp = header.Timestamp
+	e.lastPacketTime = now
+}
+
 // RTPSender allows an application to control how a given Track is encoded and transmitted to a remote peer
 type RTPSender struct {
-	track          *Track
-	rtcpReadStream *srtp.ReadStreamSRTCP
+	// The first encoding is the one the RTPSender was created with,
+	// any others are simulcast encodings added with AddEncoding
+	trackEncodings []*trackEncoding
 
 	transport *DTLSTransport
 
+	kind  RTPCodecType
+	codec *RTPCodec
+
+	// The mid and header extension ids the RID of simulcast encodings are sent with
+	mid              string
+	headerExtensions map[string]int
+
 	// A reference to the associated api object
 	api *API
 
@@ -33,17 +107,43 @@ func (api *API) NewRTPSender(track *Track, transport *DTLSTransport) (*RTPSender
 		return nil, fmt.Errorf("DTLSTransport must not be nil")
 	}
 
-	track.mu.RLock()
-	defer track.mu.RUnlock()
+	track.mu.Lock()
+	defer track.mu.Unlock()
 	if track.receiver != nil {
 		return nil, fmt.Errorf("RTPSender can not be constructed with remote track")
 	}
 	track.totalSenderCount++
 
+	// Retransmissions get a SSRC of their own if the codec can be retransmitted with RTX
+	rtx := RTPRtxParameters{}
+	if api.mediaEngine.getRTXCodec(track.payloadType) != nil {
+		rtx.SSRC = rand.Uint32() // nolint:gosec
+	}
+
+	// FlexFEC gets a SSRC of its own as well, ULPFEC is sent with the SSRC of the Track
+	fec := RTPFecParameters{}
+	if api.mediaEngine.getCodecByName(track.kind, FlexFEC03) != nil {
+		fec.SSRC = rand.Uint32() // nolint:gosec
+	}
+	fec.Mechanism = getFECMechanism(api.mediaEngine.GetCodecsByKind(track.kind), nil, fec.SSRC)
+
 	return &RTPSender{
-		track:      track,
+		trackEncodings: []*trackEncoding{{
+			track: track,
+			RTPEncodingParameters: RTPEncodingParameters{
+				RTPCodingParameters: RTPCodingParameters{
+					SSRC:        track.ssrc,
+					PayloadType: track.payloadType,
+					RTX:         rtx,
+					FEC:         fec,
+				},
+				Active: true,
+			},
+		}},
 		transport:  transport,
 		api:        api,
+		kind:       track.kind,
+		codec:      track.codec,
 		sendCalled: make(chan interface{}),
 		stopCalled: make(chan interface{}),
 	}, nil
@@ -57,13 +157,238 @@ func (r *RTPSender) Transport() *DTLSTransport {
 	return r.transport
 }
 
+// setTransport moves a RTPSender that is not sending yet to another DTLSTransport
+func (r *RTPSender) setTransport(transport *DTLSTransport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.hasSent() {
+		r.transport = transport
+	}
+}
+
+// Track returns the RTPSender's Track, or nil if the Track was removed
+func (r *RTPSender) Track() *Track {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.trackEncodings[0].track
+}
+
+// GetParameters describes the current configuration of the encodings of the RTPSender
+func (r *RTPSender) GetParameters() RTPSendParameters {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	parameters := RTPSendParameters{}
+	for _, e := range r.trackEncodings {
+		parameters.Encodings = append(parameters.Encodings, e.RTPEncodingParameters)
+	}
+	for uri, id := range r.headerExtensions {
+		parameters.HeaderExtensions = append(parameters.HeaderExtensions, RTPHeaderExtensionParameters{URI: uri, ID: id})
+	}
+	return parameters
+}
+
+// SetParameters updates the Active and MaxBitrate settings of the encodings of the
+// RTPSender. The encodings must be the ones returned by GetParameters, in the same order
+func (r *RTPSender) SetParameters(parameters RTPSendParameters) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(parameters.Encodings) != len(r.trackEncodings) {
+		return ErrRTPSenderEncodingsModified
+	}
+	for i, e := range r.trackEncodings {
+		if parameters.Encodings[i].RTPCodingParameters != e.RTPCodingParameters {
+			return ErrRTPSenderEncodingsModified
+		}
+	}
+
+	for i, e := range r.trackEncodings {
+		e.Active = parameters.Encodings[i].Active
+		e.MaxBitrate = parameters.Encodings[i].MaxBitrate
+	}
+	return nil
+}
+
+// AddEncoding adds a simulcast encoding to the RTPSender that is sent from track.
+// Every encoding needs a unique RID, so the first encoding must have been given
+// one with RtpTransceiverInit.SendEncodings. The SSRC of the encoding defaults
+// to the SSRC of track. Encodings can only be added before the RTPSender is sending
+func (r *RTPSender) AddEncoding(track *Track, parameters RTPEncodingParameters) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.stopCalled:
+		return fmt.Errorf("RTPSender has been stopped")
+	default:
+	}
+
+	switch {
+	case r.hasSent():
+		return fmt.Errorf("Send has already been called")
+	case track == nil:
+		return fmt.Errorf("Track must not be nil")
+	case parameters.RID == "" || r.trackEncodings[0].RID == "":
+		return ErrRTPSenderRIDNil
+	}
+
+	if err := r.checkTrack(track); err != nil {
+		return err
+	}
+
+	if parameters.SSRC == 0 {
+		parameters.SSRC = track.SSRC()
+	}
+	parameters.PayloadType = r.trackEncodings[0].PayloadType
+	for _, e := range r.trackEncodings {
+		if e.RID == parameters.RID {
+			return ErrRTPSenderRIDCollision
+		} else if e.track == track {
+			return fmt.Errorf("Track is already sent by this RTPSender")
+		} else if e.SSRC == parameters.SSRC {
+			return fmt.Errorf("an encoding with SSRC %d already exists", parameters.SSRC)
+		}
+	}
+
+	encoding := &trackEncoding{track: track, RTPEncodingParameters: parameters}
+	r.trackEncodings = append(r.trackEncodings, encoding)
+	r.attachTrack(encoding)
+	return nil
+}
+
+// setFirstEncoding applies RtpTransceiverInit.SendEncodings to the encoding the RTPSender was created with
+func (r *RTPSender) setFirstEncoding(parameters RTPEncodingParameters) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := r.trackEncodings[0]
+	if parameters.SSRC == 0 {
+		parameters.SSRC = e.SSRC
+	}
+	if parameters.RTX.SSRC == 0 && parameters.RID == "" {
+		parameters.RTX = e.RTX
+	}
+	if parameters.FEC == (RTPFecParameters{}) && parameters.RID == "" {
+		parameters.FEC = e.FEC
+	}
+	parameters.PayloadType = e.PayloadType
+	e.RTPEncodingParameters = parameters
+}
+
+// setMid sets the mid that is sent with the RID of simulcast encodings
+func (r *RTPSender) setMid(mid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mid = mid
+}
+
+// isSimulcast tells if the encodings of the RTPSender are identified by RID instead of SSRC
+func (r *RTPSender) isSimulcast() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.trackEncodings) > 1
+}
+
+// ReplaceTrack replaces the Track currently being sent by the RTPSender. The new Track
+// must be of the same kind and codec, so media can be switched without renegotiation.
+// It is sent with every encoding, including the simulcast encodings, each of them
+// continues the sequence numbers of its previous Track. A nil Track stops sending
+// media while keeping the RTPSender negotiated.
+func (r *RTPSender) ReplaceTrack(track *Track) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	select {
+	case <-r.stopCalled:
+		return fmt.Errorf("RTPSender has been stopped")
+	default:
+	}
+
+	if track != nil {
+		if err := r.checkTrack(track); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range r.trackEncodings {
+		r.detachTrack(e)
+	}
+	for _, e := range r.trackEncodings {
+		if track != nil && track != e.track {
+			e.sequenceMu.Lock()
+			e.trackReplaced = true
+			e.sequenceMu.Unlock()
+		}
+		e.track = track
+		r.attachTrack(e)
+	}
+	return nil
+}
+
+// checkTrack returns an error if the Track can't be sent without renegotiation
+func (r *RTPSender) checkTrack(track *Track) error {
+	track.mu.RLock()
+	defer track.mu.RUnlock()
+
+	switch {
+	case track.receiver != nil:
+		return fmt.Errorf("RTPSender can not send a remote track")
+	case track.kind != r.kind:
+		return ErrRTPSenderNewTrackHasIncorrectKind
+	case track.codec == nil || r.codec == nil ||
+		!strings.EqualFold(track.codec.Name, r.codec.Name) ||
+		track.codec.ClockRate != r.codec.ClockRate ||
+		track.codec.Channels != r.codec.Channels:
+		return ErrRTPSenderNewTrackHasIncorrectCodec
+	}
+	return nil
+}
+
+// attachTrack adds the RTPSender to the senders of the Track of an encoding, r.mu must be held
+func (r *RTPSender) attachTrack(e *trackEncoding) {
+	if e.track == nil {
+		return
+	}
+
+	e.track.mu.Lock()
+	defer e.track.mu.Unlock()
+	e.track.totalSenderCount++
+	if r.hasSent() && e.rtcpReadStream != nil {
+		e.track.addActiveSender(r)
+	}
+}
+
+// detachTrack removes the RTPSender from the senders of the Track of an encoding, r.mu must be held
+func (r *RTPSender) detachTrack(e *trackEncoding) {
+	if e.track == nil {
+		return
+	}
+
+	e.track.mu.Lock()
+	defer e.track.mu.Unlock()
+	filtered := []*RTPSender{}
+	for _, s := range e.track.activeSenders {
+		if s != r {
+			filtered = append(filtered, s)
+		}
+	}
+	e.track.activeSenders = filtered
+	e.track.totalSenderCount--
+}
+
 // Send Attempts to set the parameters controlling the sending of media.
+// Encodings are matched with the encodings of the RTPSender in order, any
+// encodings left out are not sent.
 func (r *RTPSender) Send(parameters RTPSendParameters) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	if r.hasSent() {
 		return fmt.Errorf("Send has already been called")
+	} else if len(parameters.Encodings) == 0 || len(parameters.Encodings) > len(r.trackEncodings) {
+		return fmt.Errorf("Send must be called with between 1 and %d encodings", len(r.trackEncodings))
 	}
 
 	srtcpSession, err := r.transport.getSRTCPSession()
@@ -71,16 +396,30 @@ func (r *RTPSender) Send(parameters RTPSendParameters) error {
 		return err
 	}
 
-	r.rtcpReadStream, err = srtcpSession.OpenReadStream(parameters.Encodings.SSRC)
-	if err != nil {
-		return err
+	for i, encoding := range parameters.Encodings {
+		e := r.trackEncodings[i]
+		if e.rtcpReadStream, err = srtcpSession.OpenReadStream(encoding.SSRC); err != nil {
+			return err
+		}
+		e.RTPEncodingParameters = encoding
+		r.bindInterceptor(e, parameters.HeaderExtensions)
 	}
 
-	r.track.mu.Lock()
-	r.track.activeSenders = append(r.track.activeSenders, r)
-	r.track.mu.Unlock()
-
+	r.headerExtensions = map[string]int{}
+	for _, extension := range parameters.HeaderExtensions {
+		r.headerExtensions[extension.URI] = extension.ID
+	}
 	close(r.sendCalled)
+
+	for _, e := range r.trackEncodings {
+		if e.track == nil || e.rtcpReadStream == nil {
+			continue
+		}
+
+		e.track.mu.Lock()
+		e.track.addActiveSender(r)
+		e.track.mu.Unlock()
+	}
 	return nil
 }
 
@@ -95,30 +434,91 @@ func (r *RTPSender) Stop() error {
 	default:
 	}
 
-	r.track.mu.Lock()
-	defer r.track.mu.Unlock()
-	filtered := []*RTPSender{}
-	for _, s := range r.track.activeSenders {
-		if s != r {
-			filtered = append(filtered, s)
-		} else {
-			r.track.totalSenderCount--
-		}
+	for _, e := range r.trackEncodings {
+		r.detachTrack(e)
 	}
-	r.track.activeSenders = filtered
 	close(r.stopCalled)
 
-	if r.hasSent() {
-		return r.rtcpReadStream.Close()
+	for _, e := range r.trackEncodings {
+		if e.rtcpReadStream == nil {
+			continue
+		}
+		r.api.interceptor.UnbindLocalStream(e.streamInfo)
+		if err := e.rtcpReadStream.Close(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// bindInterceptor binds a sent encoding to the Interceptor of the API, r.mu must be held
+func (r *RTPSender) bindInterceptor(e *trackEncoding, headerExtensions []RTPHeaderExtensionParameters) {
+	e.streamInfo = &interceptor.StreamInfo{
+		SSRC:        e.SSRC,
+		PayloadType: e.PayloadType,
+	}
+	if e.track != nil {
+		e.streamInfo.ID = e.track.ID()
+	}
+	if r.codec != nil {
+		e.streamInfo.MimeType = r.codec.MimeType
+		e.streamInfo.ClockRate = r.codec.ClockRate
+		e.streamInfo.Channels = r.codec.Channels
+		e.streamInfo.SDPFmtpLine = r.codec.SDPFmtpLine
+		for _, feedback := range r.codec.RTCPFeedback {
+			e.streamInfo.RTCPFeedback = append(e.streamInfo.RTCPFeedback, interceptor.RTCPFeedback{Type: feedback.Type, Parameter: feedback.Parameter})
+		}
+	}
+	if e.RTX.SSRC != 0 {
+		if rtxCodec := r.api.mediaEngine.getRTXCodec(e.PayloadType); rtxCodec != nil {
+			e.streamInfo.SSRCRetransmission = e.RTX.SSRC
+			e.streamInfo.PayloadTypeRetransmission = rtxCodec.PayloadType
+		}
+	}
+	switch e.FEC.Mechanism {
+	case fecMechanismFlexFEC:
+		if fecCodec := r.api.mediaEngine.getCodecByName(r.kind, FlexFEC03); fecCodec != nil {
+			e.streamInfo.SSRCForwardErrorCorrection = e.FEC.SSRC
+			e.streamInfo.PayloadTypeForwardErrorCorrection = fecCodec.PayloadType
+		}
+	case fecMechanismULPFEC:
+		redCodec, fecCodec := r.api.mediaEngine.getCodecByName(r.kind, RED), r.api.mediaEngine.getCodecByName(r.kind, ULPFEC)
+		if redCodec != nil && fecCodec != nil {
+			e.streamInfo.PayloadTypeRED = redCodec.PayloadType
+			e.streamInfo.PayloadTypeForwardErrorCorrection = fecCodec.PayloadType
+		}
+	}
+	for _, extension := range headerExtensions {
+		e.streamInfo.RTPHeaderExtensions = append(e.streamInfo.RTPHeaderExtensions, interceptor.RTPHeaderExtension{URI: extension.URI, ID: extension.ID})
+	}
+
+	transport := r.transport
+	e.rtpWriter = r.api.interceptor.BindLocalStream(e.streamInfo, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		srtpSession, err := transport.getSRTPSession()
+		if err != nil {
+			return 0, err
+		}
+
+		writeStream, err := srtpSession.OpenWriteStream()
+		if err != nil {
+			return 0, err
+		}
+		return writeStream.WriteRTP(header, payload)
+	}))
+
+	rtcpReadStream := e.rtcpReadStream
+	e.rtcpReader = r.api.interceptor.BindRTCPReader(interceptor.RTCPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, err := rtcpReadStream.Read(b)
+		return n, attributes, err
+	}))
+}
+
 // Read reads incoming RTCP for this RTPReceiver
 func (r *RTPSender) Read(b []byte) (n int, err error) {
 	<-r.sendCalled
-	return r.rtcpReadStream.Read(b)
+	n, _, err = r.trackEncodings[0].rtcpReader.Read(b, make(interceptor.Attributes))
+	return n, err
 }
 
 // ReadRTCP is a convenience method that wraps Read and unmarshals for you
@@ -133,22 +533,51 @@ func (r *RTPSender) ReadRTCP() ([]rtcp.Packet, error) {
 }
 
 // sendRTP should only be called by a track, this only exists so we can keep state in one place
-func (r *RTPSender) sendRTP(header *rtp.Header, payload []byte) (int, error) {
+func (r *RTPSender) sendRTP(track *Track, header *rtp.Header, payload []byte) (int, error) {
 	select {
 	case <-r.stopCalled:
 		return 0, fmt.Errorf("RTPSender has been stopped")
 	case <-r.sendCalled:
-		srtpSession, err := r.transport.getSRTPSession()
-		if err != nil {
-			return 0, err
+		r.mu.RLock()
+		sending := []*trackEncoding{}
+		encodings := []RTPEncodingParameters{}
+		rtpWriters := []interceptor.RTPWriter{}
+		for _, e := range r.trackEncodings {
+			if e.track == track && e.rtcpReadStream != nil && e.Active {
+				sending = append(sending, e)
+				encodings = append(encodings, e.RTPEncodingParameters)
+				rtpWriters = append(rtpWriters, e.rtpWriter)
+			}
 		}
+		mid, headerExtensions := r.mid, r.headerExtensions
+		r.mu.RUnlock()
 
-		writeStream, err := srtpSession.OpenWriteStream()
-		if err != nil {
-			return 0, err
-		}
+		n := 0
+		for i, sent := range sending {
+			// The Track may have been replaced, so always send with the negotiated SSRC and PayloadType
+			rewritten := *header
+			rewritten.SSRC = encodings[i].SSRC
+			rewritten.PayloadType = encodings[i].PayloadType
+			if r.codec != nil {
+				sent.continueSequence(&rewritten, r.codec.ClockRate)
+			}
 
-		return writeStream.WriteRTP(header, payload)
+			// Simulcast encodings aren't signaled by SSRC, the remote finds them by mid and RID instead
+			// https://tools.ietf.org/html/draft-ietf-mmusic-rid-15#section-5
+			if encodings[i].RID != "" {
+				setRTPHeaderExtensions(&rewritten, map[int][]byte{
+					headerExtensions[sdesMidURI]:         []byte(mid),
+					headerExtensions[sdesRTPStreamIDURI]: []byte(encodings[i].RID),
+				})
+			}
+
+			var err error
+			if n, err = rtpWriters[i].Write(&rewritten, payload, make(interceptor.Attributes)); err != nil {
+				return n, err
+			}
+			sent.countSent(&rewritten, payload)
+		}
+		return n, nil
 	}
 }
 
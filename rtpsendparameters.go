@@ -2,5 +2,6 @@ package webrtc
 
 // RTPSendParameters contains the RTP stack settings used by receivers
 type RTPSendParameters struct {
-	Encodings RTPEncodingParameters
+	Encodings        []RTPEncodingParameters
+	HeaderExtensions []RTPHeaderExtensionParameters
 }
@@ -4,40 +4,148 @@ package webrtc
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v2/pkg/rtcerr"
 )
 
 // RTPTransceiver represents a combination of an RTPSender and an RTPReceiver that share a common mid.
 type RTPTransceiver struct {
-	Sender    *RTPSender
-	Receiver  *RTPReceiver
-	Direction RTPTransceiverDirection
-	// currentDirection RTPTransceiverDirection
-	// firedDirection   RTPTransceiverDirection
-	// receptive bool
-	stopped bool
-	kind    RTPCodecType
+	Sender   *RTPSender
+	Receiver *RTPReceiver
+	// Direction is the preferred direction of the RTPTransceiver, use
+	// SetDirection so the PeerConnection knows negotiation is needed
+	Direction        RTPTransceiverDirection
+	currentDirection RTPTransceiverDirection
+	mid              string
+	stopped          bool
+	kind             RTPCodecType
+	codecs           []*RTPCodec // User provided codec preferences, empty for all registered codecs
+
+	api *API
+
+	updateNegotiationNeeded func()
 }
 
-func (t *RTPTransceiver) setSendingTrack(track *Track) error {
-	if track == nil {
-		return fmt.Errorf("track must not be nil")
+// Mid gets the mid of the media section the RTPTransceiver is associated with,
+// this is empty until the RTPTransceiver has been negotiated
+func (t *RTPTransceiver) Mid() string {
+	return t.mid
+}
+
+// CurrentDirection returns the direction negotiated by the last answer, it is
+// zero until the RTPTransceiver has been negotiated
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtptransceiver-currentdirection
+func (t *RTPTransceiver) CurrentDirection() RTPTransceiverDirection {
+	return t.currentDirection
+}
+
+// SetDirection changes the preferred direction of the RTPTransceiver. The new
+// direction is used by the next offer or answer, so this requires renegotiation
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtptransceiver-direction
+func (t *RTPTransceiver) SetDirection(d RTPTransceiverDirection) error {
+	if t.stopped {
+		return &rtcerr.InvalidStateError{Err: fmt.Errorf("RTPTransceiver has been stopped")}
+	}
+
+	switch d {
+	case RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendonly, RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionInactive:
+	default:
+		return &rtcerr.TypeError{Err: fmt.Errorf("invalid RTPTransceiverDirection: %s", d)}
+	}
+
+	if d == t.Direction {
+		return nil
+	}
+	t.Direction = d
+
+	if t.updateNegotiationNeeded != nil {
+		t.updateNegotiationNeeded()
+	}
+	return nil
+}
+
+// SetCodecPreferences sets the codecs, in order of preference, that are offered
+// or answered for this RTPTransceiver. Every codec must be registered in the
+// MediaEngine, an empty list restores the default of all registered codecs
+// https://w3c.github.io/webrtc-pc/#dom-rtcrtptransceiver-setcodecpreferences
+func (t *RTPTransceiver) SetCodecPreferences(codecs []RTPCodecCapability) error {
+	registered := t.api.mediaEngine.GetCodecsByKind(t.kind)
+
+	preferred := []*RTPCodec{}
+	for _, capability := range codecs {
+		var match *RTPCodec
+		for _, codec := range registered {
+			if codecCapabilityMatches(codec.RTPCodecCapability, capability) {
+				match = codec
+				break
+			}
+		}
+		if match == nil {
+			return &rtcerr.InvalidModificationError{Err: ErrRTPTransceiverCodecUnsupported}
+		}
+		preferred = append(preferred, match)
 	}
 
-	t.Sender.track = track
+	t.codecs = preferred
+	return nil
+}
+
+// codecCapabilityMatches compares everything that identifies a codec, the
+// RTCPFeedback is not part of that
+func codecCapabilityMatches(a, b RTPCodecCapability) bool {
+	return strings.EqualFold(a.MimeType, b.MimeType) &&
+		a.ClockRate == b.ClockRate &&
+		a.Channels == b.Channels &&
+		a.SDPFmtpLine == b.SDPFmtpLine
+}
+
+// setSendingTrack replaces the Track of the Sender and updates the direction,
+// a nil Track stops sending on the RTPTransceiver
+func (t *RTPTransceiver) setSendingTrack(track *Track) error {
+	if t.Sender == nil {
+		return fmt.Errorf("RTPTransceiver has no RTPSender")
+	}
 
-	switch t.Direction {
-	case RTPTransceiverDirectionRecvonly:
-		t.Direction = RTPTransceiverDirectionSendrecv
-	case RTPTransceiverDirectionInactive:
-		t.Direction = RTPTransceiverDirectionSendonly
+	direction := t.Direction
+	switch {
+	case track != nil && t.Direction == RTPTransceiverDirectionRecvonly:
+		direction = RTPTransceiverDirectionSendrecv
+	case track != nil && t.Direction == RTPTransceiverDirectionInactive:
+		direction = RTPTransceiverDirectionSendonly
+	case track == nil && t.Direction == RTPTransceiverDirectionSendrecv:
+		direction = RTPTransceiverDirectionRecvonly
+	case track == nil && t.Direction == RTPTransceiverDirectionSendonly:
+		direction = RTPTransceiverDirectionInactive
+	case track != nil && (t.Direction == RTPTransceiverDirectionSendrecv || t.Direction == RTPTransceiverDirectionSendonly),
+		track == nil && (t.Direction == RTPTransceiverDirectionRecvonly || t.Direction == RTPTransceiverDirectionInactive):
+		// The direction already matches, only the Track is replaced
 	default:
 		return fmt.Errorf("invalid state change in RTPTransceiver.setSending")
 	}
+
+	if err := t.Sender.ReplaceTrack(track); err != nil {
+		return err
+	}
+	t.Direction = direction
 	return nil
 }
 
 // Stop irreversibly stops the RTPTransceiver
 func (t *RTPTransceiver) Stop() error {
+	if err := t.stop(); err != nil {
+		return err
+	}
+
+	if t.updateNegotiationNeeded != nil {
+		t.updateNegotiationNeeded()
+	}
+	return nil
+}
+
+func (t *RTPTransceiver) stop() error {
+	t.stopped = true
+
 	if t.Sender != nil {
 		if err := t.Sender.Stop(); err != nil {
 			return err
@@ -60,3 +60,39 @@ func (t RTPTransceiverDirection) String() string {
 		return ErrUnknownType.Error()
 	}
 }
+
+func newRTPTransceiverDirectionFromSendRecv(send, recv bool) RTPTransceiverDirection {
+	switch {
+	case send && recv:
+		return RTPTransceiverDirectionSendrecv
+	case send:
+		return RTPTransceiverDirectionSendonly
+	case recv:
+		return RTPTransceiverDirectionRecvonly
+	default:
+		return RTPTransceiverDirectionInactive
+	}
+}
+
+func (t RTPTransceiverDirection) hasSend() bool {
+	return t == RTPTransceiverDirectionSendrecv || t == RTPTransceiverDirectionSendonly
+}
+
+func (t RTPTransceiverDirection) hasRecv() bool {
+	return t == RTPTransceiverDirectionSendrecv || t == RTPTransceiverDirectionRecvonly
+}
+
+// reverse returns the direction as seen by the remote peer
+func (t RTPTransceiverDirection) reverse() RTPTransceiverDirection {
+	if t == RTPTransceiverDirection(Unknown) {
+		return t
+	}
+	return newRTPTransceiverDirectionFromSendRecv(t.hasRecv(), t.hasSend())
+}
+
+// intersect returns the direction allowed by both t and other, this is
+// how an answer direction is chosen from the offered direction
+// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-26#section-5.3.1
+func (t RTPTransceiverDirection) intersect(other RTPTransceiverDirection) RTPTransceiverDirection {
+	return newRTPTransceiverDirectionFromSendRecv(t.hasSend() && other.hasSend(), t.hasRecv() && other.hasRecv())
+}
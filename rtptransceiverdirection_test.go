@@ -47,3 +47,27 @@ func TestRTPTransceiverDirection_String(t *testing.T) {
 		)
 	}
 }
+
+func TestRTPTransceiverDirection_Intersect(t *testing.T) {
+	testCases := []struct {
+		local    RTPTransceiverDirection
+		offered  RTPTransceiverDirection
+		expected RTPTransceiverDirection
+	}{
+		{RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendrecv},
+		{RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionSendonly, RTPTransceiverDirectionRecvonly},
+		{RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionSendonly},
+		{RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionInactive, RTPTransceiverDirectionInactive},
+		{RTPTransceiverDirectionSendonly, RTPTransceiverDirectionSendonly, RTPTransceiverDirectionInactive},
+		{RTPTransceiverDirectionRecvonly, RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionRecvonly},
+		{RTPTransceiverDirectionInactive, RTPTransceiverDirectionSendrecv, RTPTransceiverDirectionInactive},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.expected,
+			testCase.local.intersect(testCase.offered.reverse()),
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}
@@ -0,0 +1,130 @@
+// +build !js
+
+package webrtc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v2"
+)
+
+// rtxOriginalSequenceNumberSize is the size of the sequence number a RTX
+// packet carries in front of the payload it retransmits
+// https://tools.ietf.org/html/rfc4588#section-4
+const rtxOriginalSequenceNumberSize = 2
+
+// parseAssociatedPayloadType returns the apt parameter of a RTX fmtp line
+func parseAssociatedPayloadType(fmtp string) (uint8, bool) {
+	for _, parameter := range strings.Split(fmtp, ";") {
+		split := strings.SplitN(strings.TrimSpace(parameter), "=", 2)
+		if len(split) != 2 || split[0] != "apt" {
+			continue
+		}
+		if apt, err := strconv.ParseUint(split[1], 10, 8); err == nil {
+			return uint8(apt), true
+		}
+	}
+	return 0, false
+}
+
+// getRTXPayloadType returns the payload type a m-line retransmits a payload type with
+func getRTXPayloadType(media *sdp.MediaDescription, payloadType uint8) (uint8, bool) {
+	if media == nil {
+		return 0, false
+	}
+
+	rtxPayloadTypes := map[string]bool{}
+	for _, attr := range media.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+		if split := strings.Fields(attr.Value); len(split) == 2 && strings.HasPrefix(strings.ToLower(split[1]), RTX+"/") {
+			rtxPayloadTypes[split[0]] = true
+		}
+	}
+
+	for _, attr := range media.Attributes {
+		if attr.Key != "fmtp" {
+			continue
+		}
+		split := strings.SplitN(attr.Value, " ", 2)
+		if len(split) != 2 || !rtxPayloadTypes[split[0]] {
+			continue
+		}
+		if apt, ok := parseAssociatedPayloadType(split[1]); ok && apt == payloadType {
+			if rtxPayloadType, err := strconv.ParseUint(split[0], 10, 8); err == nil {
+				return uint8(rtxPayloadType), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// getRTXSSRCs returns the RTX SSRC of every SSRC of a m-line that has one,
+// they are grouped as FID
+// https://tools.ietf.org/html/rfc4588#section-8.3
+func getRTXSSRCs(media *sdp.MediaDescription) map[uint32]uint32 {
+	return getGroupedSSRCs(media, "FID")
+}
+
+// getGroupedSSRCs returns the second SSRC of every ssrc-group of a m-line with
+// the semantics, by the first SSRC of the group
+// https://tools.ietf.org/html/rfc5576#section-4.2
+func getGroupedSSRCs(media *sdp.MediaDescription, semantics string) map[uint32]uint32 {
+	grouped := map[uint32]uint32{}
+	for _, attr := range media.Attributes {
+		if attr.Key != sdp.AttrKeySSRCGroup {
+			continue
+		}
+
+		fields := strings.Fields(attr.Value)
+		if len(fields) != 3 || fields[0] != semantics {
+			continue
+		}
+		ssrc, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			continue
+		}
+		if groupedSSRC, err := strconv.ParseUint(fields[2], 10, 32); err == nil {
+			grouped[uint32(ssrc)] = uint32(groupedSSRC)
+		}
+	}
+	return grouped
+}
+
+// unwrapRTX turns a RTX packet back into the packet it retransmits
+// https://tools.ietf.org/html/rfc4588#section-4
+func unwrapRTX(p *rtp.Packet, ssrc uint32, payloadType uint8) ([]byte, error) {
+	if len(p.Payload) < rtxOriginalSequenceNumberSize {
+		// Empty RTX packets are only sent to probe bandwidth
+		return nil, fmt.Errorf("RTX packet has no original sequence number")
+	}
+
+	unwrapped := *p
+	unwrapped.SequenceNumber = binary.BigEndian.Uint16(p.Payload)
+	unwrapped.SSRC = ssrc
+	unwrapped.PayloadType = payloadType
+	unwrapped.Payload = p.Payload[rtxOriginalSequenceNumberSize:]
+	return unwrapped.Marshal()
+}
+
+// sendsRTX tells if a m-line with the codecs offers or accepts RTX for a payload
+// type, an answer only does if the offer in remoteMedia did as well
+func sendsRTX(codecs []*RTPCodec, remoteMedia *sdp.MediaDescription, payloadType uint8) bool {
+	if remoteMedia != nil {
+		if _, ok := getRTXPayloadType(remoteMedia, payloadType); !ok {
+			return false
+		}
+	}
+
+	for _, codec := range codecs {
+		if apt, ok := codec.getAssociatedPayloadType(); ok && apt == payloadType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRTXPayloadType(t *testing.T) {
+	media := (&sdp.MediaDescription{}).
+		WithCodec(96, "VP8", 90000, 0, "").
+		WithCodec(97, "rtx", 90000, 0, "apt=96").
+		WithCodec(102, "H264", 90000, 0, "packetization-mode=1").
+		WithCodec(125, "RTX", 90000, 0, "rtx-time=3000;apt=102")
+
+	rtxPayloadType, ok := getRTXPayloadType(media, 96)
+	assert.True(t, ok)
+	assert.Equal(t, uint8(97), rtxPayloadType)
+
+	rtxPayloadType, ok = getRTXPayloadType(media, 102)
+	assert.True(t, ok)
+	assert.Equal(t, uint8(125), rtxPayloadType)
+
+	_, ok = getRTXPayloadType(media, 98)
+	assert.False(t, ok)
+
+	_, ok = getRTXPayloadType(nil, 96)
+	assert.False(t, ok)
+}
+
+func TestGetRTXSSRCs(t *testing.T) {
+	media := (&sdp.MediaDescription{}).
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FID 1 2").
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FEC-FR 1 3").
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "SIM 4 5 6").
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FID 4 7")
+
+	assert.Equal(t, map[uint32]uint32{1: 2, 4: 7}, getRTXSSRCs(media))
+}
+
+func TestUnwrapRTX(t *testing.T) {
+	rtxPacket := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         true,
+			PayloadType:    97,
+			SequenceNumber: 3000,
+			Timestamp:      90000,
+			SSRC:           2,
+		},
+		Payload: []byte{0x01, 0x02, 0xAA, 0xBB},
+	}
+
+	raw, err := unwrapRTX(rtxPacket, 1, 96)
+	assert.NoError(t, err)
+
+	unwrapped := &rtp.Packet{}
+	assert.NoError(t, unwrapped.Unmarshal(raw))
+	assert.True(t, unwrapped.Marker)
+	assert.Equal(t, uint8(96), unwrapped.PayloadType)
+	assert.Equal(t, uint16(0x0102), unwrapped.SequenceNumber)
+	assert.Equal(t, uint32(90000), unwrapped.Timestamp)
+	assert.Equal(t, uint32(1), unwrapped.SSRC)
+	assert.Equal(t, []byte{0xAA, 0xBB}, unwrapped.Payload)
+
+	// Padding only RTX packets probe bandwidth, they retransmit nothing
+	rtxPacket.Payload = []byte{}
+	_, err = unwrapRTX(rtxPacket, 1, 96)
+	assert.Error(t, err)
+}
@@ -67,6 +67,16 @@ func (r *SCTPTransport) Transport() *DTLSTransport {
 	return r.dtlsTransport
 }
 
+// setDTLSTransport moves a SCTPTransport that hasn't been started to another DTLSTransport
+func (r *SCTPTransport) setDTLSTransport(dtlsTransport *DTLSTransport) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.association == nil {
+		r.dtlsTransport = dtlsTransport
+	}
+}
+
 // GetCapabilities returns the SCTPCapabilities of the SCTPTransport.
 func (r *SCTPTransport) GetCapabilities() SCTPCapabilities {
 	return SCTPCapabilities{
@@ -3,10 +3,16 @@
 package webrtc
 
 import (
+	"math/rand"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pion/sdp/v2"
+	"github.com/pion/transport/test"
+	"github.com/pion/webrtc/v2/pkg/media"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -57,6 +63,29 @@ func extractSsrcList(md *sdp.MediaDescription) []string {
 	return ssrcList
 }
 
+// extractTrackSsrcList returns the SSRCs of the tracks of a m-line, the RTX
+// SSRCs that repair them are left out
+func extractTrackSsrcList(md *sdp.MediaDescription) []string {
+	repairSSRCs := getRepairSSRCs(md)
+	ssrcList := []string{}
+	for _, ssrc := range extractSsrcList(md) {
+		if parsed, err := strconv.ParseUint(ssrc, 10, 32); err == nil && repairSSRCs[uint32(parsed)] {
+			continue
+		}
+		ssrcList = append(ssrcList, ssrc)
+	}
+	return ssrcList
+}
+
+func TestGetRepairSSRCs(t *testing.T) {
+	media := (&sdp.MediaDescription{}).
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FID 1 2").
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "FEC-FR 1 3 4").
+		WithValueAttribute(sdp.AttrKeySSRCGroup, "SIM 5 6 7")
+
+	assert.Equal(t, map[uint32]bool{2: true, 3: true, 4: true}, getRepairSSRCs(media))
+}
+
 func TestSDPSemantics_PlanBOfferTransceivers(t *testing.T) {
 	opc, err := NewPeerConnection(Configuration{
 		SDPSemantics: SDPSemanticsPlanB,
@@ -98,7 +127,7 @@ func TestSDPSemantics_PlanBOfferTransceivers(t *testing.T) {
 	for _, section := range []string{"video", "audio"} {
 		for _, media := range offer.parsed.MediaDescriptions {
 			if media.MediaName.Media == section {
-				assert.Len(t, extractSsrcList(media), 2)
+				assert.Len(t, extractTrackSsrcList(media), 2)
 			}
 		}
 	}
@@ -202,7 +231,7 @@ func TestSDPSemantics_PlanBAnswerSenders(t *testing.T) {
 	for _, section := range []string{"video", "audio"} {
 		for _, media := range answer.parsed.MediaDescriptions {
 			if media.MediaName.Media == section {
-				assert.Lenf(t, extractSsrcList(media), 2, "%q should have 2 SSRCs in Plan-B mode", section)
+				assert.Lenf(t, extractTrackSsrcList(media), 2, "%q should have 2 SSRCs in Plan-B mode", section)
 			}
 		}
 	}
@@ -284,10 +313,14 @@ func TestSDPSemantics_UnifiedPlanWithFallback(t *testing.T) {
 	assert.ObjectsAreEqual(mdNames, []string{"video", "audio", "data"})
 
 	extractSsrcList := func(md *sdp.MediaDescription) []string {
+		repairSSRCs := getRepairSSRCs(md)
 		ssrcMap := map[string]struct{}{}
 		for _, attr := range md.Attributes {
 			if attr.Key == "ssrc" {
 				ssrc := strings.Fields(attr.Value)[0]
+				if parsed, err := strconv.ParseUint(ssrc, 10, 32); err == nil && repairSSRCs[uint32(parsed)] {
+					continue
+				}
 				ssrcMap[ssrc] = struct{}{}
 			}
 		}
@@ -306,3 +339,154 @@ func TestSDPSemantics_UnifiedPlanWithFallback(t *testing.T) {
 		}
 	}
 }
+
+func TestSDPSemantics_PlanBSendTracks(t *testing.T) {
+	lim := test.TimeOut(time.Second * 20)
+	defer lim.Stop()
+
+	api := NewAPI()
+	api.mediaEngine.RegisterDefaultCodecs()
+
+	pcOffer, err := api.NewPeerConnection(Configuration{SDPSemantics: SDPSemanticsPlanB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := api.NewPeerConnection(Configuration{SDPSemantics: SDPSemanticsPlanB})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := []*Track{}
+	for _, id := range []string{"video1", "video2"} {
+		track, trackErr := pcOffer.NewTrack(DefaultPayloadTypeVP8, rand.Uint32(), id, "stream")
+		if trackErr != nil {
+			t.Fatal(trackErr)
+		}
+		if _, trackErr = pcOffer.AddTransceiverFromTrack(track, RtpTransceiverInit{Direction: RTPTransceiverDirectionSendonly}); trackErr != nil {
+			t.Fatal(trackErr)
+		}
+		tracks = append(tracks, track)
+	}
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pcAnswer.AddTransceiverFromKind(RTPCodecTypeVideo, RtpTransceiverInit{Direction: RTPTransceiverDirectionRecvonly}); err != nil {
+		t.Fatal(err)
+	}
+
+	offer, err := pcOffer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"video", "audio", "application"}, getMdNames(offer.parsed))
+	assert.Contains(t, offer.SDP, "a=msid-semantic:WMS stream\r\n")
+	for _, media := range offer.parsed.MediaDescriptions {
+		switch media.MediaName.Media {
+		case "video":
+			assert.Len(t, extractTrackSsrcList(media), 2)
+			_, sendrecv := media.Attribute(RTPTransceiverDirectionSendrecv.String())
+			assert.True(t, sendrecv)
+		case "audio":
+			assert.Len(t, extractTrackSsrcList(media), 0)
+			_, recvonly := media.Attribute(RTPTransceiverDirectionRecvonly.String())
+			assert.True(t, recvonly)
+		}
+	}
+	for _, track := range tracks {
+		assert.Contains(t, offer.SDP, "msid:stream "+track.ID()+"\r\n")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(tracks))
+	var mu sync.Mutex
+	received := map[string]bool{}
+	pcAnswer.OnTrack(func(track *Track, r *RTPReceiver) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !received[track.ID()] {
+			received[track.ID()] = true
+			assert.Equal(t, "stream", track.Label())
+			wg.Done()
+		}
+	})
+
+	if err = signalPair(pcOffer, pcAnswer); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	func() {
+		for {
+			select {
+			case <-time.After(20 * time.Millisecond):
+				for _, track := range tracks {
+					assert.NoError(t, track.WriteSample(media.Sample{Data: []byte{0x00}, Samples: 1}))
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	assert.True(t, received["video1"])
+	assert.True(t, received["video2"])
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
+
+func TestSDPSemantics_PlanBRenegotiationKeepsOrder(t *testing.T) {
+	pcOffer, err := NewPeerConnection(Configuration{SDPSemantics: SDPSemanticsPlanB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcAnswer, err := NewPeerConnection(Configuration{SDPSemantics: SDPSemanticsPlanB})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	negotiate := func() []string {
+		offer, offerErr := pcOffer.CreateOffer(nil)
+		if offerErr != nil {
+			t.Fatal(offerErr)
+		}
+		if offerErr = pcOffer.SetLocalDescription(offer); offerErr != nil {
+			t.Fatal(offerErr)
+		}
+		if offerErr = pcAnswer.SetRemoteDescription(offer); offerErr != nil {
+			t.Fatal(offerErr)
+		}
+		answer, offerErr := pcAnswer.CreateAnswer(nil)
+		if offerErr != nil {
+			t.Fatal(offerErr)
+		}
+		if offerErr = pcAnswer.SetLocalDescription(answer); offerErr != nil {
+			t.Fatal(offerErr)
+		}
+		if offerErr = pcOffer.SetRemoteDescription(answer); offerErr != nil {
+			t.Fatal(offerErr)
+		}
+		return getMdNames(offer.parsed)
+	}
+
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeAudio); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"audio", "application"}, negotiate())
+
+	if _, err = pcOffer.AddTransceiverFromKind(RTPCodecTypeVideo); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"audio", "application", "video"}, negotiate())
+
+	assert.NoError(t, pcOffer.Close())
+	assert.NoError(t, pcAnswer.Close())
+}
@@ -0,0 +1,118 @@
+// +build !js
+
+package webrtc
+
+import (
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v2/pkg/interceptor"
+)
+
+// ntpEpochOffset is the number of seconds from the NTP epoch in 1900 to the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// toNTPTime returns a time as 64 bit NTP timestamp, the seconds since 1900
+// in the upper 32 bits and the fraction of a second in the lower 32 bits
+// https://tools.ietf.org/html/rfc3550#section-4
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// countSent adds a packet that was sent to the counts of the encoding, the
+// octet count only includes the payload without its padding
+func (e *trackEncoding) countSent(header *rtp.Header, payload []byte) {
+	octets := len(payload)
+	if header.Padding && octets != 0 {
+		octets -= int(payload[octets-1])
+	}
+
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+
+	e.packetCount++
+	if octets > 0 {
+		e.octetCount += uint32(octets)
+	}
+	e.lastRTPTimestamp = header.Timestamp
+	e.lastSentTime = time.Now()
+}
+
+// senderReport returns the Sender Report of the encoding at a time, or nil if
+// nothing was sent yet. The RTP timestamp is extrapolated from the last packet
+// that was sent with the clock rate of the codec
+// https://tools.ietf.org/html/rfc3550#section-6.4.1
+func (e *trackEncoding) senderReport(now time.Time, clockRate uint32) *rtcp.SenderReport {
+	e.statsMu.Lock()
+	defer e.statsMu.Unlock()
+
+	if e.packetCount == 0 {
+		return nil
+	}
+
+	elapsed := now.Sub(e.lastSentTime)
+	return &rtcp.SenderReport{
+		SSRC:        e.SSRC,
+		NTPTime:     toNTPTime(now),
+		RTPTime:     e.lastRTPTimestamp + uint32(elapsed.Nanoseconds()*int64(clockRate)/int64(time.Second)),
+		PacketCount: e.packetCount,
+		OctetCount:  e.octetCount,
+	}
+}
+
+// senderReports returns the Sender Reports of the active encodings of the
+// RTPSender at a time, none once it is stopped
+func (r *RTPSender) senderReports(now time.Time) []rtcp.Packet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	select {
+	case <-r.stopCalled:
+		return nil
+	default:
+	}
+
+	clockRate := uint32(0)
+	if r.codec != nil {
+		clockRate = r.codec.ClockRate
+	}
+	pkts := []rtcp.Packet{}
+	for _, e := range r.trackEncodings {
+		if e.rtcpReadStream == nil || !e.Active {
+			continue
+		}
+		if report := e.senderReport(now, clockRate); report != nil {
+			pkts = append(pkts, report)
+		}
+	}
+	return pkts
+}
+
+// sendReports periodically sends a Sender Report for every active encoding of
+// the RTPSenders of the PeerConnection, until it is closed. They are written
+// through the Interceptor like the RTCP of the application
+func (pc *PeerConnection) sendReports(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pc.reportsDone:
+			return
+		case now := <-ticker.C:
+			// Every RTPSender is written on its own, they may not share a transport
+			for _, sender := range pc.GetSenders() {
+				pkts := sender.senderReports(now)
+				if len(pkts) == 0 {
+					continue
+				}
+				if _, err := pc.interceptorRTCPWriter.Write(pkts, make(interceptor.Attributes)); err != nil {
+					pc.log.Warnf("Failed to send Sender Report: %v", err)
+				}
+			}
+		}
+	}
+}
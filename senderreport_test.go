@@ -0,0 +1,36 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToNTPTime(t *testing.T) {
+	assert.Equal(t, uint64(ntpEpochOffset)<<32, toNTPTime(time.Unix(0, 0)))
+	assert.Equal(t, uint64(ntpEpochOffset+1)<<32|0x80000000, toNTPTime(time.Unix(1, int64(500*time.Millisecond))))
+	assert.Equal(t, uint64(0xE284C81C)<<32|0x40000000, toNTPTime(time.Unix(1591363996, int64(250*time.Millisecond))))
+}
+
+func TestTrackEncoding_SenderReport(t *testing.T) {
+	e := &trackEncoding{RTPEncodingParameters: RTPEncodingParameters{RTPCodingParameters: RTPCodingParameters{SSRC: 5}}}
+	assert.Nil(t, e.senderReport(time.Now(), 90000))
+
+	e.countSent(&rtp.Header{Timestamp: 3000}, []byte{0x01, 0x02, 0x03})
+	// The padding of a packet isn't part of the octet count
+	e.countSent(&rtp.Header{Timestamp: 6000, Padding: true}, []byte{0x01, 0x02, 0x00, 0x02})
+
+	now := e.lastSentTime.Add(100 * time.Millisecond)
+	report := e.senderReport(now, 90000)
+	if assert.NotNil(t, report) {
+		assert.Equal(t, uint32(5), report.SSRC)
+		assert.Equal(t, toNTPTime(now), report.NTPTime)
+		assert.Equal(t, uint32(6000+9000), report.RTPTime)
+		assert.Equal(t, uint32(2), report.PacketCount)
+		assert.Equal(t, uint32(5), report.OctetCount)
+	}
+}
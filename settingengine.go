@@ -7,6 +7,7 @@ import (
 
 	"github.com/pion/ice"
 	"github.com/pion/logging"
+	"github.com/pion/sdp/v2"
 )
 
 // SettingEngine allows influencing behavior in ways that are not
@@ -31,9 +32,18 @@ type SettingEngine struct {
 	}
 	candidates struct {
 		ICETrickle      bool
+		ICELite         bool
 		ICENetworkTypes []NetworkType
 	}
-	LoggerFactory logging.LoggerFactory
+	rtcp struct {
+		SenderReportInterval *time.Duration
+	}
+	sdpMungers struct {
+		Local  func(SDPType, *sdp.SessionDescription) error
+		Remote func(SDPType, *sdp.SessionDescription) error
+	}
+	LoggerFactory     logging.LoggerFactory
+	identityProviders map[string]IdentityProvider
 }
 
 // DetachDataChannels enables detaching data channels. When enabled
@@ -75,6 +85,13 @@ func (e *SettingEngine) SetRelayAcceptanceMinWait(t time.Duration) {
 	e.timeout.ICERelayAcceptanceMinWait = &t
 }
 
+// SetSenderReportInterval sets how often a PeerConnection sends a RTCP Sender
+// Report for every encoding its RTPSenders send, the default is once a second.
+// An interval of zero stops sending them.
+func (e *SettingEngine) SetSenderReportInterval(interval time.Duration) {
+	e.rtcp.SenderReportInterval = &interval
+}
+
 // SetEphemeralUDPPortRange limits the pool of ephemeral ports that
 // ICE UDP connections can allocate from. This affects both host candidates,
 // and the local address of server reflexive candidates.
@@ -94,6 +111,38 @@ func (e *SettingEngine) SetTrickle(trickle bool) {
 	e.candidates.ICETrickle = trickle
 }
 
+// SetLite configures whether or not the ice agent should act as an ICE-lite agent.
+// A lite agent only gathers host candidates, answers connectivity checks and is
+// always controlled by a full remote agent.
+func (e *SettingEngine) SetLite(lite bool) {
+	e.candidates.ICELite = lite
+}
+
+// SetLocalDescriptionMunger sets a function that can modify the descriptions
+// created by CreateOffer and CreateAnswer before they are returned, like adding
+// b=AS or changing a fmtp. The m-lines, their mids and the ICE and DTLS
+// parameters have to stay the same.
+func (e *SettingEngine) SetLocalDescriptionMunger(f func(sdpType SDPType, desc *sdp.SessionDescription) error) {
+	e.sdpMungers.Local = f
+}
+
+// SetRemoteDescriptionMunger sets a function that can modify the descriptions
+// passed to SetRemoteDescription before they are applied. The m-lines, their mids
+// and the ICE and DTLS parameters have to stay the same.
+func (e *SettingEngine) SetRemoteDescriptionMunger(f func(sdpType SDPType, desc *sdp.SessionDescription) error) {
+	e.sdpMungers.Remote = f
+}
+
+// RegisterIdentityProvider makes an IdentityProvider available for its domain.
+// PeerConnections use it once it's selected with SetIdentityProvider, and to
+// validate the identity assertions of remote peers that name the domain.
+func (e *SettingEngine) RegisterIdentityProvider(domain string, provider IdentityProvider) {
+	if e.identityProviders == nil {
+		e.identityProviders = map[string]IdentityProvider{}
+	}
+	e.identityProviders[domain] = provider
+}
+
 // SetNetworkTypes configures what types of candidate networks are supported
 // during local and server reflexive gathering.
 func (e *SettingEngine) SetNetworkTypes(candidateTypes []NetworkType) {
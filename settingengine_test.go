@@ -48,6 +48,21 @@ func TestSetConnectionTimeout(t *testing.T) {
 	}
 }
 
+func TestSetSenderReportInterval(t *testing.T) {
+	s := SettingEngine{}
+
+	if s.rtcp.SenderReportInterval != nil {
+		t.Fatalf("SettingEngine defaults aren't as expected.")
+	}
+
+	s.SetSenderReportInterval(0)
+
+	if s.rtcp.SenderReportInterval == nil ||
+		*s.rtcp.SenderReportInterval != 0 {
+		t.Fatalf("Sender Report interval does not reflect requested value.")
+	}
+}
+
 func TestDetachDataChannels(t *testing.T) {
 	s := SettingEngine{}
 
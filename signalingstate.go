@@ -111,6 +111,15 @@ func checkNextSignalingState(cur, next SignalingState, op stateChangeOp, sdpType
 		}
 	}
 
+	// A pending offer can be rolled back by either side
+	// https://tools.ietf.org/html/draft-ietf-rtcweb-jsep-24#section-4.1.8.2
+	if sdpType == SDPTypeRollback && next == SignalingStateStable {
+		switch cur {
+		case SignalingStateHaveLocalOffer, SignalingStateHaveRemoteOffer:
+			return next, nil
+		}
+	}
+
 	// 4.3.1 valid state transitions
 	switch cur {
 	case SignalingStateStable:
@@ -0,0 +1,76 @@
+// +build !js
+
+package webrtc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/sdp/v2"
+)
+
+// SDP attributes used to negotiate simulcast
+// https://tools.ietf.org/html/draft-ietf-mmusic-rid-15
+// https://tools.ietf.org/html/draft-ietf-mmusic-sdp-simulcast-14
+const (
+	sdpAttributeRid       = "rid"
+	sdpAttributeSimulcast = "simulcast"
+)
+
+// getRids returns the RIDs a remote offer will send in a media section, in the
+// order they were signaled
+func getRids(media *sdp.MediaDescription) []string {
+	rids := []string{}
+	for _, attr := range media.Attributes {
+		if attr.Key != sdpAttributeRid {
+			continue
+		}
+
+		if split := strings.Fields(attr.Value); len(split) >= 2 && split[1] == "send" {
+			rids = append(rids, split[0])
+		}
+	}
+	return rids
+}
+
+// addSimulcastRecv answers every offered RID, so the remote sends all of its layers
+func addSimulcastRecv(media *sdp.MediaDescription, rids []string) {
+	if len(rids) == 0 {
+		return
+	}
+
+	for _, rid := range rids {
+		media.WithValueAttribute(sdpAttributeRid, rid+" recv")
+	}
+	media.WithValueAttribute(sdpAttributeSimulcast, "recv "+strings.Join(rids, ";"))
+}
+
+// addSimulcastSend offers every encoding as a RID, encodings that are not active
+// are offered as paused so they can be resumed without renegotiation
+func addSimulcastSend(media *sdp.MediaDescription, encodings []RTPEncodingParameters) {
+	rids := []string{}
+	for _, encoding := range encodings {
+		rid := encoding.RID + " send"
+		if encoding.MaxBitrate != 0 {
+			rid += fmt.Sprintf(" max-br=%d", encoding.MaxBitrate)
+		}
+		media.WithValueAttribute(sdpAttributeRid, rid)
+
+		if encoding.Active {
+			rids = append(rids, encoding.RID)
+		} else {
+			rids = append(rids, "~"+encoding.RID)
+		}
+	}
+	media.WithValueAttribute(sdpAttributeSimulcast, "send "+strings.Join(rids, ";"))
+}
+
+// isSimulcastNegotiated tells if a remote answer accepted to receive simulcast
+func isSimulcastNegotiated(media *sdp.MediaDescription) bool {
+	if media == nil {
+		return false
+	}
+
+	simulcast, ok := media.Attribute(sdpAttributeSimulcast)
+	return ok && strings.HasPrefix(simulcast, "recv ")
+}
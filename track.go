@@ -26,6 +26,7 @@ type Track struct {
 	kind        RTPCodecType
 	label       string
 	ssrc        uint32
+	rid         string
 	codec       *RTPCodec
 
 	packetizer rtp.Packetizer
@@ -70,6 +71,14 @@ func (t *Track) SSRC() uint32 {
 	return t.ssrc
 }
 
+// RID gets the RTP Stream ID of the track, this is only set for remote
+// tracks that were sent with the RID RTP header extension
+func (t *Track) RID() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.rid
+}
+
 // Codec gets the Codec of the track
 func (t *Track) Codec() *RTPCodec {
 	t.mu.RLock()
@@ -87,7 +96,7 @@ func (t *Track) Read(b []byte) (n int, err error) {
 	r := t.receiver
 	t.mu.RUnlock()
 
-	return r.readRTP(b)
+	return r.readRTP(b, t)
 }
 
 // ReadRTP is a convenience method that wraps Read and unmarshals for you
@@ -150,7 +159,7 @@ func (t *Track) WriteRTP(p *rtp.Packet) error {
 	}
 
 	for _, s := range senders {
-		_, err := s.sendRTP(&p.Header, p.Payload)
+		_, err := s.sendRTP(t, &p.Header, p.Payload)
 		if err != nil {
 			return err
 		}
@@ -159,6 +168,17 @@ func (t *Track) WriteRTP(p *rtp.Packet) error {
 	return nil
 }
 
+// addActiveSender adds a RTPSender that sends the Track, once even if several of
+// its encodings send it. t.mu must be held
+func (t *Track) addActiveSender(r *RTPSender) {
+	for _, s := range t.activeSenders {
+		if s == r {
+			return
+		}
+	}
+	t.activeSenders = append(t.activeSenders, r)
+}
+
 // NewTrack initializes a new *Track
 func NewTrack(payloadType uint8, ssrc uint32, id, label string, codec *RTPCodec) (*Track, error) {
 	if ssrc == 0 {
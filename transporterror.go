@@ -0,0 +1,52 @@
+// +build !js
+
+package webrtc
+
+import "fmt"
+
+// TransportComponent indicates which transport of a PeerConnection failed.
+type TransportComponent int
+
+const (
+	// TransportComponentICE indicates the ICETransport failed to connect.
+	TransportComponentICE TransportComponent = iota + 1
+
+	// TransportComponentDTLS indicates the DTLS handshake failed, or the
+	// certificate of the remote doesn't match its fingerprint.
+	TransportComponentDTLS
+
+	// TransportComponentSCTP indicates the SCTP association couldn't be
+	// established.
+	TransportComponentSCTP
+)
+
+// This is done this way because of a linter.
+const (
+	transportComponentICEStr  = "ice"
+	transportComponentDTLSStr = "dtls"
+	transportComponentSCTPStr = "sctp"
+)
+
+func (t TransportComponent) String() string {
+	switch t {
+	case TransportComponentICE:
+		return transportComponentICEStr
+	case TransportComponentDTLS:
+		return transportComponentDTLSStr
+	case TransportComponentSCTP:
+		return transportComponentSCTPStr
+	default:
+		return ErrUnknownType.Error()
+	}
+}
+
+// TransportError is the error of a transport that failed once
+// SetRemoteDescription returned, it is delivered through OnError.
+type TransportError struct {
+	Component TransportComponent
+	Err       error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s transport failed: %v", e.Component, e.Err)
+}
@@ -0,0 +1,29 @@
+// +build !js
+
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportComponent_String(t *testing.T) {
+	testCases := []struct {
+		component      TransportComponent
+		expectedString string
+	}{
+		{TransportComponent(Unknown), unknownStr},
+		{TransportComponentICE, "ice"},
+		{TransportComponentDTLS, "dtls"},
+		{TransportComponentSCTP, "sctp"},
+	}
+
+	for i, testCase := range testCases {
+		assert.Equal(t,
+			testCase.expectedString,
+			testCase.component.String(),
+			"testCase: %d %v", i, testCase,
+		)
+	}
+}